@@ -59,7 +59,12 @@ func (app *BaseApp) InitChain(req abci.RequestInitChain) (res abci.ResponseInitC
 	// add block gas meter for any genesis transactions (allow infinite gas)
 	app.deliverState.ctx = app.deliverState.ctx.WithBlockGasMeter(sdk.NewInfiniteGasMeter())
 
-	res = app.initChainer(app.deliverState.ctx, req)
+	if ms := app.initChainMultiStore(); ms != nil {
+		res = app.initChainer(app.deliverState.ctx.WithMultiStore(ms), req)
+		ms.Write()
+	} else {
+		res = app.initChainer(app.deliverState.ctx, req)
+	}
 
 	// sanity check
 	if len(req.Validators) > 0 {
@@ -181,6 +186,11 @@ func (app *BaseApp) BeginBlock(req abci.RequestBeginBlock) (res abci.ResponseBeg
 	}
 	// set the signed validators for addition to context in deliverTx
 	app.voteInfos = req.LastCommitInfo.GetVotes()
+
+	for _, l := range app.abciListeners {
+		l.ListenBeginBlock(app.deliverState.ctx, req, res)
+	}
+
 	return res
 }
 
@@ -201,6 +211,10 @@ func (app *BaseApp) EndBlock(req abci.RequestEndBlock) (res abci.ResponseEndBloc
 		res.ConsensusParamUpdates = cp
 	}
 
+	for _, l := range app.abciListeners {
+		l.ListenEndBlock(app.deliverState.ctx, req, res)
+	}
+
 	return res
 }
 
@@ -245,7 +259,7 @@ func (app *BaseApp) CheckTx(req abci.RequestCheckTx) abci.ResponseCheckTx {
 // Otherwise, the ResponseDeliverTx will contain releveant error information.
 // Regardless of tx execution outcome, the ResponseDeliverTx will contain relevant
 // gas execution context.
-func (app *BaseApp) DeliverTx(req abci.RequestDeliverTx) abci.ResponseDeliverTx {
+func (app *BaseApp) DeliverTx(req abci.RequestDeliverTx) (res abci.ResponseDeliverTx) {
 	defer telemetry.MeasureSince(time.Now(), "abci", "deliver_tx")
 
 	gInfo := sdk.GasInfo{}
@@ -258,6 +272,14 @@ func (app *BaseApp) DeliverTx(req abci.RequestDeliverTx) abci.ResponseDeliverTx
 		telemetry.SetGauge(float32(gInfo.GasWanted), "tx", "gas", "wanted")
 	}()
 
+	defer func() {
+		app.streamTxStateChanges(req.Tx)
+
+		for _, l := range app.abciListeners {
+			l.ListenDeliverTx(app.deliverState.ctx, req, res)
+		}
+	}()
+
 	gInfo, result, err := app.runTx(runTxModeDeliver, req.Tx)
 	if err != nil {
 		resultStr = "failed"
@@ -289,10 +311,16 @@ func (app *BaseApp) Commit() (res abci.ResponseCommit) {
 	// Write the DeliverTx state which is cache-wrapped and commit the MultiStore.
 	// The write to the DeliverTx state writes all state transitions to the root
 	// MultiStore (app.cms) so when Commit() is called is persists those values.
+	app.streamingDispatcher.beginCommit(header.Height)
 	app.deliverState.ms.Write()
 	commitID := app.cms.Commit()
 	app.logger.Info("commit synced", "commit", fmt.Sprintf("%X", commitID))
 
+	app.streamingDispatcher.flush(header.Height)
+	app.streamBlockSummary(header.Height)
+	app.streamProofs(header.Height)
+	app.streamLifecycleEvents()
+
 	// Reset the Check state to the latest committed.
 	//
 	// NOTE: This is safe because Tendermint holds a lock on the mempool for
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/gogo/protobuf/proto"
 	abci "github.com/tendermint/tendermint/abci/types"
@@ -128,6 +129,57 @@ type BaseApp struct { // nolint: maligned
 	// indexEvents defines the set of events in the form {eventType}.{attributeKey},
 	// which informs Tendermint what to index. If empty, all events will be indexed.
 	indexEvents map[string]struct{}
+
+	// streamingMtx guards the registration and closing of streaming listeners
+	streamingMtx sync.Mutex
+
+	// streamingListeners holds every WriteListener registered with the BaseApp
+	streamingListeners []store.WriteListener
+	// streamingDispatcher passes the writes to the streamed stores to their
+	// WriteListeners, synchronously or asynchronously
+	streamingDispatcher *streamingDispatcher
+	// streamingReaders pass the reads of the streamed stores to the
+	// WriteListeners implementing ReadListener
+	streamingReaders map[sdk.StoreKey]*streamingReader
+	// bestEffortLanes pass the writes to the best-effort WriteListeners,
+	// one lane per listener
+	bestEffortLanes []*bestEffortLane
+	// stateChangeHub passes the writes of the stores enabled for
+	// subscriptions to the subscriptions made through SubscribeStateChanges
+	stateChangeHub *stateChangeHub
+	// streamGenesis marks the writes of the genesis state to the
+	// GenesisWriteListeners
+	streamGenesis bool
+
+	// abciListeners are notified of the ABCI messages processed by the BaseApp
+	abciListeners []ABCIListener
+
+	// txListeners are passed the state changes of every delivered transaction,
+	// which are buffered in txWriteBuffer by txWriteListeners
+	txListeners      []TxStateChangesListener
+	txWriteBuffer    *txWriteBuffer
+	txWriteListeners map[sdk.StoreKey][]store.WriteListener
+
+	// speculativeListeners are passed the state changes of every checked or
+	// simulated transaction to the speculativeKeys stores
+	speculativeListeners []SpeculativeListener
+	speculativeKeys      []sdk.StoreKey
+
+	// summaryListeners are passed a summary of the state changes of every
+	// block, accumulated by blockSummary over the summarizedKeys stores
+	summaryListeners []BlockSummaryListener
+	blockSummary     *blockSummary
+	summarizedKeys   map[sdk.StoreKey]bool
+
+	// proofListeners are passed the writes recorded by provenWrites along
+	// with the proofs of their values
+	proofListeners []ProofListener
+	provenWrites   *provenWrites
+
+	// lifecycleListeners are passed the lifecycle events of the multi-store
+	// recorded by lifecycleEvents
+	lifecycleListeners []store.StoreLifecycleListener
+	lifecycleEvents    *lifecycleEvents
 }
 
 // NewBaseApp returns a reference to an initialized BaseApp. It accepts a
@@ -150,6 +202,8 @@ func NewBaseApp(
 		msgServiceRouter: NewMsgServiceRouter(),
 		txDecoder:        txDecoder,
 		fauxMerkleMode:   false,
+
+		streamingDispatcher: newStreamingDispatcher(),
 	}
 
 	for _, option := range options {
@@ -533,10 +587,10 @@ func (app *BaseApp) getContextForTx(mode runTxMode, txBytes []byte) sdk.Context
 
 // cacheTxContext returns a new context based off of the provided context with
 // a cache wrapped multi-store.
-func (app *BaseApp) cacheTxContext(ctx sdk.Context, txBytes []byte) (sdk.Context, sdk.CacheMultiStore) {
+func (app *BaseApp) cacheTxContext(ctx sdk.Context, txBytes []byte, mode runTxMode, speculative *txWriteBuffer) (sdk.Context, sdk.CacheMultiStore) {
 	ms := ctx.MultiStore()
 	// TODO: https://github.com/cosmos/cosmos-sdk/issues/2824
-	msCache := ms.CacheMultiStore()
+	msCache := app.cacheTxMultiStore(ms, mode, speculative)
 	if msCache.TracingEnabled() {
 		msCache = msCache.SetTracingContext(
 			sdk.TraceContext(
@@ -566,6 +620,13 @@ func (app *BaseApp) runTx(mode runTxMode, txBytes []byte) (gInfo sdk.GasInfo, re
 	ctx := app.getContextForTx(mode, txBytes)
 	ms := ctx.MultiStore()
 
+	// the writes of checked and simulated transactions are streamed once
+	// they have been run, after any panic was recovered
+	speculative := app.speculativeWriteBuffer(mode)
+	if speculative != nil {
+		defer func() { app.streamSpeculativeTxStateChanges(ctx, mode, txBytes, speculative, err) }()
+	}
+
 	// only run the tx if there is block gas remaining
 	if mode == runTxModeDeliver && ctx.BlockGasMeter().IsOutOfGas() {
 		gInfo = sdk.GasInfo{GasUsed: ctx.BlockGasMeter().GasConsumed()}
@@ -627,7 +688,7 @@ func (app *BaseApp) runTx(mode runTxMode, txBytes []byte) (gInfo sdk.GasInfo, re
 		// NOTE: Alternatively, we could require that AnteHandler ensures that
 		// writes do not happen if aborted/failed.  This may have some
 		// performance benefits, but it'll be more difficult to get right.
-		anteCtx, msCache = app.cacheTxContext(ctx, txBytes)
+		anteCtx, msCache = app.cacheTxContext(ctx, txBytes, mode, speculative)
 		anteCtx = anteCtx.WithEventManager(sdk.NewEventManager())
 		newCtx, err := app.anteHandler(anteCtx, tx, mode == runTxModeSimulate)
 
@@ -657,7 +718,7 @@ func (app *BaseApp) runTx(mode runTxMode, txBytes []byte) (gInfo sdk.GasInfo, re
 	// Create a new Context based off of the existing Context with a cache-wrapped
 	// MultiStore in case message processing fails. At this point, the MultiStore
 	// is doubly cached-wrapped.
-	runMsgCtx, msCache := app.cacheTxContext(ctx, txBytes)
+	runMsgCtx, msCache := app.cacheTxContext(ctx, txBytes, mode, speculative)
 
 	// Attempt to execute all messages and only update state if all messages pass
 	// and we're in DeliverTx. Note, runMsgs will never return a reference to a
@@ -670,6 +731,10 @@ func (app *BaseApp) runTx(mode runTxMode, txBytes []byte) (gInfo sdk.GasInfo, re
 			// append the events in the order of occurrence
 			result.Events = append(events.ToABCIEvents(), result.Events...)
 		}
+	} else if err == nil && mode == runTxModeSimulate && speculative != nil {
+		// the simulation runs on a branch of the check state which is
+		// discarded, writing to it only records the writes to stream
+		msCache.Write()
 	}
 
 	return gInfo, result, err
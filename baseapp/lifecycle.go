@@ -0,0 +1,76 @@
+package baseapp
+
+import (
+	"github.com/cosmos/cosmos-sdk/store"
+)
+
+// lifecycleListenerSetter is implemented by multi-stores reporting their
+// pruned versions and store upgrades (e.g. rootmulti.Store).
+type lifecycleListenerSetter interface {
+	SetLifecycleListener(listener store.StoreLifecycleListener)
+}
+
+// lifecycleEvents is a StoreLifecycleListener recording the lifecycle events
+// of the multi-store until they are streamed on the next Commit.
+type lifecycleEvents struct {
+	events []store.StoreLifecycleEvent
+}
+
+// OnStoreLifecycle implements the StoreLifecycleListener interface.
+func (l *lifecycleEvents) OnStoreLifecycle(event store.StoreLifecycleEvent) {
+	l.events = append(l.events, event)
+}
+
+// flush returns the recorded events and resets the recorder.
+func (l *lifecycleEvents) flush() []store.StoreLifecycleEvent {
+	events := l.events
+	l.events = nil
+
+	return events
+}
+
+// AddStreamingLifecycleListeners registers StoreLifecycleListeners with the
+// BaseApp. On every Commit the listeners are passed the versions pruned from
+// the multi-store by the commit and, on the first Commit after a store
+// upgrade, the stores added, deleted or renamed by the upgrade, so archival
+// consumers know which historical data the node can serve and where it came
+// from. The listeners must be added before the BaseApp loads its stores to be
+// passed the upgrades.
+//
+// Like AddStreamingListeners, it must be called before the BaseApp is sealed
+// and it is safe to call concurrently. It panics if the multi-store doesn't
+// report its lifecycle events.
+func (app *BaseApp) AddStreamingLifecycleListeners(listeners ...store.StoreLifecycleListener) {
+	app.streamingMtx.Lock()
+	defer app.streamingMtx.Unlock()
+
+	if app.sealed {
+		panic("AddStreamingLifecycleListeners() on sealed BaseApp")
+	}
+
+	if app.lifecycleEvents == nil {
+		ls, ok := app.cms.(lifecycleListenerSetter)
+		if !ok {
+			panic("multi-store does not support lifecycle listeners")
+		}
+
+		app.lifecycleEvents = &lifecycleEvents{}
+		ls.SetLifecycleListener(app.lifecycleEvents)
+	}
+
+	app.lifecycleListeners = append(app.lifecycleListeners, listeners...)
+}
+
+// streamLifecycleEvents passes the lifecycle events of the multi-store
+// recorded since the previous Commit to the StoreLifecycleListeners.
+func (app *BaseApp) streamLifecycleEvents() {
+	if len(app.lifecycleListeners) == 0 {
+		return
+	}
+
+	for _, event := range app.lifecycleEvents.flush() {
+		for _, l := range app.lifecycleListeners {
+			l.OnStoreLifecycle(event)
+		}
+	}
+}
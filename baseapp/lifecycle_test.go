@@ -0,0 +1,47 @@
+package baseapp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/store"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+)
+
+type mockLifecycleListener struct {
+	events []store.StoreLifecycleEvent
+}
+
+func (l *mockLifecycleListener) OnStoreLifecycle(event store.StoreLifecycleEvent) {
+	l.events = append(l.events, event)
+}
+
+func TestAddStreamingLifecycleListeners(t *testing.T) {
+	listener := &mockLifecycleListener{}
+	pruningOpt := SetPruning(storetypes.NewPruningOptions(0, 0, 2))
+	streamingOpt := func(bapp *BaseApp) {
+		bapp.AddStreamingLifecycleListeners(listener)
+	}
+
+	app := setupBaseApp(t, pruningOpt, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	var counts []int
+	for height := int64(1); height <= 4; height++ {
+		app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: height}})
+		app.EndBlock(abci.RequestEndBlock{Height: height})
+		app.Commit()
+
+		counts = append(counts, len(listener.events))
+	}
+
+	// the events are streamed on the commit which pruned the versions
+	require.Equal(t, []int{0, 1, 1, 2}, counts)
+	require.Equal(t, []store.StoreLifecycleEvent{
+		{Type: storetypes.StoreVersionsPruned, Height: 2, Versions: []int64{1}},
+		{Type: storetypes.StoreVersionsPruned, Height: 4, Versions: []int64{2, 3}},
+	}, listener.events)
+}
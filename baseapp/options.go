@@ -52,6 +52,31 @@ func SetTrace(trace bool) func(*BaseApp) {
 	return func(app *BaseApp) { app.setTrace(trace) }
 }
 
+// SetStreamingAsync returns a BaseApp option function that sets whether the
+// writes streamed to the WriteListeners registered through
+// AddStreamingListeners are dispatched off the Commit path. In async mode the
+// writes of each block are staged and passed to the listeners on a background
+// goroutine once the block is committed, in the same order as in sync mode.
+func SetStreamingAsync(async bool) func(*BaseApp) {
+	return func(app *BaseApp) { app.setStreamingAsync(async) }
+}
+
+// SetStreamGenesis returns a BaseApp option function that sets whether the
+// writes of the genesis state made by InitChain are passed to the
+// GenesisWriteListeners through OnGenesisWrite when the first block is
+// committed, so sinks can tell the initial state of a new chain apart.
+func SetStreamGenesis(streamGenesis bool) func(*BaseApp) {
+	return func(app *BaseApp) { app.setStreamGenesis(streamGenesis) }
+}
+
+// SetStreamingRetention returns a BaseApp option function that sets whether
+// the multi-store refrains from pruning the heights above the last one
+// acknowledged through AcknowledgeStreamedHeight, until the streaming
+// consumers confirm they were durably delivered.
+func SetStreamingRetention(retain bool) func(*BaseApp) {
+	return func(app *BaseApp) { app.setStreamingRetention(retain) }
+}
+
 // SetIndexEvents provides a BaseApp option function that sets the events to index.
 func SetIndexEvents(ie []string) func(*BaseApp) {
 	return func(app *BaseApp) { app.setIndexEvents(ie) }
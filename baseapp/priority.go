@@ -0,0 +1,224 @@
+package baseapp
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cosmos/cosmos-sdk/store"
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// bestEffortWrite is a write staged by a bestEffortLane.
+type bestEffortWrite struct {
+	kv      StoreKVPair
+	genesis bool
+}
+
+// bestEffortBlock holds the writes of a committed block queued by a
+// bestEffortLane.
+type bestEffortBlock struct {
+	height int64
+	writes []bestEffortWrite
+}
+
+// bestEffortLane is the WriteListener registered with the streamingDispatcher
+// for a best-effort listener. It stages the writes of each block and queues
+// them once the block is committed, without ever blocking: the blocks which
+// don't fit in its backlog are shed. A goroutine passes the queued blocks to
+// the listener, after the guaranteed listeners were passed them.
+type bestEffortLane struct {
+	listener store.WriteListener
+
+	mtx    sync.Mutex
+	staged []bestEffortWrite
+	blocks chan bestEffortBlock
+	done   chan struct{}
+	closed bool
+
+	// shedBlocks and shedWrites count the blocks, and their writes, which
+	// were shed, accessed atomically
+	shedBlocks uint64
+	shedWrites uint64
+}
+
+var (
+	_ GenesisWriteListener = (*bestEffortLane)(nil)
+	_ CommitListener       = (*bestEffortLane)(nil)
+	_ io.Closer            = (*bestEffortLane)(nil)
+)
+
+func newBestEffortLane(listener store.WriteListener, backlog int) *bestEffortLane {
+	lane := &bestEffortLane{
+		listener: listener,
+		blocks:   make(chan bestEffortBlock, backlog),
+		done:     make(chan struct{}),
+	}
+
+	go lane.run()
+
+	return lane
+}
+
+// run passes the queued blocks to the listener until the lane is closed.
+func (lane *bestEffortLane) run() {
+	defer close(lane.done)
+
+	gl, _ := lane.listener.(GenesisWriteListener)
+	cl, _ := lane.listener.(CommitListener)
+
+	for block := range lane.blocks {
+		for _, w := range block.writes {
+			if gl != nil && w.genesis {
+				gl.OnGenesisWrite(w.kv.StoreKey, w.kv.Key, w.kv.Value)
+				continue
+			}
+
+			lane.listener.OnWrite(w.kv.StoreKey, w.kv.Key, w.kv.Value)
+		}
+
+		if cl != nil {
+			cl.OnCommit(block.height)
+		}
+	}
+}
+
+// OnWrite implements the WriteListener interface.
+func (lane *bestEffortLane) OnWrite(storeKey sdk.StoreKey, key []byte, value []byte) {
+	lane.stage(bestEffortWrite{kv: StoreKVPair{StoreKey: storeKey, Key: key, Value: value}})
+}
+
+// OnGenesisWrite implements the GenesisWriteListener interface.
+func (lane *bestEffortLane) OnGenesisWrite(storeKey sdk.StoreKey, key []byte, value []byte) {
+	lane.stage(bestEffortWrite{kv: StoreKVPair{StoreKey: storeKey, Key: key, Value: value}, genesis: true})
+}
+
+// stage stages the write, unless the lane is closed.
+func (lane *bestEffortLane) stage(w bestEffortWrite) {
+	lane.mtx.Lock()
+	defer lane.mtx.Unlock()
+
+	if !lane.closed {
+		lane.staged = append(lane.staged, w)
+	}
+}
+
+// OnCommit implements the CommitListener interface. It queues the writes of
+// the block, or sheds them if the backlog is full.
+func (lane *bestEffortLane) OnCommit(height int64) {
+	lane.mtx.Lock()
+	defer lane.mtx.Unlock()
+
+	if lane.closed {
+		return
+	}
+
+	block := bestEffortBlock{height: height, writes: lane.staged}
+	lane.staged = nil
+
+	select {
+	case lane.blocks <- block:
+	default:
+		atomic.AddUint64(&lane.shedBlocks, 1)
+		atomic.AddUint64(&lane.shedWrites, uint64(len(block.writes)))
+
+		telemetry.IncrCounter(1, "streaming", "shed_blocks")
+		telemetry.IncrCounter(float32(len(block.writes)), "streaming", "shed_writes")
+	}
+}
+
+// Err returns the error reported by the listener, if it implements
+// `Err() error`.
+func (lane *bestEffortLane) Err() error {
+	if e, ok := lane.listener.(interface{ Err() error }); ok {
+		return e.Err()
+	}
+
+	return nil
+}
+
+// Close implements the io.Closer interface. It waits for the queued blocks to
+// be passed to the listener, then closes it if it implements io.Closer.
+func (lane *bestEffortLane) Close() error {
+	lane.mtx.Lock()
+	if !lane.closed {
+		lane.closed = true
+		lane.staged = nil
+		close(lane.blocks)
+	}
+	lane.mtx.Unlock()
+
+	<-lane.done
+
+	if c, ok := lane.listener.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// AddBestEffortStreamingListeners registers WriteListeners for the KVStore
+// mounted under the provided key like AddStreamingListeners, except that the
+// listeners are best-effort rather than guaranteed destinations: the
+// guaranteed listeners, registered through AddStreamingListeners, are passed
+// the writes of every block first and are never held back by them.
+//
+// Each best-effort listener is passed the writes of every committed block, and
+// then committed, on a goroutine of its own, from a backlog of up to the given
+// number of blocks. The blocks committed while its backlog is full are shed
+// whole, so the listener is passed every write of a block or none, and counted
+// in the StreamingStatus and the streaming.shed_blocks and
+// streaming.shed_writes metrics. Best-effort listeners are not passed the
+// reads of the store.
+//
+// It panics if the backlog is negative.
+func (app *BaseApp) AddBestEffortStreamingListeners(key sdk.StoreKey, backlog int, listeners ...store.WriteListener) {
+	if backlog < 0 {
+		panic(fmt.Sprintf("negative best-effort streaming backlog %d", backlog))
+	}
+
+	lanes := make([]store.WriteListener, len(listeners))
+	for i, l := range listeners {
+		lanes[i] = app.bestEffortLane(l, backlog)
+	}
+
+	app.addStreamingListeners(key, nil, lanes, false)
+}
+
+// bestEffortLane returns the lane of the best-effort listener, creating it if
+// the listener wasn't registered for another store yet.
+func (app *BaseApp) bestEffortLane(l store.WriteListener, backlog int) *bestEffortLane {
+	app.streamingMtx.Lock()
+	defer app.streamingMtx.Unlock()
+
+	if app.sealed {
+		panic("AddBestEffortStreamingListeners() on sealed BaseApp")
+	}
+
+	if reflect.TypeOf(l).Comparable() {
+		for _, lane := range app.bestEffortLanes {
+			if lane.listener == l {
+				return lane
+			}
+		}
+	}
+
+	lane := newBestEffortLane(l, backlog)
+	app.bestEffortLanes = append(app.bestEffortLanes, lane)
+
+	return lane
+}
+
+// shedStreamingWrites returns the number of blocks, and writes, shed by the
+// best-effort listeners.
+func (app *BaseApp) shedStreamingWrites() (blocks, writes uint64) {
+	for _, lane := range app.bestEffortLanes {
+		blocks += atomic.LoadUint64(&lane.shedBlocks)
+		writes += atomic.LoadUint64(&lane.shedWrites)
+	}
+
+	return blocks, writes
+}
@@ -0,0 +1,89 @@
+package baseapp
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// blockingListener is a WriteListener whose commits block until released.
+type blockingListener struct {
+	mtx     sync.Mutex
+	writes  []string
+	commits []int64
+	closed  bool
+
+	committing chan int64
+	release    chan struct{}
+}
+
+func (l *blockingListener) OnWrite(_ sdk.StoreKey, key []byte, _ []byte) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.writes = append(l.writes, string(key))
+}
+
+func (l *blockingListener) OnCommit(height int64) {
+	l.committing <- height
+	<-l.release
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.commits = append(l.commits, height)
+}
+
+func (l *blockingListener) Close() error {
+	l.closed = true
+	return nil
+}
+
+func TestAddBestEffortStreamingListeners(t *testing.T) {
+	guaranteed := newMockWriteListener()
+	bestEffort := &blockingListener{committing: make(chan int64, 3), release: make(chan struct{})}
+	streamingOpt := func(bapp *BaseApp) {
+		bapp.AddStreamingListeners(capKey1, guaranteed)
+		bapp.AddBestEffortStreamingListeners(capKey1, 1, bestEffort)
+	}
+
+	app := setupBaseApp(t, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	for height := int64(1); height <= 3; height++ {
+		app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: height}})
+		app.deliverState.ctx.KVStore(capKey1).Set([]byte{byte('0' + height)}, []byte("value"))
+		app.EndBlock(abci.RequestEndBlock{Height: height})
+		app.Commit()
+
+		// the best-effort listener is stuck on the first block, the second
+		// one fills its backlog and the third one is shed
+		if height == 1 {
+			select {
+			case <-bestEffort.committing:
+			case <-time.After(time.Second):
+				t.Fatal("best-effort listener was not committed")
+			}
+		}
+	}
+
+	// the guaranteed listener is never held back
+	require.Len(t, guaranteed.writes, 3)
+
+	status := app.StreamingStatus()
+	require.Equal(t, uint64(1), status.ShedBlocks)
+	require.Equal(t, uint64(1), status.ShedWrites)
+
+	close(bestEffort.release)
+	require.NoError(t, app.CloseStreamingListeners(time.Second))
+
+	require.Equal(t, []string{"1", "2"}, bestEffort.writes)
+	require.Equal(t, []int64{1, 2}, bestEffort.commits)
+	require.True(t, bestEffort.closed)
+}
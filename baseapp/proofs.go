@@ -0,0 +1,160 @@
+package baseapp
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmcrypto "github.com/tendermint/tendermint/proto/tendermint/crypto"
+
+	"github.com/cosmos/cosmos-sdk/store"
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ProvenWrite is a write committed in the block at Height along with the
+// Merkle proof of the value of its key, or of its absence if the key was
+// deleted, against the app hash returned by the Commit of the block. The
+// proof can be verified with rootmulti.DefaultProofRuntime against the key
+// path "/<store name>/<key>", built with merkle.KeyPath for binary keys.
+type ProvenWrite struct {
+	StoreKVPair
+	Height int64
+	Proof  *tmcrypto.ProofOps
+}
+
+// ProofListener is the interface for streaming writes along with their
+// proofs, so consumers can serve trust-minimized data to their own users.
+type ProofListener interface {
+	OnProvenWrites(writes []ProvenWrite)
+}
+
+// provenWrites is a WriteListener recording the writes to the keys whose
+// values are proven. A key written several times in a block is recorded once,
+// with its final value, as only that one can be proven.
+type provenWrites struct {
+	prefixes map[sdk.StoreKey][][]byte
+	writes   []StoreKVPair
+	index    map[string]int
+}
+
+func newProvenWrites() *provenWrites {
+	return &provenWrites{
+		prefixes: make(map[sdk.StoreKey][][]byte),
+		index:    make(map[string]int),
+	}
+}
+
+// OnWrite implements the WriteListener interface.
+func (p *provenWrites) OnWrite(storeKey sdk.StoreKey, key []byte, value []byte) {
+	if !p.proven(storeKey, key) {
+		return
+	}
+
+	id := storeKey.Name() + "/" + string(key)
+	if i, ok := p.index[id]; ok {
+		p.writes[i].Value = value
+		return
+	}
+
+	p.index[id] = len(p.writes)
+	p.writes = append(p.writes, StoreKVPair{StoreKey: storeKey, Key: key, Value: value})
+}
+
+// proven returns true if the key has one of the proven prefixes of its
+// store.
+func (p *provenWrites) proven(storeKey sdk.StoreKey, key []byte) bool {
+	for _, prefix := range p.prefixes[storeKey] {
+		if bytes.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// flush returns the recorded writes and resets the recorder.
+func (p *provenWrites) flush() []StoreKVPair {
+	writes := p.writes
+	p.writes = nil
+	p.index = make(map[string]int)
+
+	return writes
+}
+
+// AddStreamingProofListeners registers ProofListeners with the BaseApp. On
+// every Commit the listeners are passed the writes committed to the keys of
+// the store mounted under the provided key starting with one of the given
+// prefixes, along with their proofs. Every key of the store is proven if no
+// prefix is given. Proving a key costs a query of the committed store, so the
+// prefixes should be kept narrow.
+//
+// Like AddStreamingListeners, it must be called before the BaseApp is sealed
+// and it is safe to call concurrently.
+func (app *BaseApp) AddStreamingProofListeners(key sdk.StoreKey, prefixes [][]byte, listeners ...ProofListener) {
+	app.streamingMtx.Lock()
+	defer app.streamingMtx.Unlock()
+
+	if app.sealed {
+		panic("AddStreamingProofListeners() on sealed BaseApp")
+	}
+
+	if _, ok := key.(*sdk.KVStoreKey); !ok {
+		panic(fmt.Sprintf("cannot add streaming listeners for non-persistent store %s", key.Name()))
+	}
+
+	if app.provenWrites == nil {
+		app.provenWrites = newProvenWrites()
+	}
+
+	if _, ok := app.provenWrites.prefixes[key]; !ok {
+		app.cms.AddListeners(key, []store.WriteListener{app.provenWrites})
+	}
+
+	if len(prefixes) == 0 {
+		prefixes = [][]byte{{}}
+	}
+	app.provenWrites.prefixes[key] = append(app.provenWrites.prefixes[key], prefixes...)
+
+	app.proofListeners = append(app.proofListeners, listeners...)
+}
+
+// streamProofs passes the proven writes committed in the block at the given
+// height, along with their proofs, to the ProofListeners. Writes whose proof
+// can't be queried are logged and skipped.
+func (app *BaseApp) streamProofs(height int64) {
+	if len(app.proofListeners) == 0 {
+		return
+	}
+
+	defer telemetry.MeasureSince(time.Now(), "streaming", "proof_listeners")
+
+	queryable, ok := app.cms.(sdk.Queryable)
+	if !ok {
+		app.logger.Error("multi-store doesn't support queries, cannot stream proofs")
+		return
+	}
+
+	writes := app.provenWrites.flush()
+	proven := make([]ProvenWrite, 0, len(writes))
+
+	for _, kv := range writes {
+		res := queryable.Query(abci.RequestQuery{
+			Path:   "/" + kv.StoreKey.Name() + "/key",
+			Data:   kv.Key,
+			Height: height,
+			Prove:  true,
+		})
+		if res.IsErr() {
+			app.logger.Error("failed to prove streamed write", "store", kv.StoreKey.Name(), "key", fmt.Sprintf("%X", kv.Key), "err", res.Log)
+			continue
+		}
+
+		proven = append(proven, ProvenWrite{StoreKVPair: kv, Height: height, Proof: res.ProofOps})
+	}
+
+	for _, l := range app.proofListeners {
+		l.OnProvenWrites(proven)
+	}
+}
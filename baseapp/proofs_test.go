@@ -0,0 +1,63 @@
+package baseapp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto/merkle"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/store/rootmulti"
+)
+
+type mockProofListener struct {
+	writes []ProvenWrite
+}
+
+func (l *mockProofListener) OnProvenWrites(writes []ProvenWrite) {
+	l.writes = append(l.writes, writes...)
+}
+
+func TestAddStreamingProofListeners(t *testing.T) {
+	listener := &mockProofListener{}
+	streamingOpt := func(bapp *BaseApp) {
+		bapp.AddStreamingProofListeners(capKey1, [][]byte{[]byte("proven/")}, listener)
+	}
+
+	app := setupBaseApp(t, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: 1}})
+	store := app.deliverState.ctx.KVStore(capKey1)
+	store.Set([]byte("proven/a"), []byte("1"))
+	store.Set([]byte("proven/a"), []byte("2"))
+	store.Set([]byte("proven/b"), []byte("1"))
+	store.Delete([]byte("proven/b"))
+	store.Set([]byte("other"), []byte("1"))
+	app.EndBlock(abci.RequestEndBlock{Height: 1})
+	app.Commit()
+
+	require.Len(t, listener.writes, 2)
+	appHash := app.LastCommitID().Hash
+	prt := rootmulti.DefaultProofRuntime()
+
+	keyPath := func(key string) string {
+		return merkle.KeyPath{}.
+			AppendKey([]byte(capKey1.Name()), merkle.KeyEncodingURL).
+			AppendKey([]byte(key), merkle.KeyEncodingHex).
+			String()
+	}
+
+	written := listener.writes[0]
+	require.Equal(t, int64(1), written.Height)
+	require.Equal(t, []byte("proven/a"), written.Key)
+	require.Equal(t, []byte("2"), written.Value)
+	require.NoError(t, prt.VerifyValue(written.Proof, appHash, keyPath("proven/a"), []byte("2")))
+	require.Error(t, prt.VerifyValue(written.Proof, appHash, keyPath("proven/a"), []byte("1")))
+
+	deleted := listener.writes[1]
+	require.Equal(t, []byte("proven/b"), deleted.Key)
+	require.Nil(t, deleted.Value)
+	require.NoError(t, prt.VerifyAbsence(deleted.Proof, appHash, keyPath("proven/b")))
+}
@@ -0,0 +1,71 @@
+package baseapp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/store"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// storeKeyResolver is implemented by multi-stores which resolve the names of
+// their mounted stores (e.g. rootmulti.Store).
+type storeKeyResolver interface {
+	StoreKeyByName(name string) sdk.StoreKey
+}
+
+// ReplayBlock commits the given writes as the block at the given height,
+// without executing it, and returns the resulting app hash. Replaying the
+// streamed writes of every block of a chain, genesis included, into the empty
+// stores of a new node rebuilds their state and history, like a state sync
+// from a stream archive. The app hash only matches the one of the chain if
+// every persistent store was streamed.
+//
+// The height must follow the latest height, unless no block was committed,
+// in which case the stores start at the given height. The writes are not
+// passed to the streaming listeners.
+func (app *BaseApp) ReplayBlock(height int64, writes []store.FrameRecord) ([]byte, error) {
+	resolver, ok := app.cms.(storeKeyResolver)
+	if !ok {
+		return nil, errors.New("multi-store does not support replays")
+	}
+
+	lastHeight := app.LastBlockHeight()
+	switch {
+	case lastHeight == 0 && height > 1:
+		if err := app.cms.SetInitialVersion(height); err != nil {
+			return nil, err
+		}
+
+	case height != lastHeight+1:
+		return nil, fmt.Errorf("cannot replay height %d after height %d", height, lastHeight)
+	}
+
+	// resolve every store first, so an invalid block leaves the stores as is
+	stores := make([]sdk.CommitKVStore, len(writes))
+	for i, write := range writes {
+		key := resolver.StoreKeyByName(write.StoreKey)
+		if key == nil {
+			return nil, fmt.Errorf("cannot replay height %d: unknown store %s", height, write.StoreKey)
+		}
+
+		if stores[i] = app.cms.GetCommitKVStore(key); stores[i] == nil {
+			return nil, fmt.Errorf("cannot replay height %d: store %s is not persistent", height, write.StoreKey)
+		}
+	}
+
+	for i, write := range writes {
+		if write.Delete {
+			stores[i].Delete(write.Key)
+			continue
+		}
+
+		value := write.Value
+		if value == nil {
+			value = []byte{}
+		}
+		stores[i].Set(write.Key, value)
+	}
+
+	return app.cms.Commit().Hash, nil
+}
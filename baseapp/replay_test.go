@@ -0,0 +1,71 @@
+package baseapp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/store"
+)
+
+func TestReplayBlock(t *testing.T) {
+	blocks := [][]store.FrameRecord{
+		{
+			{StoreKey: capKey1.Name(), Key: []byte("a"), Value: []byte("1")},
+			{StoreKey: capKey2.Name(), Key: []byte("b"), Value: []byte("2")},
+		},
+		{
+			{StoreKey: capKey1.Name(), Key: []byte("a"), Value: []byte("3")},
+			{StoreKey: capKey1.Name(), Key: []byte("c"), Value: []byte{}},
+		},
+		{
+			{StoreKey: capKey2.Name(), Key: []byte("b"), Delete: true},
+		},
+	}
+
+	// execute the blocks on a first app
+	app := setupBaseApp(t)
+	app.InitChain(abci.RequestInitChain{})
+
+	var hashes [][]byte
+	for i, writes := range blocks {
+		height := int64(i + 1)
+		app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: height}})
+		for _, write := range writes {
+			kv := app.deliverState.ctx.KVStore(app.cms.(storeKeyResolver).StoreKeyByName(write.StoreKey))
+			if write.Delete {
+				kv.Delete(write.Key)
+			} else {
+				kv.Set(write.Key, write.Value)
+			}
+		}
+		app.EndBlock(abci.RequestEndBlock{Height: height})
+		hashes = append(hashes, app.Commit().Data)
+	}
+
+	// replay their writes into a new app
+	replayed := setupBaseApp(t)
+	for i, writes := range blocks {
+		hash, err := replayed.ReplayBlock(int64(i+1), writes)
+		require.NoError(t, err)
+		require.Equal(t, hashes[i], hash)
+	}
+	require.Equal(t, int64(len(blocks)), replayed.LastBlockHeight())
+
+	// the heights must follow each other
+	_, err := replayed.ReplayBlock(5, nil)
+	require.EqualError(t, err, "cannot replay height 5 after height 3")
+
+	_, err = replayed.ReplayBlock(4, []store.FrameRecord{{StoreKey: "unknown", Key: []byte("a")}})
+	require.EqualError(t, err, "cannot replay height 4: unknown store unknown")
+}
+
+func TestReplayBlockInitialHeight(t *testing.T) {
+	app := setupBaseApp(t)
+
+	_, err := app.ReplayBlock(10, []store.FrameRecord{{StoreKey: capKey1.Name(), Key: []byte("a"), Value: []byte("1")}})
+	require.NoError(t, err)
+	require.Equal(t, int64(10), app.LastBlockHeight())
+}
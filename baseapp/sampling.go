@@ -0,0 +1,109 @@
+package baseapp
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/store"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// StreamingSampling configures the sample of the writes passed to the
+// WriteListeners registered through AddSampledStreamingListeners, for
+// destinations which only need statistical visibility of the state changes.
+type StreamingSampling struct {
+	// BlockInterval restricts the sample to the writes streamed at heights
+	// which are a multiple of it. Every height is sampled if it is 0 or 1.
+	BlockInterval int64
+	// PrefixRates are the probabilities with which the writes to the keys
+	// under each prefix are sampled, the longest matching prefix applying.
+	// The writes to keys under none of the prefixes are always sampled.
+	PrefixRates []PrefixSamplingRate
+}
+
+// PrefixSamplingRate is the probability, between 0 and 1, with which the
+// writes to the keys under Prefix are sampled.
+type PrefixSamplingRate struct {
+	Prefix []byte
+	Rate   float64
+}
+
+// validate returns an error if the sampling is invalid.
+func (s StreamingSampling) validate() error {
+	if s.BlockInterval < 0 {
+		return fmt.Errorf("negative sampling block interval %d", s.BlockInterval)
+	}
+
+	for _, pr := range s.PrefixRates {
+		if pr.Rate < 0 || pr.Rate > 1 {
+			return fmt.Errorf("sampling rate %v of prefix %X is not between 0 and 1", pr.Rate, pr.Prefix)
+		}
+	}
+
+	return nil
+}
+
+// streamingSampler decides which writes are passed to sampled listeners.
+type streamingSampler struct {
+	blockInterval int64
+	// prefixRates are sorted by decreasing prefix length
+	prefixRates []PrefixSamplingRate
+
+	mtx  sync.Mutex
+	rand *rand.Rand
+}
+
+func newStreamingSampler(sampling StreamingSampling) *streamingSampler {
+	prefixRates := make([]PrefixSamplingRate, len(sampling.PrefixRates))
+	copy(prefixRates, sampling.PrefixRates)
+	sort.SliceStable(prefixRates, func(i, j int) bool {
+		return len(prefixRates[i].Prefix) > len(prefixRates[j].Prefix)
+	})
+
+	return &streamingSampler{
+		blockInterval: sampling.BlockInterval,
+		prefixRates:   prefixRates,
+		rand:          rand.New(rand.NewSource(time.Now().UnixNano())), // nolint:gosec
+	}
+}
+
+// sample returns true if the write streamed at the given height is part of
+// the sample. A nil sampler samples every write.
+func (s *streamingSampler) sample(kv StoreKVPair, height int64) bool {
+	if s == nil {
+		return true
+	}
+
+	if s.blockInterval > 1 && height%s.blockInterval != 0 {
+		return false
+	}
+
+	for _, pr := range s.prefixRates {
+		if bytes.HasPrefix(kv.Key, pr.Prefix) {
+			s.mtx.Lock()
+			defer s.mtx.Unlock()
+
+			return s.rand.Float64() < pr.Rate
+		}
+	}
+
+	return true
+}
+
+// AddSampledStreamingListeners registers WriteListeners for the KVStore
+// mounted under the provided key like AddStreamingListeners, except that the
+// listeners are only passed the sample of the writes configured by sampling.
+// The listeners registered through AddStreamingListeners are unaffected.
+//
+// It panics if the sampling is invalid.
+func (app *BaseApp) AddSampledStreamingListeners(key sdk.StoreKey, sampling StreamingSampling, listeners ...store.WriteListener) {
+	if err := sampling.validate(); err != nil {
+		panic(err)
+	}
+
+	app.addStreamingListeners(key, newStreamingSampler(sampling), listeners, false)
+}
@@ -0,0 +1,51 @@
+package baseapp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+func TestAddSampledStreamingListeners(t *testing.T) {
+	sampled := newMockWriteListener()
+	listener := newMockWriteListener()
+	sampling := StreamingSampling{
+		BlockInterval: 2,
+		PrefixRates: []PrefixSamplingRate{
+			{Prefix: []byte("skip"), Rate: 0},
+			{Prefix: []byte("skip-keep"), Rate: 1},
+		},
+	}
+	streamingOpt := func(bapp *BaseApp) {
+		bapp.AddSampledStreamingListeners(capKey1, sampling, sampled)
+		bapp.AddStreamingListeners(capKey1, listener)
+	}
+
+	app := setupBaseApp(t, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	for height := int64(1); height <= 3; height++ {
+		app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: height}})
+		store := app.deliverState.ctx.KVStore(capKey1)
+		store.Set([]byte("key"), []byte{byte(height)})
+		store.Set([]byte("skip/key"), []byte{byte(height)})
+		store.Set([]byte("skip-keep"), []byte{byte(height)})
+		app.EndBlock(abci.RequestEndBlock{Height: height})
+		app.Commit()
+	}
+
+	require.Equal(t, map[string][]byte{
+		capKey1.Name() + "/key":       {2},
+		capKey1.Name() + "/skip-keep": {2},
+	}, sampled.writes)
+	require.Equal(t, map[string][]byte{
+		capKey1.Name() + "/key":       {3},
+		capKey1.Name() + "/skip/key":  {3},
+		capKey1.Name() + "/skip-keep": {3},
+	}, listener.writes)
+
+	invalid := StreamingSampling{PrefixRates: []PrefixSamplingRate{{Prefix: []byte("key"), Rate: 2}}}
+	require.Panics(t, func() { app.AddSampledStreamingListeners(capKey1, invalid, sampled) })
+}
@@ -0,0 +1,98 @@
+package baseapp
+
+import (
+	"fmt"
+
+	"github.com/tendermint/tendermint/crypto/tmhash"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// Modes of the SpeculativeTxStateChanges.
+const (
+	SpeculativeModeCheck    = "check"
+	SpeculativeModeReCheck  = "recheck"
+	SpeculativeModeSimulate = "simulate"
+)
+
+// SpeculativeTxStateChanges groups the writes a transaction made while it
+// was checked, rechecked or simulated, to the stores it is streamed for.
+// These writes are speculative: they are made to the check state, or to a
+// branch of it, and are never committed. CheckTx only runs the AnteHandler,
+// so its writes are the ones of the AnteHandler, while simulations also run
+// the messages. Err is the error the transaction failed with, if any, in
+// which case the writes of the failed step are discarded and not included.
+type SpeculativeTxStateChanges struct {
+	Mode   string
+	TxHash []byte
+	Writes []StoreKVPair
+	Err    error
+}
+
+// SpeculativeListener is the interface for streaming the speculative state
+// changes of the transactions checked or simulated by the node, e.g. for
+// mempool analytics or to debug the state used by an AnteHandler. It is
+// called after every CheckTx, ReCheckTx and simulation, possibly concurrently,
+// and must not be used as a source of canonical state.
+type SpeculativeListener interface {
+	OnSpeculativeTxStateChanges(ctx sdk.Context, changes SpeculativeTxStateChanges)
+}
+
+// AddSpeculativeStreamingListeners registers SpeculativeListeners with the
+// BaseApp, opting in to the streaming of the writes checked and simulated
+// transactions make to the stores mounted under the provided keys. These
+// writes are kept apart from the canonical writes of delivered transactions
+// passed to the other listeners.
+//
+// Like AddStreamingListeners, it must be called before the BaseApp is sealed
+// and it is safe to call concurrently.
+func (app *BaseApp) AddSpeculativeStreamingListeners(keys []sdk.StoreKey, listeners ...SpeculativeListener) {
+	app.streamingMtx.Lock()
+	defer app.streamingMtx.Unlock()
+
+	if app.sealed {
+		panic("AddSpeculativeStreamingListeners() on sealed BaseApp")
+	}
+
+	for _, key := range keys {
+		if _, ok := key.(*sdk.KVStoreKey); !ok {
+			panic(fmt.Sprintf("cannot add streaming listeners for non-persistent store %s", key.Name()))
+		}
+	}
+
+	app.speculativeKeys = append(app.speculativeKeys, keys...)
+	app.speculativeListeners = append(app.speculativeListeners, listeners...)
+}
+
+// speculativeWriteBuffer returns the buffer recording the speculative writes
+// of a transaction run in the given mode, nil if they are not streamed.
+func (app *BaseApp) speculativeWriteBuffer(mode runTxMode) *txWriteBuffer {
+	if mode == runTxModeDeliver || len(app.speculativeListeners) == 0 {
+		return nil
+	}
+
+	return &txWriteBuffer{}
+}
+
+// streamSpeculativeTxStateChanges passes the buffered writes of a checked or
+// simulated transaction to the SpeculativeListeners.
+func (app *BaseApp) streamSpeculativeTxStateChanges(ctx sdk.Context, mode runTxMode, txBytes []byte, buf *txWriteBuffer, err error) {
+	changes := SpeculativeTxStateChanges{
+		TxHash: tmhash.Sum(txBytes),
+		Writes: buf.flush(),
+		Err:    err,
+	}
+
+	switch mode {
+	case runTxModeReCheck:
+		changes.Mode = SpeculativeModeReCheck
+	case runTxModeSimulate:
+		changes.Mode = SpeculativeModeSimulate
+	default:
+		changes.Mode = SpeculativeModeCheck
+	}
+
+	for _, l := range app.speculativeListeners {
+		l.OnSpeculativeTxStateChanges(ctx, changes)
+	}
+}
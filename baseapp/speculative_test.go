@@ -0,0 +1,92 @@
+package baseapp
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+type mockSpeculativeListener struct {
+	mtx     sync.Mutex
+	changes []SpeculativeTxStateChanges
+}
+
+func (l *mockSpeculativeListener) OnSpeculativeTxStateChanges(_ sdk.Context, changes SpeculativeTxStateChanges) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.changes = append(l.changes, changes)
+}
+
+func TestAddSpeculativeStreamingListeners(t *testing.T) {
+	anteKey := []byte("ante-key")
+	anteOpt := func(bapp *BaseApp) { bapp.SetAnteHandler(anteHandlerTxTest(t, capKey1, anteKey)) }
+
+	deliverKey := []byte("deliver-key")
+	routerOpt := func(bapp *BaseApp) {
+		r := sdk.NewRoute(routeMsgCounter, handlerMsgCounter(t, capKey1, deliverKey))
+		bapp.Router().AddRoute(r)
+	}
+
+	canonical := newMockWriteListener()
+	speculative := &mockSpeculativeListener{}
+	streamingOpt := func(bapp *BaseApp) {
+		bapp.AddStreamingListeners(capKey1, canonical)
+		bapp.AddSpeculativeStreamingListeners([]sdk.StoreKey{capKey1}, speculative)
+	}
+
+	app := setupBaseApp(t, anteOpt, routerOpt, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+
+	// CheckTx only runs the AnteHandler
+	checkTx, err := codec.MarshalBinaryBare(newTxCounter(0, 0))
+	require.NoError(t, err)
+	res := app.CheckTx(abci.RequestCheckTx{Tx: checkTx})
+	require.True(t, res.IsOK(), res.Log)
+
+	// simulations run on the check state, including the messages
+	simulateTx, err := codec.MarshalBinaryBare(newTxCounter(1, 0))
+	require.NoError(t, err)
+	_, _, err = app.Simulate(simulateTx)
+	require.NoError(t, err)
+
+	// the writes of failed steps are discarded
+	failing := newTxCounter(1, 0)
+	failing.FailOnAnte = true
+	failingTx, err := codec.MarshalBinaryBare(failing)
+	require.NoError(t, err)
+	res = app.CheckTx(abci.RequestCheckTx{Tx: failingTx, Type: abci.CheckTxType_Recheck})
+	require.False(t, res.IsOK())
+
+	require.Len(t, speculative.changes, 3)
+
+	keys := func(writes []StoreKVPair) []string {
+		keys := make([]string, len(writes))
+		for i, kv := range writes {
+			keys[i] = kv.StoreKey.Name() + "/" + string(kv.Key)
+		}
+		return keys
+	}
+
+	require.Equal(t, SpeculativeModeCheck, speculative.changes[0].Mode)
+	require.Equal(t, []string{capKey1.Name() + "/ante-key"}, keys(speculative.changes[0].Writes))
+	require.NoError(t, speculative.changes[0].Err)
+
+	require.Equal(t, SpeculativeModeSimulate, speculative.changes[1].Mode)
+	require.Equal(t, []string{capKey1.Name() + "/ante-key", capKey1.Name() + "/deliver-key"}, keys(speculative.changes[1].Writes))
+
+	require.Equal(t, SpeculativeModeReCheck, speculative.changes[2].Mode)
+	require.Empty(t, speculative.changes[2].Writes)
+	require.Error(t, speculative.changes[2].Err)
+
+	// speculative writes never reach the canonical stream
+	require.Empty(t, canonical.writes)
+}
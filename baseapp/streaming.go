@@ -0,0 +1,1224 @@
+package baseapp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto/tmhash"
+
+	"github.com/cosmos/cosmos-sdk/store"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ABCIListener is the interface for streaming the consensus data and events
+// processed by the BaseApp alongside the state changes passed to the
+// WriteListeners.
+//
+// Listeners are called synchronously from within the ABCI methods, in the
+// order in which Tendermint delivers them, and must not modify the provided
+// requests and responses. State changes are only passed to the WriteListeners
+// on Commit, so for a given block all of its ABCI messages are passed to the
+// ABCIListeners before its state changes are streamed.
+type ABCIListener interface {
+	// ListenBeginBlock is called once BeginBlock has been executed. The request
+	// carries the evidence of byzantine validators and the response carries the
+	// events emitted by the BeginBlocker, including slashing events.
+	ListenBeginBlock(ctx sdk.Context, req abci.RequestBeginBlock, res abci.ResponseBeginBlock)
+
+	// ListenEndBlock is called once EndBlock has been executed. The response
+	// carries the validator set and consensus param updates of the block.
+	ListenEndBlock(ctx sdk.Context, req abci.RequestEndBlock, res abci.ResponseEndBlock)
+
+	// ListenDeliverTx is called once a transaction has been delivered, whether
+	// or not it succeeded. The response carries the events emitted while
+	// executing the transaction.
+	ListenDeliverTx(ctx sdk.Context, req abci.RequestDeliverTx, res abci.ResponseDeliverTx)
+}
+
+// StoreKVPair is a single write to a KVStore. A nil Value signals that the
+// key was deleted.
+type StoreKVPair struct {
+	StoreKey sdk.StoreKey
+	Key      []byte
+	Value    []byte
+}
+
+// TxStateChanges groups the writes a delivered transaction made to the
+// KVStores it is streamed for, in the order they were written.
+type TxStateChanges struct {
+	TxHash []byte
+	Msgs   []sdk.Msg
+	Writes []StoreKVPair
+}
+
+// TxStateChangesListener is the interface for streaming state changes grouped
+// per transaction. OnTxStateChanges is called after every DeliverTx with the
+// writes the transaction made, which is empty if it failed in the AnteHandler.
+// Writes of failed messages are never included as they are discarded.
+type TxStateChangesListener interface {
+	OnTxStateChanges(ctx sdk.Context, changes TxStateChanges)
+}
+
+// txWriteBuffer is a WriteListener which buffers the writes of the
+// transaction being delivered.
+type txWriteBuffer struct {
+	writes []StoreKVPair
+}
+
+// OnWrite implements the WriteListener interface.
+func (b *txWriteBuffer) OnWrite(storeKey sdk.StoreKey, key []byte, value []byte) {
+	b.writes = append(b.writes, StoreKVPair{StoreKey: storeKey, Key: key, Value: value})
+}
+
+// flush returns the buffered writes and resets the buffer.
+func (b *txWriteBuffer) flush() []StoreKVPair {
+	writes := b.writes
+	b.writes = nil
+	return writes
+}
+
+// CommitListener is implemented by WriteListeners which are notified once
+// every write of a committed block has been passed to them, e.g. to batch the
+// writes per block. In async mode OnCommit is called from the dispatching
+// goroutine.
+type CommitListener interface {
+	OnCommit(height int64)
+}
+
+// GenesisWriteListener is implemented by WriteListeners which tell apart the
+// writes of the genesis state. With the SetStreamGenesis option, the writes
+// committed with the first block which were made by InitChain, i.e. by the
+// InitGenesis of the modules, are passed to OnGenesisWrite instead of OnWrite.
+// Keys written by InitChain and then overwritten in the first block are
+// passed to OnWrite with their final value.
+type GenesisWriteListener interface {
+	store.WriteListener
+	OnGenesisWrite(storeKey sdk.StoreKey, key []byte, value []byte)
+}
+
+// genesisState records the writes made by InitChain to the streamed stores,
+// by store name and key.
+type genesisState map[string][]byte
+
+// OnWrite implements the WriteListener interface.
+func (g genesisState) OnWrite(storeKey sdk.StoreKey, key []byte, value []byte) {
+	g[storeKey.Name()+"/"+string(key)] = value
+}
+
+// has returns true if the write is part of the genesis state.
+func (g genesisState) has(kv StoreKVPair) bool {
+	value, ok := g[kv.StoreKey.Name()+"/"+string(kv.Key)]
+	return ok && bytes.Equal(value, kv.Value)
+}
+
+// listenableMultiStore is implemented by cache multi-stores that can pass the
+// writes flushed from their cache-wraps to WriteListeners.
+type listenableMultiStore interface {
+	CacheMultiStoreWithListeners(listeners map[sdk.StoreKey][]store.WriteListener) sdk.CacheMultiStore
+}
+
+// streamingAsyncBacklog is the number of committed blocks whose writes may be
+// pending dispatch in async mode before Commit blocks on the listeners.
+const streamingAsyncBacklog = 16
+
+// streamingDispatcher is the WriteListener registered with the
+// CommitMultiStore for the stores streamed through AddStreamingListeners. It
+// passes every write to the listeners of its store, either synchronously or,
+// in async mode, by staging the writes of each block and dispatching them on
+// a background goroutine once the block is committed, so the listeners can't
+// slow down Commit.
+type streamingDispatcher struct {
+	listeners map[sdk.StoreKey][]store.WriteListener
+	// samplers hold the sampler of each listener, nil for those passed every
+	// write
+	samplers map[sdk.StoreKey][]*streamingSampler
+	// commitListeners are the listeners implementing CommitListener, each
+	// registered once
+	commitListeners []CommitListener
+	// async is 1 while the writes are dispatched asynchronously, accessed
+	// atomically
+	async int32
+
+	// mtx serializes the dispatch of the committed blocks with that of the
+	// writes replayed by StreamState and Backfill, so they are never
+	// interleaved. It is held for the whole commit of a block dispatched
+	// synchronously, and for every batch dispatched asynchronously.
+	mtx sync.Mutex
+	// inBlock is true while the block being committed holds mtx
+	inBlock bool
+	// commitMtx is held while a block is committed, so the replays read the
+	// versions of the stores between two commits. It is acquired after mtx.
+	commitMtx sync.Mutex
+	// height is the height of the block being committed, whose writes are
+	// passed synchronously
+	height int64
+
+	staged  []StoreKVPair
+	genesis genesisState
+	batches chan streamingBatch
+	done    chan struct{}
+	// batchesMtx serializes the queueing of the batches with the closing of
+	// their queue by drain, and guards drained
+	batchesMtx sync.Mutex
+	drained    bool
+	// quit is closed once the dispatch is cancelled, dropping the batches
+	// pending dispatch
+	quit     chan struct{}
+	quitOnce sync.Once
+
+	// lastHeight is the height of the last block whose writes were all
+	// passed to the listeners, accessed atomically
+	lastHeight int64
+	// ackedHeight is the height up to which the streamed writes were
+	// acknowledged as durably delivered, accessed atomically
+	ackedHeight int64
+}
+
+// streamingReader is registered with the CommitMultiStore for the stores
+// streamed to WriteListeners which also implement ReadListener. It passes
+// every read of its store to these listeners synchronously, while their
+// writes are passed through the streamingDispatcher.
+//
+// Reads are only observed once they reach the committed stores, i.e. on
+// cache misses, and may be made concurrently from CheckTx, DeliverTx and
+// simulations.
+type streamingReader struct {
+	listeners []store.ReadListener
+}
+
+// OnWrite implements the WriteListener interface. Writes are ignored.
+func (r *streamingReader) OnWrite(sdk.StoreKey, []byte, []byte) {}
+
+// OnRead implements the ReadListener interface.
+func (r *streamingReader) OnRead(storeKey sdk.StoreKey, key []byte, value []byte) {
+	for _, l := range r.listeners {
+		l.OnRead(storeKey, key, value)
+	}
+}
+
+// streamingBatch holds the writes of a committed block pending dispatch.
+type streamingBatch struct {
+	height  int64
+	writes  []StoreKVPair
+	genesis genesisState
+}
+
+func newStreamingDispatcher() *streamingDispatcher {
+	return &streamingDispatcher{
+		listeners: make(map[sdk.StoreKey][]store.WriteListener),
+		samplers:  make(map[sdk.StoreKey][]*streamingSampler),
+		quit:      make(chan struct{}),
+	}
+}
+
+// isAsync returns true if the writes are dispatched asynchronously.
+func (d *streamingDispatcher) isAsync() bool {
+	return atomic.LoadInt32(&d.async) == 1
+}
+
+// cancelled returns true once the dispatch is cancelled.
+func (d *streamingDispatcher) cancelled() bool {
+	select {
+	case <-d.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// OnWrite implements the WriteListener interface.
+func (d *streamingDispatcher) OnWrite(storeKey sdk.StoreKey, key []byte, value []byte) {
+	if d.isAsync() {
+		d.staged = append(d.staged, StoreKVPair{StoreKey: storeKey, Key: key, Value: value})
+		return
+	}
+
+	if !d.inBlock {
+		d.mtx.Lock()
+		defer d.mtx.Unlock()
+	}
+
+	d.dispatch(StoreKVPair{StoreKey: storeKey, Key: key, Value: value}, d.genesis, d.height)
+}
+
+// dispatch passes a write streamed at the given height to the listeners of
+// its store, skipping the sampled listeners it is not sampled for. If the
+// write is part of the given genesis state, it is passed to the
+// GenesisWriteListeners through OnGenesisWrite.
+func (d *streamingDispatcher) dispatch(kv StoreKVPair, genesis genesisState, height int64) {
+	isGenesis := genesis != nil && genesis.has(kv)
+
+	for i, l := range d.listeners[kv.StoreKey] {
+		if !d.samplers[kv.StoreKey][i].sample(kv, height) {
+			continue
+		}
+
+		if gl, ok := l.(GenesisWriteListener); ok && isGenesis {
+			gl.OnGenesisWrite(kv.StoreKey, kv.Key, kv.Value)
+			continue
+		}
+
+		l.OnWrite(kv.StoreKey, kv.Key, kv.Value)
+	}
+}
+
+// start starts the goroutine dispatching the writes staged in async mode.
+func (d *streamingDispatcher) start() {
+	if d.batches != nil {
+		return
+	}
+
+	d.batches = make(chan streamingBatch, streamingAsyncBacklog)
+	d.done = make(chan struct{})
+
+	go func() {
+		defer close(d.done)
+
+		for batch := range d.batches {
+			// the batches queued once cancelled are dropped until the queue
+			// is closed
+			if d.cancelled() {
+				continue
+			}
+
+			start := time.Now()
+			d.mtx.Lock()
+			for _, kv := range batch.writes {
+				if d.cancelled() {
+					break
+				}
+
+				d.dispatch(kv, batch.genesis, batch.height)
+			}
+			if !d.cancelled() {
+				d.commit(batch.height)
+				atomic.StoreInt64(&d.lastHeight, batch.height)
+			}
+			d.mtx.Unlock()
+			telemetry.MeasureSince(start, "streaming", "dispatch")
+		}
+	}()
+}
+
+// beginCommit is called before the writes of the block at the given height
+// are committed. It holds the commit lock of the dispatcher until the block is
+// flushed and, in sync mode, its dispatch lock too, so the writes of the block
+// are dispatched at once.
+func (d *streamingDispatcher) beginCommit(height int64) {
+	d.height = height
+
+	if !d.isAsync() {
+		d.mtx.Lock()
+		d.inBlock = true
+	}
+
+	d.commitMtx.Lock()
+}
+
+// flush queues the writes staged in async mode for dispatch. It is called
+// once the block at the given height is committed.
+func (d *streamingDispatcher) flush(height int64) {
+	genesis := d.genesis
+	d.genesis = nil
+
+	// the mode can't change while the block holds the commit lock
+	if !d.isAsync() {
+		d.commitMtx.Unlock()
+
+		// the block began before the dispatcher was drained
+		if !d.inBlock {
+			d.mtx.Lock()
+		}
+
+		d.commit(height)
+		atomic.StoreInt64(&d.lastHeight, height)
+
+		d.inBlock = false
+		d.mtx.Unlock()
+
+		return
+	}
+
+	// the batch is queued before the commit lock is released, so drain can't
+	// close the queue in between
+	d.batchesMtx.Lock()
+	defer d.batchesMtx.Unlock()
+
+	d.commitMtx.Unlock()
+
+	d.batches <- streamingBatch{height: height, writes: d.staged, genesis: genesis}
+	d.staged = nil
+
+	telemetry.SetGauge(float32(len(d.batches)), "streaming", "pending_blocks")
+}
+
+// commit notifies the CommitListeners that every write of the block at the
+// given height was passed to them.
+func (d *streamingDispatcher) commit(height int64) {
+	for _, l := range d.commitListeners {
+		l.OnCommit(height)
+	}
+}
+
+// addCommitListener registers the listener as a CommitListener if it
+// implements the interface and isn't registered yet.
+func (d *streamingDispatcher) addCommitListener(l store.WriteListener) {
+	cl, ok := l.(CommitListener)
+	if !ok {
+		return
+	}
+
+	if reflect.TypeOf(cl).Comparable() {
+		for _, registered := range d.commitListeners {
+			if registered == cl {
+				return
+			}
+		}
+	}
+
+	d.commitListeners = append(d.commitListeners, cl)
+}
+
+// pending returns the number of committed blocks whose writes are pending
+// dispatch.
+func (d *streamingDispatcher) pending() int {
+	if d.batches == nil {
+		return 0
+	}
+
+	return len(d.batches)
+}
+
+// drain waits for every queued write to be dispatched, unless the dispatch
+// is cancelled, and stops the dispatching goroutine. Writes passed to the
+// dispatcher afterwards are dispatched synchronously.
+func (d *streamingDispatcher) drain() {
+	// the mode is switched between two blocks, so the writes staged for a
+	// block are always queued
+	d.commitMtx.Lock()
+	atomic.StoreInt32(&d.async, 0)
+
+	d.batchesMtx.Lock()
+	closing := d.batches != nil && !d.drained
+	if closing {
+		close(d.batches)
+		d.drained = true
+	}
+	d.batchesMtx.Unlock()
+	d.commitMtx.Unlock()
+
+	if closing {
+		<-d.done
+	}
+}
+
+// cancel cancels the dispatch of the writes pending asynchronous dispatch,
+// letting drain return once the write being dispatched, if any, returns.
+func (d *streamingDispatcher) cancel() {
+	d.quitOnce.Do(func() { close(d.quit) })
+}
+
+// dispatchingAsync returns true while the writes are dispatched asynchronously by the
+// dispatching goroutine.
+func (d *streamingDispatcher) dispatchingAsync() bool {
+	d.batchesMtx.Lock()
+	defer d.batchesMtx.Unlock()
+
+	return d.batches != nil && !d.drained
+}
+
+// setStreamingAsync sets whether the writes passed to the WriteListeners
+// registered through AddStreamingListeners are dispatched asynchronously.
+func (app *BaseApp) setStreamingAsync(async bool) {
+	if !async {
+		atomic.StoreInt32(&app.streamingDispatcher.async, 0)
+		return
+	}
+
+	atomic.StoreInt32(&app.streamingDispatcher.async, 1)
+	app.streamingDispatcher.start()
+}
+
+// setStreamGenesis sets whether the writes of the genesis state are passed to
+// the GenesisWriteListeners through OnGenesisWrite.
+func (app *BaseApp) setStreamGenesis(streamGenesis bool) {
+	app.streamGenesis = streamGenesis
+}
+
+// pruningGuardSetter is implemented by multi-stores whose pruning can be held
+// back (e.g. rootmulti.Store).
+type pruningGuardSetter interface {
+	SetPruningGuard(guard func() int64)
+}
+
+// setStreamingRetention sets whether the multi-store retains the heights
+// above the last one acknowledged through AcknowledgeStreamedHeight.
+func (app *BaseApp) setStreamingRetention(retain bool) {
+	gs, ok := app.cms.(pruningGuardSetter)
+	if !ok {
+		if retain {
+			app.logger.Error("streaming retention is disabled as the multi-store does not support pruning guards")
+		}
+
+		return
+	}
+
+	if !retain {
+		gs.SetPruningGuard(nil)
+		return
+	}
+
+	gs.SetPruningGuard(func() int64 {
+		return atomic.LoadInt64(&app.streamingDispatcher.ackedHeight)
+	})
+}
+
+// AcknowledgeStreamedHeight records that the state changes of every block up
+// to the given height were durably delivered or archived by the streaming
+// consumers. With the SetStreamingRetention option, no height above the last
+// acknowledged one is pruned, so a consumer gap can always be backfilled.
+// Acknowledgements are capped at the height of the last streamed block, never
+// move backwards and are not persisted: after a restart nothing is pruned
+// until a height is acknowledged again. It is safe to call concurrently with
+// block processing.
+func (app *BaseApp) AcknowledgeStreamedHeight(height int64) {
+	if streamed := atomic.LoadInt64(&app.streamingDispatcher.lastHeight); height > streamed {
+		height = streamed
+	}
+
+	for {
+		acked := atomic.LoadInt64(&app.streamingDispatcher.ackedHeight)
+		if height <= acked || atomic.CompareAndSwapInt64(&app.streamingDispatcher.ackedHeight, acked, height) {
+			return
+		}
+	}
+}
+
+// StreamingStatus reports the state of the streaming of a BaseApp.
+type StreamingStatus struct {
+	// Stores are the names of the stores streamed to WriteListeners.
+	Stores []string `json:"stores"`
+	// Listeners is the number of registered listeners of any kind.
+	Listeners int `json:"listeners"`
+	// Async is true if writes are dispatched asynchronously.
+	Async bool `json:"async"`
+	// LastStreamedHeight is the height of the last block whose writes were all
+	// passed to the WriteListeners.
+	LastStreamedHeight int64 `json:"last_streamed_height"`
+	// PendingBlocks is the number of committed blocks whose writes are pending
+	// asynchronous dispatch.
+	PendingBlocks int `json:"pending_blocks"`
+	// AcknowledgedHeight is the last height acknowledged through
+	// AcknowledgeStreamedHeight.
+	AcknowledgedHeight int64 `json:"acknowledged_height"`
+	// ShedBlocks is the number of committed blocks shed by the best-effort
+	// listeners because their backlog was full.
+	ShedBlocks uint64 `json:"shed_blocks"`
+	// ShedWrites is the number of writes of the shed blocks.
+	ShedWrites uint64 `json:"shed_writes"`
+	// Errors are the errors reported by the listeners implementing
+	// `Err() error`, such as FrameWriteListener.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// StreamingStatus returns the StreamingStatus of the BaseApp. It is safe to
+// call concurrently with block processing.
+func (app *BaseApp) StreamingStatus() StreamingStatus {
+	app.streamingMtx.Lock()
+	defer app.streamingMtx.Unlock()
+
+	listeners := len(app.streamingListeners) + len(app.abciListeners) + len(app.txListeners) + len(app.summaryListeners) +
+		len(app.speculativeListeners) + len(app.proofListeners) + len(app.lifecycleListeners)
+
+	status := StreamingStatus{
+		Stores:             make([]string, 0, len(app.streamingDispatcher.listeners)),
+		Listeners:          listeners,
+		Async:              app.streamingDispatcher.dispatchingAsync(),
+		LastStreamedHeight: atomic.LoadInt64(&app.streamingDispatcher.lastHeight),
+		PendingBlocks:      app.streamingDispatcher.pending(),
+		AcknowledgedHeight: atomic.LoadInt64(&app.streamingDispatcher.ackedHeight),
+	}
+
+	status.ShedBlocks, status.ShedWrites = app.shedStreamingWrites()
+
+	for key := range app.streamingDispatcher.listeners {
+		status.Stores = append(status.Stores, key.Name())
+	}
+	sort.Strings(status.Stores)
+
+	for _, l := range app.streamingListeners {
+		if e, ok := l.(interface{ Err() error }); ok {
+			if err := e.Err(); err != nil {
+				status.Errors = append(status.Errors, err.Error())
+			}
+		}
+	}
+
+	return status
+}
+
+// AddStreamingListeners registers WriteListeners for the KVStore mounted under
+// the provided key. Every write committed to that store is passed to the
+// listeners in the order it is written to the underlying store. Listeners
+// which also implement ReadListener are passed the reads of the store.
+//
+// Listeners can only be registered for persistent stores and must be
+// registered before the BaseApp is sealed, i.e. before the latest version is
+// loaded and InitChain is called. It is safe to call concurrently.
+func (app *BaseApp) AddStreamingListeners(key sdk.StoreKey, listeners ...store.WriteListener) {
+	app.addStreamingListeners(key, nil, listeners, false)
+}
+
+// AddEphemeralStreamingListeners registers WriteListeners for the transient or
+// memory store mounted under the provided key, e.g. to observe the data modules
+// stage there while debugging. The writes flushed to the store are passed to
+// the listeners like the writes to the persistent stores, and sinks can tell
+// them apart with storetypes.IsEphemeralStoreKey, but the reset of transient
+// stores on Commit is not streamed. Ephemeral stores are not part of the state
+// streamed by StreamState and Backfill.
+//
+// Like AddStreamingListeners, it must be called before the BaseApp is sealed
+// and it is safe to call concurrently. It panics if the store is persistent.
+func (app *BaseApp) AddEphemeralStreamingListeners(key sdk.StoreKey, listeners ...store.WriteListener) {
+	if !storetypes.IsEphemeralStoreKey(key) {
+		panic(fmt.Sprintf("cannot add ephemeral streaming listeners for persistent store %s", key.Name()))
+	}
+
+	app.addStreamingListeners(key, nil, listeners, true)
+}
+
+// addStreamingListeners registers WriteListeners passed the writes sampled by
+// the given sampler, or every write if it is nil. Ephemeral stores may only
+// be streamed if ephemeral is true.
+func (app *BaseApp) addStreamingListeners(key sdk.StoreKey, sampler *streamingSampler, listeners []store.WriteListener, ephemeral bool) {
+	app.streamingMtx.Lock()
+	defer app.streamingMtx.Unlock()
+
+	if app.sealed {
+		panic("AddStreamingListeners() on sealed BaseApp")
+	}
+
+	if _, ok := key.(*sdk.KVStoreKey); !ok && !(ephemeral && storetypes.IsEphemeralStoreKey(key)) {
+		panic(fmt.Sprintf("cannot add streaming listeners for non-persistent store %s", key.Name()))
+	}
+
+	if len(app.streamingDispatcher.listeners[key]) == 0 {
+		app.cms.AddListeners(key, []store.WriteListener{app.streamingDispatcher})
+	}
+
+	for _, l := range listeners {
+		if rl, ok := l.(store.ReadListener); ok {
+			app.streamingReader(key).listeners = append(app.streamingReader(key).listeners, rl)
+		}
+	}
+
+	app.streamingDispatcher.listeners[key] = append(app.streamingDispatcher.listeners[key], listeners...)
+	for _, l := range listeners {
+		app.streamingDispatcher.samplers[key] = append(app.streamingDispatcher.samplers[key], sampler)
+		app.streamingDispatcher.addCommitListener(l)
+	}
+	app.streamingListeners = append(app.streamingListeners, listeners...)
+}
+
+// streamingReader returns the streamingReader of the store mounted under the
+// given key, registering it with the CommitMultiStore if needed.
+func (app *BaseApp) streamingReader(key sdk.StoreKey) *streamingReader {
+	if app.streamingReaders == nil {
+		app.streamingReaders = make(map[sdk.StoreKey]*streamingReader)
+	}
+
+	r, ok := app.streamingReaders[key]
+	if !ok {
+		r = &streamingReader{}
+		app.streamingReaders[key] = r
+		app.cms.AddListeners(key, []store.WriteListener{r})
+	}
+
+	return r
+}
+
+// AddStreamingABCIListeners registers ABCIListeners with the BaseApp. Like
+// AddStreamingListeners, it must be called before the BaseApp is sealed and it
+// is safe to call concurrently.
+func (app *BaseApp) AddStreamingABCIListeners(listeners ...ABCIListener) {
+	app.streamingMtx.Lock()
+	defer app.streamingMtx.Unlock()
+
+	if app.sealed {
+		panic("AddStreamingABCIListeners() on sealed BaseApp")
+	}
+
+	app.abciListeners = append(app.abciListeners, listeners...)
+}
+
+// AddStreamingTxListeners registers TxStateChangesListeners with the BaseApp.
+// The writes every delivered transaction makes to the stores mounted under
+// the provided keys are buffered and passed to the listeners as a single
+// TxStateChanges after the transaction is delivered, instead of as individual
+// writes on Commit.
+//
+// Like AddStreamingListeners, it must be called before the BaseApp is sealed
+// and it is safe to call concurrently.
+func (app *BaseApp) AddStreamingTxListeners(keys []sdk.StoreKey, listeners ...TxStateChangesListener) {
+	app.streamingMtx.Lock()
+	defer app.streamingMtx.Unlock()
+
+	if app.sealed {
+		panic("AddStreamingTxListeners() on sealed BaseApp")
+	}
+
+	if app.txWriteBuffer == nil {
+		app.txWriteBuffer = &txWriteBuffer{}
+		app.txWriteListeners = make(map[sdk.StoreKey][]store.WriteListener)
+	}
+
+	for _, key := range keys {
+		if _, ok := key.(*sdk.KVStoreKey); !ok {
+			panic(fmt.Sprintf("cannot add streaming listeners for non-persistent store %s", key.Name()))
+		}
+
+		app.txWriteListeners[key] = []store.WriteListener{app.txWriteBuffer}
+	}
+
+	app.txListeners = append(app.txListeners, listeners...)
+}
+
+// cacheTxMultiStore cache-wraps the provided multi-store for the execution of
+// a transaction. When delivering a transaction with TxStateChangesListeners
+// registered, the writes flushed from the returned store are buffered so they
+// can be streamed once the transaction has been delivered. When checking or
+// simulating a transaction with a speculative buffer, they are buffered in it.
+func (app *BaseApp) cacheTxMultiStore(ms sdk.MultiStore, mode runTxMode, speculative *txWriteBuffer) sdk.CacheMultiStore {
+	if mode == runTxModeDeliver && len(app.txListeners) > 0 {
+		if lms, ok := ms.(listenableMultiStore); ok {
+			return lms.CacheMultiStoreWithListeners(app.txWriteListeners)
+		}
+	}
+
+	if speculative != nil {
+		if lms, ok := ms.(listenableMultiStore); ok {
+			listeners := make(map[sdk.StoreKey][]store.WriteListener, len(app.speculativeKeys))
+			for _, key := range app.speculativeKeys {
+				listeners[key] = []store.WriteListener{speculative}
+			}
+
+			return lms.CacheMultiStoreWithListeners(listeners)
+		}
+	}
+
+	return ms.CacheMultiStore()
+}
+
+// initChainMultiStore returns the multi-store the InitChainer writes the
+// genesis state to. With genesis streaming enabled, it is a cache-wrap of the
+// deliver state recording the writes to the streamed stores, which must be
+// written back once the InitChainer returns.
+func (app *BaseApp) initChainMultiStore() sdk.CacheMultiStore {
+	if !app.streamGenesis || len(app.streamingDispatcher.listeners) == 0 {
+		return nil
+	}
+
+	lms, ok := app.deliverState.ms.(listenableMultiStore)
+	if !ok {
+		return nil
+	}
+
+	genesis := make(genesisState)
+	listeners := make(map[sdk.StoreKey][]store.WriteListener, len(app.streamingDispatcher.listeners))
+	for key := range app.streamingDispatcher.listeners {
+		listeners[key] = []store.WriteListener{genesis}
+	}
+
+	app.streamingDispatcher.genesis = genesis
+	return lms.CacheMultiStoreWithListeners(listeners)
+}
+
+// streamTxStateChanges passes the buffered writes of the delivered
+// transaction to the TxStateChangesListeners.
+func (app *BaseApp) streamTxStateChanges(txBytes []byte) {
+	if len(app.txListeners) == 0 {
+		return
+	}
+
+	defer telemetry.MeasureSince(time.Now(), "streaming", "tx_listeners")
+
+	changes := TxStateChanges{
+		TxHash: tmhash.Sum(txBytes),
+		Writes: app.txWriteBuffer.flush(),
+	}
+
+	if tx, err := app.txDecoder(txBytes); err == nil {
+		changes.Msgs = tx.GetMsgs()
+	}
+
+	for _, l := range app.txListeners {
+		l.OnTxStateChanges(app.deliverState.ctx, changes)
+	}
+}
+
+// StoreWriteSummary summarizes the writes committed to a single KVStore in a
+// block. Prefixes holds the distinct first bytes of the written keys, which
+// by convention identify the kind of record stored under them, in ascending
+// order.
+type StoreWriteSummary struct {
+	StoreKey sdk.StoreKey
+	Writes   uint64
+	Deletes  uint64
+	Bytes    uint64
+	Prefixes []byte
+}
+
+// BlockStateSummary summarizes the state changes committed in a block.
+type BlockStateSummary struct {
+	Height int64
+	Stores []StoreWriteSummary
+}
+
+// BlockSummaryListener is the interface for streaming a summary of the state
+// changes of every block, without consuming the individual writes.
+type BlockSummaryListener interface {
+	OnBlockSummary(summary BlockStateSummary)
+}
+
+// blockSummary is a WriteListener accumulating the StoreWriteSummary of every
+// store it listens to.
+type blockSummary struct {
+	stores   map[sdk.StoreKey]*StoreWriteSummary
+	prefixes map[sdk.StoreKey]map[byte]struct{}
+}
+
+func newBlockSummary() *blockSummary {
+	return &blockSummary{
+		stores:   make(map[sdk.StoreKey]*StoreWriteSummary),
+		prefixes: make(map[sdk.StoreKey]map[byte]struct{}),
+	}
+}
+
+// OnWrite implements the WriteListener interface.
+func (b *blockSummary) OnWrite(storeKey sdk.StoreKey, key []byte, value []byte) {
+	summary, ok := b.stores[storeKey]
+	if !ok {
+		summary = &StoreWriteSummary{StoreKey: storeKey}
+		b.stores[storeKey] = summary
+		b.prefixes[storeKey] = make(map[byte]struct{})
+	}
+
+	if value == nil {
+		summary.Deletes++
+	} else {
+		summary.Writes++
+	}
+
+	summary.Bytes += uint64(len(key) + len(value))
+	if len(key) > 0 {
+		b.prefixes[storeKey][key[0]] = struct{}{}
+	}
+}
+
+// flush returns the summary of the accumulated writes, ordered by store name,
+// and resets the accumulator.
+func (b *blockSummary) flush(height int64) BlockStateSummary {
+	summary := BlockStateSummary{Height: height, Stores: make([]StoreWriteSummary, 0, len(b.stores))}
+	for key, s := range b.stores {
+		for prefix := range b.prefixes[key] {
+			s.Prefixes = append(s.Prefixes, prefix)
+		}
+		sort.Slice(s.Prefixes, func(i, j int) bool { return s.Prefixes[i] < s.Prefixes[j] })
+
+		summary.Stores = append(summary.Stores, *s)
+	}
+	sort.Slice(summary.Stores, func(i, j int) bool {
+		return summary.Stores[i].StoreKey.Name() < summary.Stores[j].StoreKey.Name()
+	})
+
+	b.stores = make(map[sdk.StoreKey]*StoreWriteSummary)
+	b.prefixes = make(map[sdk.StoreKey]map[byte]struct{})
+
+	return summary
+}
+
+// AddStreamingSummaryListeners registers BlockSummaryListeners with the
+// BaseApp. On every Commit the listeners are passed a summary of the writes
+// committed to the stores mounted under the provided keys. Stores which were
+// not written to in the block are omitted from the summary.
+//
+// Like AddStreamingListeners, it must be called before the BaseApp is sealed
+// and it is safe to call concurrently.
+func (app *BaseApp) AddStreamingSummaryListeners(keys []sdk.StoreKey, listeners ...BlockSummaryListener) {
+	app.streamingMtx.Lock()
+	defer app.streamingMtx.Unlock()
+
+	if app.sealed {
+		panic("AddStreamingSummaryListeners() on sealed BaseApp")
+	}
+
+	if app.blockSummary == nil {
+		app.blockSummary = newBlockSummary()
+		app.summarizedKeys = make(map[sdk.StoreKey]bool)
+	}
+
+	for _, key := range keys {
+		if _, ok := key.(*sdk.KVStoreKey); !ok {
+			panic(fmt.Sprintf("cannot add streaming listeners for non-persistent store %s", key.Name()))
+		}
+
+		if !app.summarizedKeys[key] {
+			app.cms.AddListeners(key, []store.WriteListener{app.blockSummary})
+			app.summarizedKeys[key] = true
+		}
+	}
+
+	app.summaryListeners = append(app.summaryListeners, listeners...)
+}
+
+// streamBlockSummary passes the summary of the writes committed in the block
+// at the given height to the BlockSummaryListeners.
+func (app *BaseApp) streamBlockSummary(height int64) {
+	if len(app.summaryListeners) == 0 {
+		return
+	}
+
+	defer telemetry.MeasureSince(time.Now(), "streaming", "summary_listeners")
+
+	summary := app.blockSummary.flush(height)
+	for _, l := range app.summaryListeners {
+		l.OnBlockSummary(summary)
+	}
+}
+
+// StreamState passes every key-value pair of the stores streamed through
+// AddStreamingListeners, as of the given height, to their WriteListeners as if
+// it had just been written. It produces a full-state baseline in the same
+// format as the live stream. A non-positive height streams the latest state.
+//
+// The state is passed to the listeners at once, between the writes of two
+// blocks, so the dispatch of the committed blocks is held back for the whole
+// call. In sync mode, Commit blocks until the whole state has been streamed;
+// in async mode, it blocks once streamingAsyncBacklog committed blocks are
+// pending dispatch. Running it on a live node therefore stalls the chain for
+// as long as it takes to stream the state.
+func (app *BaseApp) StreamState(height int64) error {
+	app.streamingMtx.Lock()
+	defer app.streamingMtx.Unlock()
+
+	// the state is passed between the blocks being streamed, and loaded
+	// between the blocks being committed. Streaming it in chunks between the
+	// blocks instead would let the chunks overwrite the writes of the later
+	// blocks streamed in between.
+	app.streamingDispatcher.mtx.Lock()
+	defer app.streamingDispatcher.mtx.Unlock()
+
+	app.streamingDispatcher.commitMtx.Lock()
+	lastHeight := app.LastBlockHeight()
+	if height <= 0 {
+		height = lastHeight
+	} else if height > lastHeight {
+		app.streamingDispatcher.commitMtx.Unlock()
+		return fmt.Errorf("cannot stream state at height %d above the latest height %d", height, lastHeight)
+	}
+
+	cms, err := app.cms.CacheMultiStoreWithVersion(height)
+	app.streamingDispatcher.commitMtx.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to load state at height %d: %w", height, err)
+	}
+
+	for _, key := range app.streamedKeys() {
+		it := cms.GetKVStore(key).Iterator(nil, nil)
+		for ; it.Valid(); it.Next() {
+			app.streamingDispatcher.dispatch(StoreKVPair{StoreKey: key, Key: it.Key(), Value: it.Value()}, nil, height)
+		}
+
+		if err := it.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// storeKeyLister is implemented by multi-stores which list their mounted
+// stores (e.g. rootmulti.Store).
+type storeKeyLister interface {
+	StoreKeys() []sdk.StoreKey
+}
+
+// ExportState passes every key-value pair of the IAVL stores at the given
+// height to the given listener, ordered by store name then key, e.g. to dump
+// a snapshot of the state for an audit. A height of 0 or less exports the
+// state at the latest height. Unlike StreamState, every persistent store is
+// exported and the streaming listeners are not involved.
+func (app *BaseApp) ExportState(height int64, listener store.WriteListener) error {
+	lister, ok := app.cms.(storeKeyLister)
+	if !ok {
+		return fmt.Errorf("multi-store does not support state exports")
+	}
+
+	lastHeight := app.LastBlockHeight()
+	if height <= 0 {
+		height = lastHeight
+	} else if height > lastHeight {
+		return fmt.Errorf("cannot export state at height %d above the latest height %d", height, lastHeight)
+	}
+
+	cms, err := app.cms.CacheMultiStoreWithVersion(height)
+	if err != nil {
+		return fmt.Errorf("failed to load state at height %d: %w", height, err)
+	}
+
+	for _, key := range lister.StoreKeys() {
+		if app.cms.GetCommitKVStore(key).GetStoreType() != sdk.StoreTypeIAVL {
+			continue
+		}
+
+		it := cms.GetKVStore(key).Iterator(nil, nil)
+		for ; it.Valid(); it.Next() {
+			listener.OnWrite(key, it.Key(), it.Value())
+		}
+
+		if err := it.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// storeDiffer is implemented by multi-stores which can compute the changes
+// made to their stores between two versions (e.g. rootmulti.Store).
+type storeDiffer interface {
+	LoadStoreDiff(fromVersion, toVersion int64, keys ...sdk.StoreKey) (func() ([]store.KVPairDelta, error), error)
+}
+
+// Backfill passes the state changes committed in every block of the given
+// height range, inclusive, to the WriteListeners of the stores streamed
+// through AddStreamingListeners, letting new consumers catch up without
+// replaying the blocks. The changes of a block are re-derived by diffing the
+// state at its height against the state at the previous height, so every
+// version from fromHeight-1 to toHeight must be available, e.g. on an archive
+// node. Unlike the live stream, keys rewritten with their previous value are
+// not streamed.
+//
+// It may be called while blocks are committed: the changes of every height
+// are diffed from immutable versions of the stores, so Commit only waits for
+// the versions to be loaded and for the changes of one height to be passed to
+// the listeners, between the writes of two blocks. The blocks committed
+// meanwhile may thus be streamed between two backfilled heights, and the
+// versions must not be pruned until the backfill returns.
+func (app *BaseApp) Backfill(fromHeight, toHeight int64) error {
+	app.streamingMtx.Lock()
+	defer app.streamingMtx.Unlock()
+
+	return app.backfill(fromHeight, toHeight, app.streamedKeys(), func(height int64, deltas []store.KVPairDelta) {
+		// the changes are passed between the blocks being streamed
+		app.streamingDispatcher.mtx.Lock()
+		defer app.streamingDispatcher.mtx.Unlock()
+
+		for _, delta := range deltas {
+			app.streamingDispatcher.dispatch(StoreKVPair{StoreKey: delta.StoreKey, Key: delta.Key, Value: delta.Value}, nil, height)
+		}
+	})
+}
+
+// BackfillTo passes the state changes committed in every block of the given
+// height range, inclusive, to the given listener, followed by the commit of
+// the block if the listener implements CommitListener. Unlike Backfill, the
+// changes of every persistent store are passed and the streaming listeners
+// are not involved, e.g. for tools backfilling a sink of their own.
+func (app *BaseApp) BackfillTo(fromHeight, toHeight int64, listener store.WriteListener) error {
+	cl, _ := listener.(CommitListener)
+
+	return app.backfill(fromHeight, toHeight, nil, func(height int64, deltas []store.KVPairDelta) {
+		for _, delta := range deltas {
+			listener.OnWrite(delta.StoreKey, delta.Key, delta.Value)
+		}
+
+		if cl != nil {
+			cl.OnCommit(height)
+		}
+	})
+}
+
+// backfill passes the changes made to the stores mounted under the given
+// keys, every persistent store if nil, by every block of the given height
+// range to pass.
+func (app *BaseApp) backfill(fromHeight, toHeight int64, keys []sdk.StoreKey, pass func(height int64, deltas []store.KVPairDelta)) error {
+	if fromHeight <= 0 || fromHeight > toHeight {
+		return fmt.Errorf("invalid backfill height range [%d, %d]", fromHeight, toHeight)
+	}
+
+	differ, ok := app.cms.(storeDiffer)
+	if !ok {
+		return fmt.Errorf("multi-store does not support state diffs")
+	}
+
+	// the changes are diffed between the blocks being committed
+	app.streamingDispatcher.commitMtx.Lock()
+	lastHeight := app.LastBlockHeight()
+	app.streamingDispatcher.commitMtx.Unlock()
+
+	if toHeight > lastHeight {
+		return fmt.Errorf("cannot backfill height %d above the latest height %d", toHeight, lastHeight)
+	}
+
+	if keys != nil && len(keys) == 0 {
+		return nil
+	}
+
+	for height := fromHeight; height <= toHeight; height++ {
+		// the versions are loaded between the blocks being committed, then
+		// diffed while the next blocks are committed
+		app.streamingDispatcher.commitMtx.Lock()
+		diff, err := differ.LoadStoreDiff(height-1, height, keys...)
+		app.streamingDispatcher.commitMtx.Unlock()
+
+		var deltas []store.KVPairDelta
+		if err == nil {
+			deltas, err = diff()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to backfill height %d: %w", height, err)
+		}
+
+		pass(height, deltas)
+	}
+
+	return nil
+}
+
+// streamedKeys returns the keys of the persistent stores streamed through
+// AddStreamingListeners, sorted by name.
+func (app *BaseApp) streamedKeys() []sdk.StoreKey {
+	keys := make([]sdk.StoreKey, 0, len(app.streamingDispatcher.listeners))
+	for key := range app.streamingDispatcher.listeners {
+		if !storetypes.IsEphemeralStoreKey(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name() < keys[j].Name() })
+
+	return keys
+}
+
+// CloseStreamingListeners drains and closes every registered listener that
+// implements io.Closer. It is meant to be called on graceful shutdown, once
+// the node has stopped processing blocks, so that no further writes are
+// passed to the listeners.
+//
+// A listener's Close must flush any data it buffers before returning. The
+// listeners are closed concurrently and CloseStreamingListeners waits for at
+// most the given timeout, returning an error if any listener failed to close
+// or did not close in time. A zero timeout waits indefinitely. If the writes
+// pending asynchronous dispatch are not dispatched in time, those not yet
+// passed to the listeners are dropped.
+func (app *BaseApp) CloseStreamingListeners(timeout time.Duration) error {
+	app.streamingMtx.Lock()
+	defer app.streamingMtx.Unlock()
+
+	listeners := make([]interface{}, 0, len(app.streamingListeners)+len(app.abciListeners)+len(app.txListeners)+len(app.summaryListeners)+len(app.proofListeners)+len(app.lifecycleListeners)+len(app.speculativeListeners))
+	for _, l := range app.streamingListeners {
+		listeners = append(listeners, l)
+	}
+	for _, l := range app.abciListeners {
+		listeners = append(listeners, l)
+	}
+	for _, l := range app.txListeners {
+		listeners = append(listeners, l)
+	}
+	for _, l := range app.summaryListeners {
+		listeners = append(listeners, l)
+	}
+	for _, l := range app.proofListeners {
+		listeners = append(listeners, l)
+	}
+	for _, l := range app.lifecycleListeners {
+		listeners = append(listeners, l)
+	}
+	for _, l := range app.speculativeListeners {
+		listeners = append(listeners, l)
+	}
+
+	closers := make([]io.Closer, 0, len(listeners))
+	seen := make(map[interface{}]struct{}, len(listeners))
+
+	for _, l := range listeners {
+		// the same listener may be registered for several stores, or as
+		// several kinds of listener
+		if reflect.TypeOf(l).Comparable() {
+			if _, ok := seen[l]; ok {
+				continue
+			}
+			seen[l] = struct{}{}
+		}
+
+		if c, ok := l.(io.Closer); ok {
+			closers = append(closers, c)
+		}
+	}
+
+	app.streamingListeners = nil
+	app.abciListeners = nil
+	app.txListeners = nil
+	app.summaryListeners = nil
+	app.proofListeners = nil
+	app.lifecycleListeners = nil
+	app.speculativeListeners = nil
+
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		timeoutCh = timer.C
+	}
+
+	// writes pending async dispatch must reach the listeners before they close
+	drained := make(chan struct{})
+	go func() {
+		app.streamingDispatcher.drain()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-timeoutCh:
+		// the writes still pending dispatch are dropped, so the dispatching
+		// goroutine stops
+		app.streamingDispatcher.cancel()
+		return fmt.Errorf("timed out after %s waiting for streaming listeners to drain", timeout)
+	}
+
+	errCh := make(chan error, len(closers))
+	for _, c := range closers {
+		go func(c io.Closer) { errCh <- c.Close() }(c)
+	}
+
+	var errs []string
+	for range closers {
+		select {
+		case err := <-errCh:
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+
+		case <-timeoutCh:
+			return fmt.Errorf("timed out after %s waiting for streaming listeners to close", timeout)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to close streaming listeners: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,883 @@
+package baseapp
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto/tmhash"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+type mockWriteListener struct {
+	mtx    sync.Mutex
+	writes map[string][]byte
+}
+
+func newMockWriteListener() *mockWriteListener {
+	return &mockWriteListener{writes: make(map[string][]byte)}
+}
+
+func (l *mockWriteListener) OnWrite(storeKey sdk.StoreKey, key []byte, value []byte) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.writes[storeKey.Name()+"/"+string(key)] = value
+}
+
+func TestAddStreamingListeners(t *testing.T) {
+	anteKey := []byte("ante-key")
+	anteOpt := func(bapp *BaseApp) { bapp.SetAnteHandler(anteHandlerTxTest(t, capKey1, anteKey)) }
+
+	deliverKey := []byte("deliver-key")
+	routerOpt := func(bapp *BaseApp) {
+		r := sdk.NewRoute(routeMsgCounter, handlerMsgCounter(t, capKey1, deliverKey))
+		bapp.Router().AddRoute(r)
+	}
+
+	listener := newMockWriteListener()
+	streamingOpt := func(bapp *BaseApp) { bapp.AddStreamingListeners(capKey1, listener) }
+
+	app := setupBaseApp(t, anteOpt, routerOpt, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+
+	header := tmproto.Header{Height: 1}
+	app.BeginBlock(abci.RequestBeginBlock{Header: header})
+
+	txBytes, err := codec.MarshalBinaryBare(newTxCounter(0, 0))
+	require.NoError(t, err)
+
+	res := app.DeliverTx(abci.RequestDeliverTx{Tx: txBytes})
+	require.True(t, res.IsOK(), res.Log)
+
+	app.EndBlock(abci.RequestEndBlock{})
+	require.Empty(t, listener.writes, "writes should only be streamed once committed")
+
+	app.Commit()
+
+	require.Len(t, listener.writes, 2)
+	require.Contains(t, listener.writes, capKey1.Name()+"/"+string(anteKey))
+	require.Contains(t, listener.writes, capKey1.Name()+"/"+string(deliverKey))
+
+	require.Panics(t, func() { app.AddStreamingListeners(capKey2, listener) })
+}
+
+func TestAddStreamingListenersAsync(t *testing.T) {
+	anteKey := []byte("ante-key")
+	anteOpt := func(bapp *BaseApp) { bapp.SetAnteHandler(anteHandlerTxTest(t, capKey1, anteKey)) }
+
+	deliverKey := []byte("deliver-key")
+	routerOpt := func(bapp *BaseApp) {
+		r := sdk.NewRoute(routeMsgCounter, handlerMsgCounter(t, capKey1, deliverKey))
+		bapp.Router().AddRoute(r)
+	}
+
+	listener := newMockWriteListener()
+	streamingOpt := func(bapp *BaseApp) { bapp.AddStreamingListeners(capKey1, listener) }
+
+	app := setupBaseApp(t, anteOpt, routerOpt, streamingOpt, SetStreamingAsync(true))
+	app.InitChain(abci.RequestInitChain{})
+
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+
+	for height := int64(1); height <= 3; height++ {
+		app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: height}})
+
+		txBytes, err := codec.MarshalBinaryBare(newTxCounter(height-1, height-1))
+		require.NoError(t, err)
+
+		res := app.DeliverTx(abci.RequestDeliverTx{Tx: txBytes})
+		require.True(t, res.IsOK(), res.Log)
+
+		app.EndBlock(abci.RequestEndBlock{Height: height})
+		app.Commit()
+	}
+
+	// closing drains the writes pending dispatch
+	require.NoError(t, app.CloseStreamingListeners(time.Second))
+
+	// the last writes of the counters are streamed
+	counter := make([]byte, binary.MaxVarintLen64)
+	counter = counter[:binary.PutVarint(counter, 3)]
+
+	require.Len(t, listener.writes, 2)
+	require.Equal(t, counter, listener.writes[capKey1.Name()+"/"+string(anteKey)])
+	require.Equal(t, counter, listener.writes[capKey1.Name()+"/"+string(deliverKey)])
+}
+
+// blockingWriteListener blocks every write until released.
+type blockingWriteListener struct {
+	*mockWriteListener
+
+	release chan struct{}
+	calls   int32
+}
+
+func (l *blockingWriteListener) OnWrite(storeKey sdk.StoreKey, key []byte, value []byte) {
+	atomic.AddInt32(&l.calls, 1)
+	<-l.release
+
+	l.mockWriteListener.OnWrite(storeKey, key, value)
+}
+
+func TestCloseStreamingListenersAsyncTimeout(t *testing.T) {
+	anteKey := []byte("ante-key")
+	anteOpt := func(bapp *BaseApp) { bapp.SetAnteHandler(anteHandlerTxTest(t, capKey1, anteKey)) }
+
+	listener := &blockingWriteListener{mockWriteListener: newMockWriteListener(), release: make(chan struct{})}
+	streamingOpt := func(bapp *BaseApp) { bapp.AddStreamingListeners(capKey1, listener) }
+
+	app := setupBaseApp(t, anteOpt, streamingOpt, SetStreamingAsync(true))
+	app.InitChain(abci.RequestInitChain{})
+
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+
+	for height := int64(1); height <= 3; height++ {
+		app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: height}})
+
+		txBytes, err := codec.MarshalBinaryBare(newTxCounter(height-1, height-1))
+		require.NoError(t, err)
+		app.DeliverTx(abci.RequestDeliverTx{Tx: txBytes})
+
+		app.EndBlock(abci.RequestEndBlock{Height: height})
+		app.Commit()
+	}
+
+	require.Error(t, app.CloseStreamingListeners(10*time.Millisecond))
+
+	// once the blocked write returns, the pending writes are dropped and the
+	// dispatching goroutine stops
+	close(listener.release)
+	select {
+	case <-app.streamingDispatcher.done:
+	case <-time.After(time.Second):
+		t.Fatal("the dispatching goroutine did not stop")
+	}
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&listener.calls))
+	require.False(t, app.StreamingStatus().Async)
+}
+
+func TestStreamState(t *testing.T) {
+	anteKey := []byte("ante-key")
+	anteOpt := func(bapp *BaseApp) { bapp.SetAnteHandler(anteHandlerTxTest(t, capKey1, anteKey)) }
+
+	deliverKey := []byte("deliver-key")
+	routerOpt := func(bapp *BaseApp) {
+		r := sdk.NewRoute(routeMsgCounter, handlerMsgCounter(t, capKey1, deliverKey))
+		bapp.Router().AddRoute(r)
+	}
+
+	listener := newMockWriteListener()
+	streamingOpt := func(bapp *BaseApp) { bapp.AddStreamingListeners(capKey1, listener) }
+
+	app := setupBaseApp(t, anteOpt, routerOpt, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+
+	for height := int64(1); height <= 2; height++ {
+		app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: height}})
+
+		txBytes, err := codec.MarshalBinaryBare(newTxCounter(height-1, height-1))
+		require.NoError(t, err)
+
+		res := app.DeliverTx(abci.RequestDeliverTx{Tx: txBytes})
+		require.True(t, res.IsOK(), res.Log)
+
+		app.EndBlock(abci.RequestEndBlock{Height: height})
+		app.Commit()
+	}
+
+	counter := func(i int64) []byte {
+		bz := make([]byte, binary.MaxVarintLen64)
+		return bz[:binary.PutVarint(bz, i)]
+	}
+
+	testCases := []struct {
+		height  int64
+		counter int64
+	}{
+		{0, 2},
+		{1, 1},
+		{2, 2},
+	}
+
+	for _, tc := range testCases {
+		listener.writes = make(map[string][]byte)
+		require.NoError(t, app.StreamState(tc.height))
+
+		require.Len(t, listener.writes, 2)
+		require.Equal(t, counter(tc.counter), listener.writes[capKey1.Name()+"/"+string(anteKey)])
+		require.Equal(t, counter(tc.counter), listener.writes[capKey1.Name()+"/"+string(deliverKey)])
+	}
+
+	require.Error(t, app.StreamState(10))
+}
+
+// exclusiveWriteListener records whether it was ever passed writes
+// concurrently.
+type exclusiveWriteListener struct {
+	active     int32
+	overlapped int32
+}
+
+func (l *exclusiveWriteListener) OnWrite(sdk.StoreKey, []byte, []byte) {
+	if atomic.AddInt32(&l.active, 1) > 1 {
+		atomic.StoreInt32(&l.overlapped, 1)
+	}
+
+	time.Sleep(10 * time.Microsecond)
+	atomic.AddInt32(&l.active, -1)
+}
+
+// testStreamingReplayDuringCommits checks that the writes replayed to the
+// streaming listeners while blocks are committed are never passed to them
+// concurrently with the writes of the blocks.
+func testStreamingReplayDuringCommits(t *testing.T, replay func(app *BaseApp) error) {
+	for _, async := range []bool{false, true} {
+		listener := &exclusiveWriteListener{}
+		streamingOpt := func(bapp *BaseApp) { bapp.AddStreamingListeners(capKey1, listener) }
+
+		app := setupBaseApp(t, SetPruning(storetypes.PruneNothing), SetStreamingAsync(async), streamingOpt)
+		app.InitChain(abci.RequestInitChain{})
+
+		commit := func(height int64) {
+			app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: height}})
+			for i := 0; i < 20; i++ {
+				app.deliverState.ctx.KVStore(capKey1).Set([]byte{byte(height), byte(i)}, []byte{byte(i)})
+			}
+			app.EndBlock(abci.RequestEndBlock{Height: height})
+			app.Commit()
+		}
+
+		commit(1)
+		commit(2)
+
+		done := make(chan error)
+		go func() {
+			for i := 0; i < 10; i++ {
+				if err := replay(app); err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}()
+
+		for height := int64(3); height <= 12; height++ {
+			commit(height)
+		}
+
+		require.NoError(t, <-done)
+		require.NoError(t, app.CloseStreamingListeners(0))
+		require.Zero(t, atomic.LoadInt32(&listener.overlapped), "async: %t", async)
+	}
+}
+
+func TestStreamStateDuringCommits(t *testing.T) {
+	testStreamingReplayDuringCommits(t, func(app *BaseApp) error { return app.StreamState(0) })
+}
+
+func TestBackfillDuringCommits(t *testing.T) {
+	testStreamingReplayDuringCommits(t, func(app *BaseApp) error { return app.Backfill(1, 2) })
+}
+
+func TestExportState(t *testing.T) {
+	app := setupBaseApp(t)
+	app.InitChain(abci.RequestInitChain{})
+
+	for height := int64(1); height <= 2; height++ {
+		app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: height}})
+		app.deliverState.ctx.KVStore(capKey1).Set([]byte("key1"), []byte{byte(height)})
+		app.deliverState.ctx.KVStore(capKey2).Set([]byte{byte(height)}, []byte("value2"))
+		app.EndBlock(abci.RequestEndBlock{Height: height})
+		app.Commit()
+	}
+
+	// every persistent store is exported, streamed or not
+	listener := newMockWriteListener()
+	require.NoError(t, app.ExportState(1, listener))
+	require.Equal(t, map[string][]byte{
+		capKey1.Name() + "/key1":                 {1},
+		capKey2.Name() + "/" + string([]byte{1}): []byte("value2"),
+	}, listener.writes)
+
+	listener = newMockWriteListener()
+	require.NoError(t, app.ExportState(0, listener))
+	require.Len(t, listener.writes, 3)
+	require.Equal(t, []byte{2}, listener.writes[capKey1.Name()+"/key1"])
+
+	require.Error(t, app.ExportState(10, listener))
+}
+
+func TestBackfill(t *testing.T) {
+	anteKey := []byte("ante-key")
+	anteOpt := func(bapp *BaseApp) { bapp.SetAnteHandler(anteHandlerTxTest(t, capKey1, anteKey)) }
+
+	deliverKey := []byte("deliver-key")
+	routerOpt := func(bapp *BaseApp) {
+		r := sdk.NewRoute(routeMsgCounter, handlerMsgCounter(t, capKey1, deliverKey))
+		bapp.Router().AddRoute(r)
+	}
+
+	listener := newMockWriteListener()
+	streamingOpt := func(bapp *BaseApp) { bapp.AddStreamingListeners(capKey1, listener) }
+
+	app := setupBaseApp(t, anteOpt, routerOpt, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+
+	for height := int64(1); height <= 3; height++ {
+		app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: height}})
+
+		txBytes, err := codec.MarshalBinaryBare(newTxCounter(height-1, height-1))
+		require.NoError(t, err)
+
+		res := app.DeliverTx(abci.RequestDeliverTx{Tx: txBytes})
+		require.True(t, res.IsOK(), res.Log)
+
+		app.EndBlock(abci.RequestEndBlock{Height: height})
+		app.Commit()
+	}
+
+	counter := func(i int64) []byte {
+		bz := make([]byte, binary.MaxVarintLen64)
+		return bz[:binary.PutVarint(bz, i)]
+	}
+
+	for height := int64(1); height <= 3; height++ {
+		listener.writes = make(map[string][]byte)
+		require.NoError(t, app.Backfill(height, height))
+
+		require.Len(t, listener.writes, 2)
+		require.Equal(t, counter(height), listener.writes[capKey1.Name()+"/"+string(anteKey)])
+		require.Equal(t, counter(height), listener.writes[capKey1.Name()+"/"+string(deliverKey)])
+	}
+
+	require.Error(t, app.Backfill(0, 1))
+	require.Error(t, app.Backfill(2, 1))
+	require.Error(t, app.Backfill(1, 10))
+
+	// a listener of its own is passed the changes and commits of every block
+	own := &commitListener{mockWriteListener: newMockWriteListener()}
+	require.NoError(t, app.BackfillTo(2, 3, own))
+	require.Equal(t, []int64{2, 3}, own.commits)
+	require.Equal(t, counter(3), own.writes[capKey1.Name()+"/"+string(deliverKey)])
+	require.Error(t, app.BackfillTo(1, 10, own))
+}
+
+func TestStreamingRetention(t *testing.T) {
+	streamingOpt := func(bapp *BaseApp) { bapp.AddStreamingListeners(capKey1, newMockWriteListener()) }
+
+	app := setupBaseApp(t, SetPruning(sdk.PruningOptions{Interval: 1}), SetStreamingRetention(true), streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	commit := func(height int64) {
+		app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: height}})
+		app.EndBlock(abci.RequestEndBlock{Height: height})
+		app.Commit()
+	}
+
+	for height := int64(1); height <= 5; height++ {
+		commit(height)
+	}
+
+	// nothing is pruned until a height is acknowledged
+	require.NoError(t, app.Backfill(1, 5))
+
+	app.AcknowledgeStreamedHeight(3)
+	app.AcknowledgeStreamedHeight(2)
+	require.Equal(t, int64(3), app.StreamingStatus().AcknowledgedHeight)
+
+	commit(6)
+	require.Error(t, app.Backfill(3, 3))
+	require.NoError(t, app.Backfill(5, 6))
+
+	// heights which weren't streamed yet cannot be acknowledged
+	app.AcknowledgeStreamedHeight(100)
+	require.Equal(t, int64(6), app.StreamingStatus().AcknowledgedHeight)
+}
+
+type genesisWriteListener struct {
+	*mockWriteListener
+	genesis map[string][]byte
+}
+
+func (l *genesisWriteListener) OnGenesisWrite(storeKey sdk.StoreKey, key []byte, value []byte) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.genesis[storeKey.Name()+"/"+string(key)] = value
+}
+
+func TestStreamGenesis(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		genesisListener := &genesisWriteListener{mockWriteListener: newMockWriteListener(), genesis: make(map[string][]byte)}
+		listener := newMockWriteListener()
+
+		initChainerOpt := func(bapp *BaseApp) {
+			bapp.SetInitChainer(func(ctx sdk.Context, req abci.RequestInitChain) abci.ResponseInitChain {
+				ctx.KVStore(capKey1).Set([]byte("genesis"), []byte("a"))
+				ctx.KVStore(capKey1).Set([]byte("overwritten"), []byte("b"))
+				return abci.ResponseInitChain{}
+			})
+		}
+		streamingOpt := func(bapp *BaseApp) { bapp.AddStreamingListeners(capKey1, genesisListener, listener) }
+
+		app := setupBaseApp(t, initChainerOpt, SetStreamGenesis(true), SetStreamingAsync(async), streamingOpt)
+		app.InitChain(abci.RequestInitChain{})
+
+		for height := int64(1); height <= 2; height++ {
+			app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: height}})
+			app.deliverState.ctx.KVStore(capKey1).Set([]byte("overwritten"), []byte{byte(height)})
+			app.EndBlock(abci.RequestEndBlock{Height: height})
+			app.Commit()
+		}
+
+		require.NoError(t, app.CloseStreamingListeners(time.Second))
+
+		require.Equal(t, map[string][]byte{capKey1.Name() + "/genesis": []byte("a")}, genesisListener.genesis)
+		require.Equal(t, map[string][]byte{capKey1.Name() + "/overwritten": {2}}, genesisListener.writes)
+		require.Equal(t, map[string][]byte{
+			capKey1.Name() + "/genesis":     []byte("a"),
+			capKey1.Name() + "/overwritten": {2},
+		}, listener.writes)
+	}
+}
+
+type readListener struct {
+	*mockWriteListener
+	reads map[string][]byte
+}
+
+func (l *readListener) OnRead(storeKey sdk.StoreKey, key []byte, value []byte) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.reads[storeKey.Name()+"/"+string(key)] = value
+}
+
+func TestAddStreamingReadListeners(t *testing.T) {
+	reader := &readListener{mockWriteListener: newMockWriteListener(), reads: make(map[string][]byte)}
+	listener := newMockWriteListener()
+	streamingOpt := func(bapp *BaseApp) { bapp.AddStreamingListeners(capKey1, reader, listener) }
+
+	app := setupBaseApp(t, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: 1}})
+	app.deliverState.ctx.KVStore(capKey1).Set([]byte("key"), []byte("value"))
+	app.EndBlock(abci.RequestEndBlock{Height: 1})
+	app.Commit()
+
+	require.Empty(t, reader.reads)
+	require.Equal(t, map[string][]byte{capKey1.Name() + "/key": []byte("value")}, reader.writes)
+
+	app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: 2}})
+	require.Equal(t, []byte("value"), app.deliverState.ctx.KVStore(capKey1).Get([]byte("key")))
+	require.Nil(t, app.deliverState.ctx.KVStore(capKey1).Get([]byte("missing")))
+
+	require.Equal(t, map[string][]byte{
+		capKey1.Name() + "/key":     []byte("value"),
+		capKey1.Name() + "/missing": nil,
+	}, reader.reads)
+	require.Len(t, listener.writes, 1, "writes should be streamed once")
+}
+
+type commitListener struct {
+	*mockWriteListener
+	commits []int64
+	blocks  []int
+}
+
+func (l *commitListener) OnCommit(height int64) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.commits = append(l.commits, height)
+	l.blocks = append(l.blocks, len(l.writes))
+}
+
+func TestStreamingCommitListener(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		listener := &commitListener{mockWriteListener: newMockWriteListener()}
+		streamingOpt := func(bapp *BaseApp) {
+			bapp.AddStreamingListeners(capKey1, listener)
+			bapp.AddStreamingListeners(capKey2, listener)
+		}
+
+		app := setupBaseApp(t, SetStreamingAsync(async), streamingOpt)
+		app.InitChain(abci.RequestInitChain{})
+
+		for height := int64(1); height <= 2; height++ {
+			app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: height}})
+			app.deliverState.ctx.KVStore(capKey1).Set([]byte{byte(height)}, []byte("value"))
+			app.EndBlock(abci.RequestEndBlock{Height: height})
+			app.Commit()
+		}
+
+		require.NoError(t, app.CloseStreamingListeners(time.Second))
+		require.Equal(t, []int64{1, 2}, listener.commits, "listeners should be notified once per block")
+		require.Equal(t, []int{1, 2}, listener.blocks, "the writes of a block should be passed before its commit")
+	}
+}
+
+type erroringWriteListener struct {
+	*mockWriteListener
+}
+
+func (l erroringWriteListener) Err() error { return errors.New("write failed") }
+
+func TestStreamingStatus(t *testing.T) {
+	listener := newMockWriteListener()
+	streamingOpt := func(bapp *BaseApp) {
+		bapp.AddStreamingListeners(capKey2, listener)
+		bapp.AddStreamingListeners(capKey1, listener, erroringWriteListener{newMockWriteListener()})
+		bapp.AddSpeculativeStreamingListeners([]sdk.StoreKey{capKey1}, &mockSpeculativeListener{})
+		bapp.AddStreamingProofListeners(capKey1, nil, &mockProofListener{})
+		bapp.AddStreamingLifecycleListeners(&mockLifecycleListener{})
+	}
+
+	app := setupBaseApp(t, streamingOpt, SetStreamingAsync(true))
+	app.InitChain(abci.RequestInitChain{})
+
+	for height := int64(1); height <= 2; height++ {
+		app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: height}})
+		app.EndBlock(abci.RequestEndBlock{Height: height})
+		app.Commit()
+	}
+
+	require.Eventually(t, func() bool {
+		return app.StreamingStatus().LastStreamedHeight == 2
+	}, time.Second, 10*time.Millisecond)
+
+	status := app.StreamingStatus()
+	require.Equal(t, []string{capKey1.Name(), capKey2.Name()}, status.Stores)
+	require.Equal(t, 6, status.Listeners)
+	require.True(t, status.Async)
+	require.Zero(t, status.PendingBlocks)
+	require.Equal(t, []string{"write failed"}, status.Errors)
+
+	require.NoError(t, app.CloseStreamingListeners(time.Second))
+	require.False(t, app.StreamingStatus().Async)
+}
+
+type mockABCIListener struct {
+	beginBlocks []abci.RequestBeginBlock
+	endBlocks   []abci.ResponseEndBlock
+	deliverTxs  []abci.ResponseDeliverTx
+}
+
+func (l *mockABCIListener) ListenBeginBlock(_ sdk.Context, req abci.RequestBeginBlock, _ abci.ResponseBeginBlock) {
+	l.beginBlocks = append(l.beginBlocks, req)
+}
+
+func (l *mockABCIListener) ListenEndBlock(_ sdk.Context, _ abci.RequestEndBlock, res abci.ResponseEndBlock) {
+	l.endBlocks = append(l.endBlocks, res)
+}
+
+func (l *mockABCIListener) ListenDeliverTx(_ sdk.Context, _ abci.RequestDeliverTx, res abci.ResponseDeliverTx) {
+	l.deliverTxs = append(l.deliverTxs, res)
+}
+
+func TestAddStreamingABCIListeners(t *testing.T) {
+	valUpdates := []abci.ValidatorUpdate{{Power: 10}}
+	endBlockerOpt := func(bapp *BaseApp) {
+		bapp.SetEndBlocker(func(_ sdk.Context, _ abci.RequestEndBlock) abci.ResponseEndBlock {
+			return abci.ResponseEndBlock{ValidatorUpdates: valUpdates}
+		})
+	}
+
+	listener := &mockABCIListener{}
+	streamingOpt := func(bapp *BaseApp) { bapp.AddStreamingABCIListeners(listener) }
+
+	app := setupBaseApp(t, endBlockerOpt, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	evidence := []abci.Evidence{{Type: abci.EvidenceType_DUPLICATE_VOTE, Height: 1}}
+	app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: 1}, ByzantineValidators: evidence})
+	app.EndBlock(abci.RequestEndBlock{Height: 1})
+	app.Commit()
+
+	require.Len(t, listener.beginBlocks, 1)
+	require.Equal(t, evidence, listener.beginBlocks[0].ByzantineValidators)
+	require.Len(t, listener.endBlocks, 1)
+	require.Equal(t, valUpdates, listener.endBlocks[0].ValidatorUpdates)
+
+	require.Panics(t, func() { app.AddStreamingABCIListeners(listener) })
+}
+
+func TestStreamingDeliverTxEvents(t *testing.T) {
+	anteKey := []byte("ante-key")
+	anteOpt := func(bapp *BaseApp) { bapp.SetAnteHandler(anteHandlerTxTest(t, capKey1, anteKey)) }
+
+	deliverKey := []byte("deliver-key")
+	routerOpt := func(bapp *BaseApp) {
+		r := sdk.NewRoute(routeMsgCounter, handlerMsgCounter(t, capKey1, deliverKey))
+		bapp.Router().AddRoute(r)
+	}
+
+	listener := &mockABCIListener{}
+	streamingOpt := func(bapp *BaseApp) { bapp.AddStreamingABCIListeners(listener) }
+
+	app := setupBaseApp(t, anteOpt, routerOpt, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+
+	app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: 1}})
+
+	tx := newTxCounter(0, 0)
+	txBytes, err := codec.MarshalBinaryBare(tx)
+	require.NoError(t, err)
+	res := app.DeliverTx(abci.RequestDeliverTx{Tx: txBytes})
+	require.True(t, res.IsOK(), res.Log)
+
+	// a failing transaction is passed to the listeners as well
+	tx = newTxCounter(1, 1)
+	tx.setFailOnHandler(true)
+	txBytes, err = codec.MarshalBinaryBare(tx)
+	require.NoError(t, err)
+	failed := app.DeliverTx(abci.RequestDeliverTx{Tx: txBytes})
+	require.False(t, failed.IsOK())
+
+	app.EndBlock(abci.RequestEndBlock{Height: 1})
+	app.Commit()
+
+	require.Equal(t, []abci.ResponseDeliverTx{res, failed}, listener.deliverTxs)
+	require.NotEmpty(t, listener.deliverTxs[0].Events)
+}
+
+type mockTxStateChangesListener struct {
+	changes []TxStateChanges
+}
+
+func (l *mockTxStateChangesListener) OnTxStateChanges(_ sdk.Context, changes TxStateChanges) {
+	l.changes = append(l.changes, changes)
+}
+
+func TestAddStreamingTxListeners(t *testing.T) {
+	anteKey := []byte("ante-key")
+	anteOpt := func(bapp *BaseApp) { bapp.SetAnteHandler(anteHandlerTxTest(t, capKey1, anteKey)) }
+
+	deliverKey := []byte("deliver-key")
+	routerOpt := func(bapp *BaseApp) {
+		r := sdk.NewRoute(routeMsgCounter, handlerMsgCounter(t, capKey1, deliverKey))
+		bapp.Router().AddRoute(r)
+	}
+
+	listener := &mockTxStateChangesListener{}
+	streamingOpt := func(bapp *BaseApp) { bapp.AddStreamingTxListeners([]sdk.StoreKey{capKey1}, listener) }
+
+	app := setupBaseApp(t, anteOpt, routerOpt, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+
+	app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: 1}})
+
+	tx := newTxCounter(0, 0)
+	txBytes, err := codec.MarshalBinaryBare(tx)
+	require.NoError(t, err)
+	res := app.DeliverTx(abci.RequestDeliverTx{Tx: txBytes})
+	require.True(t, res.IsOK(), res.Log)
+
+	require.Len(t, listener.changes, 1)
+	require.Equal(t, tmhash.Sum(txBytes), listener.changes[0].TxHash)
+	require.Len(t, listener.changes[0].Msgs, 1)
+
+	writes := listener.changes[0].Writes
+	require.Len(t, writes, 2)
+	require.Equal(t, anteKey, writes[0].Key)
+	require.Equal(t, deliverKey, writes[1].Key)
+
+	// only the AnteHandler writes of a transaction with failing messages are kept
+	tx = newTxCounter(1, 1)
+	tx.setFailOnHandler(true)
+	txBytes, err = codec.MarshalBinaryBare(tx)
+	require.NoError(t, err)
+	res = app.DeliverTx(abci.RequestDeliverTx{Tx: txBytes})
+	require.False(t, res.IsOK())
+
+	require.Len(t, listener.changes, 2)
+	require.Len(t, listener.changes[1].Writes, 1)
+	require.Equal(t, anteKey, listener.changes[1].Writes[0].Key)
+
+	require.Panics(t, func() { app.AddStreamingTxListeners([]sdk.StoreKey{capKey2}, listener) })
+}
+
+type mockBlockSummaryListener struct {
+	summaries []BlockStateSummary
+}
+
+func (l *mockBlockSummaryListener) OnBlockSummary(summary BlockStateSummary) {
+	l.summaries = append(l.summaries, summary)
+}
+
+func TestAddStreamingSummaryListeners(t *testing.T) {
+	anteKey := []byte("ante-key")
+	anteOpt := func(bapp *BaseApp) { bapp.SetAnteHandler(anteHandlerTxTest(t, capKey1, anteKey)) }
+
+	deliverKey := []byte("deliver-key")
+	routerOpt := func(bapp *BaseApp) {
+		r := sdk.NewRoute(routeMsgCounter, handlerMsgCounter(t, capKey1, deliverKey))
+		bapp.Router().AddRoute(r)
+	}
+
+	listener := &mockBlockSummaryListener{}
+	streamingOpt := func(bapp *BaseApp) {
+		bapp.AddStreamingSummaryListeners([]sdk.StoreKey{capKey1, capKey2}, listener)
+	}
+
+	app := setupBaseApp(t, anteOpt, routerOpt, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+
+	app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: 1}})
+
+	txBytes, err := codec.MarshalBinaryBare(newTxCounter(0, 0))
+	require.NoError(t, err)
+	res := app.DeliverTx(abci.RequestDeliverTx{Tx: txBytes})
+	require.True(t, res.IsOK(), res.Log)
+
+	app.EndBlock(abci.RequestEndBlock{Height: 1})
+	app.Commit()
+
+	require.Len(t, listener.summaries, 1)
+	summary := listener.summaries[0]
+	require.Equal(t, int64(1), summary.Height)
+
+	// capKey2 was not written to
+	require.Len(t, summary.Stores, 1)
+	stores := summary.Stores[0]
+	require.Equal(t, capKey1, stores.StoreKey)
+	require.Equal(t, uint64(2), stores.Writes)
+	require.Zero(t, stores.Deletes)
+	require.NotZero(t, stores.Bytes)
+	require.Equal(t, []byte{'a', 'd'}, stores.Prefixes)
+
+	// empty blocks are still summarized
+	app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: 2}})
+	app.EndBlock(abci.RequestEndBlock{Height: 2})
+	app.Commit()
+
+	require.Len(t, listener.summaries, 2)
+	require.Equal(t, int64(2), listener.summaries[1].Height)
+	require.Empty(t, listener.summaries[1].Stores)
+}
+
+func TestAddStreamingListenersNonPersistent(t *testing.T) {
+	app := newBaseApp(t.Name())
+
+	require.Panics(t, func() {
+		app.AddStreamingListeners(sdk.NewTransientStoreKey("transient"), newMockWriteListener())
+	})
+	require.NotPanics(t, func() {
+		app.AddStreamingListeners(capKey1, newMockWriteListener())
+	})
+}
+
+func TestAddEphemeralStreamingListeners(t *testing.T) {
+	tkey, mkey := sdk.NewTransientStoreKey("transient"), storetypes.NewMemoryStoreKey("memory")
+	listener := newMockWriteListener()
+	streamingOpt := func(bapp *BaseApp) {
+		bapp.AddEphemeralStreamingListeners(tkey, listener)
+		bapp.AddEphemeralStreamingListeners(mkey, listener)
+	}
+
+	app := newBaseApp(t.Name(), streamingOpt)
+	app.MountStores(capKey1, tkey)
+	app.MountStore(mkey, sdk.StoreTypeMemory)
+	require.NoError(t, app.LoadLatestVersion())
+	app.InitChain(abci.RequestInitChain{})
+
+	app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: 1}})
+	app.deliverState.ctx.KVStore(tkey).Set([]byte("flag"), []byte("1"))
+	app.deliverState.ctx.KVStore(mkey).Set([]byte("cache"), []byte("2"))
+	app.deliverState.ctx.KVStore(capKey1).Set([]byte("key"), []byte("3"))
+	app.EndBlock(abci.RequestEndBlock{Height: 1})
+	app.Commit()
+
+	require.Equal(t, map[string][]byte{
+		"transient/flag": []byte("1"),
+		"memory/cache":   []byte("2"),
+	}, listener.writes)
+
+	// ephemeral stores are not part of the streamed state
+	listener.writes = make(map[string][]byte)
+	require.NoError(t, app.StreamState(0))
+	require.Empty(t, listener.writes)
+
+	require.Panics(t, func() {
+		newBaseApp(t.Name()).AddEphemeralStreamingListeners(capKey1, newMockWriteListener())
+	})
+}
+
+type closingWriteListener struct {
+	*mockWriteListener
+
+	closed int
+	delay  time.Duration
+	err    error
+}
+
+func (l *closingWriteListener) Close() error {
+	time.Sleep(l.delay)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.closed++
+	return l.err
+}
+
+func TestCloseStreamingListeners(t *testing.T) {
+	listener := &closingWriteListener{mockWriteListener: newMockWriteListener()}
+
+	app := newBaseApp(t.Name())
+	app.AddStreamingListeners(capKey1, listener, newMockWriteListener())
+	app.AddStreamingListeners(capKey2, listener)
+
+	require.NoError(t, app.CloseStreamingListeners(time.Second))
+	require.Equal(t, 1, listener.closed, "a listener registered for several stores should be closed once")
+
+	// listeners are only closed once
+	require.NoError(t, app.CloseStreamingListeners(time.Second))
+	require.Equal(t, 1, listener.closed)
+}
+
+func TestCloseStreamingListenersErrors(t *testing.T) {
+	failing := &closingWriteListener{mockWriteListener: newMockWriteListener(), err: errors.New("flush failed")}
+
+	app := newBaseApp(t.Name())
+	app.AddStreamingListeners(capKey1, failing)
+	require.Error(t, app.CloseStreamingListeners(time.Second))
+
+	slow := &closingWriteListener{mockWriteListener: newMockWriteListener(), delay: time.Second}
+
+	app = newBaseApp(t.Name())
+	app.AddStreamingListeners(capKey1, slow)
+	require.Error(t, app.CloseStreamingListeners(10*time.Millisecond))
+}
@@ -0,0 +1,255 @@
+package baseapp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// stateChangeBuffer is the number of state changes buffered for each
+// subscription before it is considered too slow and closed.
+const stateChangeBuffer = 1024
+
+// StateChange is a write committed to a store at a height, passed to the
+// subscriptions made through SubscribeStateChanges.
+type StateChange struct {
+	Height int64
+	StoreKVPair
+}
+
+// StateChangeFilter selects the state changes passed to a subscription: the
+// writes to the stores mounted under StoreKeys, all the subscribable stores
+// if empty, and to the keys with one of KeyPrefixes, all if empty.
+type StateChangeFilter struct {
+	StoreKeys   []sdk.StoreKey
+	KeyPrefixes [][]byte
+}
+
+// matches returns true if the write is passed to the subscriptions with the
+// filter.
+func (f StateChangeFilter) matches(kv StoreKVPair) bool {
+	if len(f.StoreKeys) > 0 {
+		var found bool
+		for _, key := range f.StoreKeys {
+			found = found || key == kv.StoreKey
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.KeyPrefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range f.KeyPrefixes {
+		if bytes.HasPrefix(kv.Key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stateChangeSubscription is a subscription made through
+// SubscribeStateChanges.
+type stateChangeSubscription struct {
+	filter  StateChangeFilter
+	changes chan StateChange
+	done    chan struct{}
+}
+
+// stateChangeHub is the WriteListener and CommitListener registered for the
+// stores which can be subscribed to. It stages the writes of each block and
+// passes them to the matching subscriptions once the block is committed.
+type stateChangeHub struct {
+	mtx  sync.Mutex
+	keys map[sdk.StoreKey]bool
+	subs map[*stateChangeSubscription]struct{}
+	// pending are the subscriptions made while the writes of a block were
+	// being passed, which start with the next block so they never receive
+	// part of one
+	pending map[*stateChangeSubscription]struct{}
+	// inBlock is true from the first write of a block until it is committed
+	inBlock bool
+	staged  []StoreKVPair
+	closed  bool
+}
+
+var _ CommitListener = (*stateChangeHub)(nil)
+
+func newStateChangeHub() *stateChangeHub {
+	return &stateChangeHub{
+		keys:    make(map[sdk.StoreKey]bool),
+		subs:    make(map[*stateChangeSubscription]struct{}),
+		pending: make(map[*stateChangeSubscription]struct{}),
+	}
+}
+
+// OnWrite implements the WriteListener interface. The writes are only staged
+// while there are subscriptions.
+func (h *stateChangeHub) OnWrite(storeKey sdk.StoreKey, key []byte, value []byte) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.inBlock = true
+	if len(h.subs) > 0 {
+		h.staged = append(h.staged, StoreKVPair{StoreKey: storeKey, Key: key, Value: value})
+	}
+}
+
+// OnCommit implements the CommitListener interface. It passes the writes of
+// the block to the matching subscriptions, closing those whose buffer is
+// full, then starts the subscriptions made during the block.
+func (h *stateChangeHub) OnCommit(height int64) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	staged := h.staged
+	h.staged = nil
+	h.inBlock = false
+
+	defer func() {
+		for sub := range h.pending {
+			delete(h.pending, sub)
+			h.subs[sub] = struct{}{}
+		}
+	}()
+
+	for sub := range h.subs {
+		for _, kv := range staged {
+			if !sub.filter.matches(kv) {
+				continue
+			}
+
+			select {
+			case sub.changes <- StateChange{Height: height, StoreKVPair: kv}:
+				continue
+			default:
+			}
+
+			telemetry.IncrCounter(1, "streaming", "dropped_subscriptions")
+			h.unsubscribe(sub)
+
+			break
+		}
+	}
+}
+
+// unsubscribe removes the subscription and closes its channel. It must be
+// called with the lock held.
+func (h *stateChangeHub) unsubscribe(sub *stateChangeSubscription) {
+	_, active := h.subs[sub]
+	_, pending := h.pending[sub]
+	if !active && !pending {
+		return
+	}
+
+	delete(h.subs, sub)
+	delete(h.pending, sub)
+	close(sub.changes)
+	close(sub.done)
+}
+
+// Close implements the io.Closer interface. It closes every subscription.
+func (h *stateChangeHub) Close() error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for sub := range h.subs {
+		h.unsubscribe(sub)
+	}
+	for sub := range h.pending {
+		h.unsubscribe(sub)
+	}
+	h.staged = nil
+	h.closed = true
+
+	return nil
+}
+
+// EnableStateChangeSubscriptions makes the KVStores mounted under the provided
+// keys available to SubscribeStateChanges. Like AddStreamingListeners, it must
+// be called before the BaseApp is sealed and it is safe to call concurrently.
+func (app *BaseApp) EnableStateChangeSubscriptions(keys ...sdk.StoreKey) {
+	app.streamingMtx.Lock()
+	if app.stateChangeHub == nil {
+		app.stateChangeHub = newStateChangeHub()
+	}
+	hub := app.stateChangeHub
+	app.streamingMtx.Unlock()
+
+	for _, key := range keys {
+		app.AddStreamingListeners(key, hub)
+
+		hub.mtx.Lock()
+		hub.keys[key] = true
+		hub.mtx.Unlock()
+	}
+}
+
+// SubscribeStateChanges subscribes to the state changes committed to the
+// stores enabled through EnableStateChangeSubscriptions which match the
+// filter, so modules or goroutines running in the same binary can consume
+// them without going through files or network sinks. The writes of every
+// block are passed on the returned channel once the block is committed, in
+// the order they were written. A subscription made while the writes of a
+// block are being passed starts with the next block, so every block is either
+// passed whole or not at all.
+//
+// The channel is closed once ctx is done, once the streaming listeners are
+// closed, or if the subscriber falls more than 1024 state changes behind, as
+// a slow subscriber must not hold back the node. It returns an error if the
+// filter selects a store which wasn't enabled, or if subscriptions are not
+// enabled at all.
+func (app *BaseApp) SubscribeStateChanges(ctx context.Context, filter StateChangeFilter) (<-chan StateChange, error) {
+	app.streamingMtx.Lock()
+	hub := app.stateChangeHub
+	app.streamingMtx.Unlock()
+
+	if hub == nil {
+		return nil, errors.New("state change subscriptions are not enabled")
+	}
+
+	hub.mtx.Lock()
+	defer hub.mtx.Unlock()
+
+	if hub.closed {
+		return nil, errors.New("state change subscriptions are closed")
+	}
+
+	for _, key := range filter.StoreKeys {
+		if !hub.keys[key] {
+			return nil, fmt.Errorf("state change subscriptions are not enabled for store %s", key.Name())
+		}
+	}
+
+	sub := &stateChangeSubscription{
+		filter:  filter,
+		changes: make(chan StateChange, stateChangeBuffer),
+		done:    make(chan struct{}),
+	}
+	if hub.inBlock {
+		hub.pending[sub] = struct{}{}
+	} else {
+		hub.subs[sub] = struct{}{}
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			hub.mtx.Lock()
+			hub.unsubscribe(sub)
+			hub.mtx.Unlock()
+
+		case <-sub.done:
+		}
+	}()
+
+	return sub.changes, nil
+}
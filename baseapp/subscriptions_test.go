@@ -0,0 +1,143 @@
+package baseapp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestSubscribeStateChanges(t *testing.T) {
+	streamingOpt := func(bapp *BaseApp) { bapp.EnableStateChangeSubscriptions(capKey1) }
+
+	app := setupBaseApp(t, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	all, err := app.SubscribeStateChanges(ctx, StateChangeFilter{})
+	require.NoError(t, err)
+	filtered, err := app.SubscribeStateChanges(ctx, StateChangeFilter{
+		StoreKeys:   []sdk.StoreKey{capKey1},
+		KeyPrefixes: [][]byte{[]byte("b")},
+	})
+	require.NoError(t, err)
+
+	// the store was not enabled
+	_, err = app.SubscribeStateChanges(ctx, StateChangeFilter{StoreKeys: []sdk.StoreKey{capKey2}})
+	require.Error(t, err)
+
+	app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: 1}})
+	app.deliverState.ctx.KVStore(capKey1).Set([]byte("a"), []byte("1"))
+	app.deliverState.ctx.KVStore(capKey1).Set([]byte("b"), []byte("2"))
+	app.deliverState.ctx.KVStore(capKey2).Set([]byte("c"), []byte("3"))
+	app.EndBlock(abci.RequestEndBlock{Height: 1})
+
+	// the writes are passed once the block is committed
+	require.Empty(t, all)
+	app.Commit()
+
+	require.Equal(t, StateChange{Height: 1, StoreKVPair: StoreKVPair{StoreKey: capKey1, Key: []byte("a"), Value: []byte("1")}}, <-all)
+	require.Equal(t, StateChange{Height: 1, StoreKVPair: StoreKVPair{StoreKey: capKey1, Key: []byte("b"), Value: []byte("2")}}, <-all)
+	require.Equal(t, StateChange{Height: 1, StoreKVPair: StoreKVPair{StoreKey: capKey1, Key: []byte("b"), Value: []byte("2")}}, <-filtered)
+	require.Empty(t, all)
+	require.Empty(t, filtered)
+
+	// cancelling the context closes the channel
+	cancel()
+	_, ok := <-all
+	require.False(t, ok)
+
+	// closing the streaming listeners closes the subscriptions
+	open, err := app.SubscribeStateChanges(context.Background(), StateChangeFilter{})
+	require.NoError(t, err)
+	require.NoError(t, app.CloseStreamingListeners(0))
+	_, ok = <-open
+	require.False(t, ok)
+
+	_, err = app.SubscribeStateChanges(context.Background(), StateChangeFilter{})
+	require.Error(t, err)
+}
+
+func TestSubscribeStateChangesSlowSubscriber(t *testing.T) {
+	streamingOpt := func(bapp *BaseApp) { bapp.EnableStateChangeSubscriptions(capKey1) }
+
+	app := setupBaseApp(t, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	changes, err := app.SubscribeStateChanges(context.Background(), StateChangeFilter{})
+	require.NoError(t, err)
+
+	app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: 1}})
+	for i := 0; i <= stateChangeBuffer; i++ {
+		app.deliverState.ctx.KVStore(capKey1).Set(sdk.Uint64ToBigEndian(uint64(i)), []byte("value"))
+	}
+	app.EndBlock(abci.RequestEndBlock{Height: 1})
+	app.Commit()
+
+	// the subscriber fell behind and was dropped after its buffer filled
+	for i := 0; i < stateChangeBuffer; i++ {
+		_, ok := <-changes
+		require.True(t, ok)
+	}
+	_, ok := <-changes
+	require.False(t, ok)
+
+	require.Panics(t, func() { app.EnableStateChangeSubscriptions(capKey2) })
+}
+
+func TestSubscribeStateChangesNotEnabled(t *testing.T) {
+	app := setupBaseApp(t)
+
+	_, err := app.SubscribeStateChanges(context.Background(), StateChangeFilter{})
+	require.Error(t, err)
+}
+
+// subscribingListener subscribes to the state changes on the first write it
+// is passed.
+type subscribingListener struct {
+	app     *BaseApp
+	changes <-chan StateChange
+}
+
+func (l *subscribingListener) OnWrite(sdk.StoreKey, []byte, []byte) {
+	if l.changes == nil {
+		l.changes, _ = l.app.SubscribeStateChanges(context.Background(), StateChangeFilter{})
+	}
+}
+
+func TestSubscribeStateChangesMidBlock(t *testing.T) {
+	listener := &subscribingListener{}
+	streamingOpt := func(bapp *BaseApp) {
+		bapp.EnableStateChangeSubscriptions(capKey1)
+		bapp.AddStreamingListeners(capKey1, listener)
+		listener.app = bapp
+	}
+
+	app := setupBaseApp(t, streamingOpt)
+	app.InitChain(abci.RequestInitChain{})
+
+	all, err := app.SubscribeStateChanges(context.Background(), StateChangeFilter{})
+	require.NoError(t, err)
+
+	for height := int64(1); height <= 2; height++ {
+		app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: height}})
+		app.deliverState.ctx.KVStore(capKey1).Set([]byte("a"), []byte{byte(height)})
+		app.deliverState.ctx.KVStore(capKey1).Set([]byte("b"), []byte{byte(height)})
+		app.EndBlock(abci.RequestEndBlock{Height: height})
+		app.Commit()
+	}
+
+	require.Len(t, all, 4)
+
+	// the subscription made during the first block starts with the second
+	require.NotNil(t, listener.changes)
+	require.Len(t, listener.changes, 2)
+	require.Equal(t, StateChange{Height: 2, StoreKVPair: StoreKVPair{StoreKey: capKey1, Key: []byte("a"), Value: []byte{2}}}, <-listener.changes)
+	require.Equal(t, StateChange{Height: 2, StoreKVPair: StoreKVPair{StoreKey: capKey1, Key: []byte("b"), Value: []byte{2}}}, <-listener.changes)
+}
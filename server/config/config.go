@@ -1,11 +1,18 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 
+	"github.com/cosmos/cosmos-sdk/server/streaming"
 	storetypes "github.com/cosmos/cosmos-sdk/store/types"
 	"github.com/cosmos/cosmos-sdk/telemetry"
 	sdk "github.com/cosmos/cosmos-sdk/types"
@@ -16,6 +23,10 @@ const (
 
 	// DefaultGRPCAddress is the default address the gRPC server binds to.
 	DefaultGRPCAddress = "0.0.0.0:9090"
+
+	// DefaultStreamingAdminAddress is the default address the streaming admin
+	// endpoints are served on, only reachable from the node itself.
+	DefaultStreamingAdminAddress = "127.0.0.1:1318"
 )
 
 // BaseConfig defines the server's basic configuration
@@ -118,6 +129,306 @@ type StateSyncConfig struct {
 	SnapshotKeepRecent uint32 `mapstructure:"snapshot-keep-recent"`
 }
 
+// Streaming services which can be enabled in the StreamingConfig.
+const (
+	StreamingServiceDestination = "destination"
+	StreamingServiceWebhook     = "webhook"
+	StreamingServiceFirehose    = "firehose"
+)
+
+// Kinds of the streaming destination.
+const (
+	StreamingDestinationAuto   = "auto"
+	StreamingDestinationSocket = "socket"
+	StreamingDestinationFIFO   = "fifo"
+)
+
+// StreamingConfig defines the state streaming configuration.
+type StreamingConfig struct {
+	// ShutdownTimeout is the maximum time to wait for the streaming listeners
+	// to flush on shutdown. 0 waits indefinitely.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown-timeout"`
+
+	// Retention retains the heights above the last one acknowledged by the
+	// streaming consumers instead of pruning them.
+	Retention bool `mapstructure:"retention"`
+
+	// Services defines the streaming services the node streams the state
+	// changes to, among the StreamingService* services.
+	Services []string `mapstructure:"services"`
+
+	// Keys defines the names of the stores whose state changes are streamed.
+	// An empty list streams every store.
+	Keys []string `mapstructure:"keys"`
+
+	// Namespace moves the files the streaming services write to, or connect
+	// to, into a directory named after the chain ID, so the nodes of several
+	// networks sharing a host don't collide.
+	Namespace bool `mapstructure:"namespace"`
+
+	// Metadata defines the metadata the streamed state changes are stamped
+	// with, such as the node moniker, region or custom labels, so the records
+	// streamed by a fleet of nodes can be attributed and deduplicated.
+	Metadata map[string]string `mapstructure:"metadata"`
+
+	// Pipeline defines the concurrency and batching of the pipelined
+	// destinations.
+	Pipeline StreamingPipelineConfig `mapstructure:"pipeline"`
+
+	// Destination defines the configuration of the destination service.
+	Destination StreamingDestinationConfig `mapstructure:"destination"`
+
+	// Webhook defines the configuration of the webhook service.
+	Webhook StreamingWebhookConfig `mapstructure:"webhook"`
+
+	// Firehose defines the configuration of the firehose service.
+	Firehose StreamingFirehoseConfig `mapstructure:"firehose"`
+
+	// Admin defines the listener serving the streaming endpoints which
+	// control the node, kept apart from the public API server.
+	Admin StreamingAdminConfig `mapstructure:"admin"`
+}
+
+// StreamingPipelineConfig defines the configuration of the pipelined streaming
+// destinations, see streaming.PipelineOptions.
+type StreamingPipelineConfig struct {
+	// Writers is the number of writers of each pipelined destination.
+	Writers int `mapstructure:"writers"`
+
+	// InFlightBatches is the number of batches queued for each writer before
+	// the writes block.
+	InFlightBatches int `mapstructure:"in-flight-batches"`
+
+	// MaxBatchBytes is the size in bytes past which a batch of writes is queued
+	// for its writer.
+	MaxBatchBytes int `mapstructure:"max-batch-bytes"`
+
+	// Linger is the longest a write waits for its batch to fill before it is
+	// queued. 0 waits for the commit of its block.
+	Linger time.Duration `mapstructure:"linger"`
+}
+
+// StreamingDestinationConfig defines the configuration of the destination
+// service, streaming to a co-located consumer, see streaming.Destination.
+type StreamingDestinationConfig struct {
+	// Path is the path of the unix domain socket or named pipe the consumer
+	// reads from.
+	Path string `mapstructure:"path"`
+
+	// Kind is the kind of file at Path, one of "auto", "socket" or "fifo".
+	Kind string `mapstructure:"kind"`
+}
+
+// StreamingWebhookConfig defines the configuration of the webhook service,
+// see streaming.Webhook.
+type StreamingWebhookConfig struct {
+	// URLs are the URLs of the endpoints the writes are posted to.
+	URLs []string `mapstructure:"urls"`
+
+	// Secret is the secret the requests are signed with. An empty secret
+	// leaves them unsigned.
+	Secret Secret `mapstructure:"secret"`
+}
+
+// Secret is a credential set in the configuration, either inline or, to keep
+// it out of app.toml, as a reference to the environment variable holding it,
+// "env:NAME", or to the file holding it, "file:PATH". Its String and JSON
+// encoding redact inline secrets, so configurations can be logged or served
+// without leaking them.
+type Secret string
+
+// Prefixes of the Secret references.
+const (
+	SecretEnvPrefix  = "env:"
+	SecretFilePrefix = "file:"
+)
+
+// Resolve returns the value of the secret, read from the environment variable
+// or file it references, if any. The surrounding whitespace of secrets read
+// from files is trimmed.
+func (s Secret) Resolve() (string, error) {
+	ref := string(s)
+
+	switch {
+	case strings.HasPrefix(ref, SecretEnvPrefix):
+		name := strings.TrimPrefix(ref, SecretEnvPrefix)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret environment variable %s is not set", name)
+		}
+
+		return value, nil
+
+	case strings.HasPrefix(ref, SecretFilePrefix):
+		path := strings.TrimPrefix(ref, SecretFilePrefix)
+		bz, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+
+		return strings.TrimSpace(string(bz)), nil
+
+	default:
+		return ref, nil
+	}
+}
+
+// Reference returns the secret as set in the configuration, unredacted.
+func (s Secret) Reference() string {
+	return string(s)
+}
+
+// String implements the fmt.Stringer interface, redacting inline secrets.
+// References are returned as is.
+func (s Secret) String() string {
+	ref := string(s)
+	if ref == "" || strings.HasPrefix(ref, SecretEnvPrefix) || strings.HasPrefix(ref, SecretFilePrefix) {
+		return ref
+	}
+
+	return "<redacted>"
+}
+
+// MarshalJSON implements the json.Marshaler interface, redacting inline
+// secrets.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// StreamingFirehoseConfig defines the configuration of the firehose service,
+// see streaming.Firehose.
+type StreamingFirehoseConfig struct {
+	// Path is the path of the file the Firehose stream is written to. An
+	// empty path writes it to the standard output.
+	Path string `mapstructure:"path"`
+}
+
+// StreamingAdminConfig defines the configuration of the listener serving the
+// streaming admin endpoints, like pausing destinations or acknowledging the
+// streamed heights.
+type StreamingAdminConfig struct {
+	// Address defines the address the admin endpoints are served on. An empty
+	// address disables them.
+	Address string `mapstructure:"address"`
+
+	// Token is the bearer token the admin requests must carry. It is required
+	// unless the address is a loopback address.
+	Token Secret `mapstructure:"token"`
+}
+
+// IsEnabled returns true if the given streaming service is enabled.
+func (c StreamingConfig) IsEnabled(service string) bool {
+	for _, s := range c.Services {
+		if s == service {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StreamsStore returns true if the state changes of the store with the given
+// name are streamed.
+func (c StreamingConfig) StreamsStore(name string) bool {
+	if len(c.Keys) == 0 {
+		return true
+	}
+
+	for _, key := range c.Keys {
+		if key == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DestinationKind returns the streaming.DestinationKind of the configured
+// kind.
+func (c StreamingDestinationConfig) DestinationKind() (streaming.DestinationKind, error) {
+	switch c.Kind {
+	case "", StreamingDestinationAuto:
+		return streaming.DestinationAuto, nil
+	case StreamingDestinationSocket:
+		return streaming.DestinationUnixSocket, nil
+	case StreamingDestinationFIFO:
+		return streaming.DestinationFIFO, nil
+	default:
+		return 0, fmt.Errorf("unknown streaming destination kind %q, expected one of [%s %s %s]", c.Kind, StreamingDestinationAuto, StreamingDestinationSocket, StreamingDestinationFIFO)
+	}
+}
+
+// PipelineOptions returns the PipelineOptions of the pipelined destinations.
+func (c StreamingConfig) PipelineOptions() streaming.PipelineOptions {
+	return streaming.PipelineOptions{
+		Writers:         c.Pipeline.Writers,
+		InFlightBatches: c.Pipeline.InFlightBatches,
+		MaxBatchBytes:   c.Pipeline.MaxBatchBytes,
+		Linger:          c.Pipeline.Linger,
+	}
+}
+
+// ValidateBasic returns an error if the streaming configuration is invalid.
+func (c StreamingConfig) ValidateBasic() error {
+	if c.ShutdownTimeout < 0 {
+		return fmt.Errorf("invalid streaming shutdown-timeout %s, must not be negative", c.ShutdownTimeout)
+	}
+
+	if err := c.PipelineOptions().Validate(); err != nil {
+		return fmt.Errorf("invalid streaming pipeline configuration: %w", err)
+	}
+
+	for _, service := range c.Services {
+		switch service {
+		case StreamingServiceDestination, StreamingServiceWebhook, StreamingServiceFirehose:
+		default:
+			return fmt.Errorf("unknown streaming service %q, expected one of [%s %s %s]", service, StreamingServiceDestination, StreamingServiceWebhook, StreamingServiceFirehose)
+		}
+	}
+
+	if c.IsEnabled(StreamingServiceDestination) && c.Destination.Path == "" {
+		return errors.New("streaming destination enabled without a path")
+	}
+
+	if _, err := c.Destination.DestinationKind(); err != nil {
+		return err
+	}
+
+	if c.IsEnabled(StreamingServiceWebhook) && len(c.Webhook.URLs) == 0 {
+		return errors.New("streaming webhook enabled without urls")
+	}
+
+	if _, err := c.Webhook.Secret.Resolve(); err != nil {
+		return fmt.Errorf("invalid streaming webhook secret %s: %w", c.Webhook.Secret, err)
+	}
+
+	return c.Admin.ValidateBasic()
+}
+
+// ValidateBasic returns an error if the admin configuration is invalid, or if
+// it serves the admin endpoints beyond the node without a token.
+func (c StreamingAdminConfig) ValidateBasic() error {
+	token, err := c.Token.Resolve()
+	if err != nil {
+		return fmt.Errorf("invalid streaming admin token %s: %w", c.Token, err)
+	}
+
+	if c.Address == "" || token != "" {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(c.Address)
+	if err != nil {
+		return fmt.Errorf("invalid streaming admin address %s: %w", c.Address, err)
+	}
+
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("streaming admin address %s is not a loopback address and requires a token", c.Address)
+	}
+
+	return nil
+}
+
 // Config defines the server's top level configuration
 type Config struct {
 	BaseConfig `mapstructure:",squash"`
@@ -127,6 +438,7 @@ type Config struct {
 	API       APIConfig        `mapstructure:"api"`
 	GRPC      GRPCConfig       `mapstructure:"grpc"`
 	StateSync StateSyncConfig  `mapstructure:"state-sync"`
+	Streaming StreamingConfig  `mapstructure:"streaming"`
 }
 
 // SetMinGasPrices sets the validator's minimum gas prices.
@@ -189,6 +501,29 @@ func DefaultConfig() *Config {
 			SnapshotInterval:   0,
 			SnapshotKeepRecent: 2,
 		},
+		Streaming: StreamingConfig{
+			ShutdownTimeout: 10 * time.Second,
+			Retention:       false,
+			Services:        []string{},
+			Keys:            []string{},
+			Namespace:       false,
+			Metadata:        map[string]string{},
+			Pipeline: StreamingPipelineConfig{
+				Writers:         streaming.DefaultPipelineWriters,
+				InFlightBatches: streaming.DefaultPipelineInFlightBatches,
+				MaxBatchBytes:   streaming.DefaultPipelineMaxBatchBytes,
+				Linger:          streaming.DefaultPipelineLinger,
+			},
+			Destination: StreamingDestinationConfig{
+				Kind: StreamingDestinationAuto,
+			},
+			Webhook: StreamingWebhookConfig{
+				URLs: []string{},
+			},
+			Admin: StreamingAdminConfig{
+				Address: DefaultStreamingAdminAddress,
+			},
+		},
 	}
 }
 
@@ -243,5 +578,34 @@ func GetConfig(v *viper.Viper) Config {
 			SnapshotInterval:   v.GetUint64("state-sync.snapshot-interval"),
 			SnapshotKeepRecent: v.GetUint32("state-sync.snapshot-keep-recent"),
 		},
+		Streaming: StreamingConfig{
+			ShutdownTimeout: v.GetDuration("streaming.shutdown-timeout"),
+			Retention:       v.GetBool("streaming.retention"),
+			Services:        v.GetStringSlice("streaming.services"),
+			Keys:            v.GetStringSlice("streaming.keys"),
+			Namespace:       v.GetBool("streaming.namespace"),
+			Metadata:        v.GetStringMapString("streaming.metadata"),
+			Pipeline: StreamingPipelineConfig{
+				Writers:         v.GetInt("streaming.pipeline.writers"),
+				InFlightBatches: v.GetInt("streaming.pipeline.in-flight-batches"),
+				MaxBatchBytes:   v.GetInt("streaming.pipeline.max-batch-bytes"),
+				Linger:          v.GetDuration("streaming.pipeline.linger"),
+			},
+			Destination: StreamingDestinationConfig{
+				Path: v.GetString("streaming.destination.path"),
+				Kind: v.GetString("streaming.destination.kind"),
+			},
+			Webhook: StreamingWebhookConfig{
+				URLs:   v.GetStringSlice("streaming.webhook.urls"),
+				Secret: Secret(v.GetString("streaming.webhook.secret")),
+			},
+			Firehose: StreamingFirehoseConfig{
+				Path: v.GetString("streaming.firehose.path"),
+			},
+			Admin: StreamingAdminConfig{
+				Address: v.GetString("streaming.admin.address"),
+				Token:   Secret(v.GetString("streaming.admin.token")),
+			},
+		},
 	}
 }
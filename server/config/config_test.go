@@ -1,10 +1,16 @@
 package config
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 
+	"github.com/cosmos/cosmos-sdk/server/streaming"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 )
 
@@ -18,3 +24,97 @@ func TestSetMinimumFees(t *testing.T) {
 	cfg.SetMinGasPrices(sdk.DecCoins{sdk.NewInt64DecCoin("foo", 5)})
 	require.Equal(t, "5.000000000000000000foo", cfg.MinGasPrices)
 }
+
+func TestStreamingConfigValidateBasic(t *testing.T) {
+	cfg := DefaultConfig().Streaming
+	require.NoError(t, cfg.ValidateBasic())
+	require.Equal(t, streaming.DefaultPipelineOptions(), cfg.PipelineOptions())
+
+	cfg.Pipeline.Writers = 0
+	require.Error(t, cfg.ValidateBasic())
+
+	cfg = DefaultConfig().Streaming
+	cfg.Services = []string{StreamingServiceDestination}
+	require.Error(t, cfg.ValidateBasic(), "destination without a path")
+
+	cfg.Destination.Path = "/tmp/streaming.sock"
+	require.NoError(t, cfg.ValidateBasic())
+
+	cfg.Destination.Kind = "pipe"
+	require.Error(t, cfg.ValidateBasic())
+
+	cfg = DefaultConfig().Streaming
+	cfg.Services = []string{"kafka"}
+	require.Error(t, cfg.ValidateBasic())
+
+	// the admin endpoints are only served beyond the node with a token
+	cfg = DefaultConfig().Streaming
+	cfg.Admin.Address = "0.0.0.0:1318"
+	require.Error(t, cfg.ValidateBasic())
+
+	cfg.Admin.Token = "admin"
+	require.NoError(t, cfg.ValidateBasic())
+
+	cfg.Admin = StreamingAdminConfig{Address: "localhost:1318"}
+	require.NoError(t, cfg.ValidateBasic())
+}
+
+func TestStreamingConfigTemplate(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Streaming.Services = []string{StreamingServiceDestination, StreamingServiceWebhook}
+	cfg.Streaming.Keys = []string{"acc", "bank"}
+	cfg.Streaming.Namespace = true
+	cfg.Streaming.Metadata = map[string]string{"moniker": "node0", "region": "eu-west"}
+	cfg.Streaming.Destination.Path = "/tmp/streaming.sock"
+	cfg.Streaming.Webhook.URLs = []string{"https://example.com/hook"}
+
+	path := filepath.Join(t.TempDir(), "app.toml")
+	WriteConfigFile(path, cfg)
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	require.NoError(t, v.ReadInConfig())
+
+	streamingCfg := GetConfig(v).Streaming
+	require.Equal(t, cfg.Streaming, streamingCfg)
+	require.True(t, streamingCfg.IsEnabled(StreamingServiceWebhook))
+	require.False(t, streamingCfg.IsEnabled(StreamingServiceFirehose))
+	require.True(t, streamingCfg.StreamsStore("bank"))
+	require.False(t, streamingCfg.StreamsStore("staking"))
+}
+
+func TestSecret(t *testing.T) {
+	os.Setenv("STREAMING_TEST_SECRET", "from-env")
+	defer os.Unsetenv("STREAMING_TEST_SECRET")
+
+	path := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, ioutil.WriteFile(path, []byte("from-file\n"), 0600))
+
+	for _, tc := range []struct {
+		secret   Secret
+		value    string
+		redacted string
+	}{
+		{"", "", ""},
+		{"inline", "inline", "<redacted>"},
+		{"env:STREAMING_TEST_SECRET", "from-env", "env:STREAMING_TEST_SECRET"},
+		{Secret("file:" + path), "from-file", "file:" + path},
+	} {
+		value, err := tc.secret.Resolve()
+		require.NoError(t, err)
+		require.Equal(t, tc.value, value)
+		require.Equal(t, tc.redacted, tc.secret.String())
+
+		bz, err := json.Marshal(StreamingWebhookConfig{Secret: tc.secret})
+		require.NoError(t, err)
+		require.NotContains(t, string(bz), "inline")
+	}
+
+	_, err := Secret("env:STREAMING_TEST_UNSET").Resolve()
+	require.Error(t, err)
+
+	// unresolvable secrets fail the validation
+	cfg := DefaultConfig().Streaming
+	cfg.Webhook.Secret = Secret("file:" + filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, cfg.ValidateBasic())
+}
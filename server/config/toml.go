@@ -161,6 +161,97 @@ snapshot-interval = {{ .StateSync.SnapshotInterval }}
 
 # snapshot-keep-recent specifies the number of recent snapshots to keep and serve (0 to keep all).
 snapshot-keep-recent = {{ .StateSync.SnapshotKeepRecent }}
+
+###############################################################################
+###                        State Streaming Configuration                    ###
+###############################################################################
+
+[streaming]
+
+# shutdown-timeout is the maximum time to wait for the state streaming listeners to flush
+# on shutdown (0 to wait indefinitely).
+shutdown-timeout = "{{ .Streaming.ShutdownTimeout }}"
+
+# retention retains the heights above the last one acknowledged by the streaming consumers
+# through the /streaming/ack admin endpoint instead of pruning them.
+retention = {{ .Streaming.Retention }}
+
+# services defines the streaming services the state changes are streamed to, among
+# "destination", "webhook" and "firehose", each configured in its subsection below.
+services = [{{ range $i, $s := .Streaming.Services }}{{ if $i }}, {{ end }}{{ printf "%q" $s }}{{ end }}]
+
+# keys defines the names of the stores whose state changes are streamed (empty to stream
+# every store), e.g. ["acc", "bank"].
+keys = [{{ range $i, $k := .Streaming.Keys }}{{ if $i }}, {{ end }}{{ printf "%q" $k }}{{ end }}]
+
+# namespace moves the files the streaming services write to or connect to, i.e. the firehose
+# file and the destination socket or named pipe, into a directory named after the chain ID,
+# so the nodes of several networks sharing a host don't collide.
+namespace = {{ .Streaming.Namespace }}
+
+# The metadata the streamed state changes are stamped with, such as the node moniker, region
+# or custom labels, so the records streamed by a fleet of nodes can be attributed and
+# deduplicated downstream, e.g. moniker = "node0".
+[streaming.metadata]
+{{ range $k, $v := .Streaming.Metadata }}
+{{ printf "%q" $k }} = {{ printf "%q" $v }}{{ end }}
+
+# The pipeline configures the concurrency and batching of the pipelined streaming destinations.
+[streaming.pipeline]
+
+# writers is the number of writers of each pipelined destination (at least 1).
+writers = {{ .Streaming.Pipeline.Writers }}
+
+# in-flight-batches is the number of batches queued for each writer before the writes block.
+in-flight-batches = {{ .Streaming.Pipeline.InFlightBatches }}
+
+# max-batch-bytes is the size in bytes past which a batch of writes is queued for its writer.
+max-batch-bytes = {{ .Streaming.Pipeline.MaxBatchBytes }}
+
+# linger is the longest a write waits for its batch to fill before it is queued (0 to wait
+# for the commit of its block).
+linger = "{{ .Streaming.Pipeline.Linger }}"
+
+# The destination service streams to a co-located consumer through a unix domain socket
+# or a named pipe.
+[streaming.destination]
+
+# path is the path of the socket or named pipe the consumer reads from.
+path = "{{ .Streaming.Destination.Path }}"
+
+# kind is the kind of file at path, one of "auto" (detected on every connection), "socket"
+# or "fifo".
+kind = "{{ .Streaming.Destination.Kind }}"
+
+# The webhook service posts the state changes to HTTP endpoints.
+[streaming.webhook]
+
+# urls are the URLs of the endpoints the state changes are posted to.
+urls = [{{ range $i, $u := .Streaming.Webhook.URLs }}{{ if $i }}, {{ end }}{{ printf "%q" $u }}{{ end }}]
+
+# secret is the secret the requests are signed with (empty to leave them unsigned). Rather than
+# inlining it, it may reference the environment variable or the file holding it, as
+# "env:NAME" or "file:PATH".
+secret = "{{ .Streaming.Webhook.Secret.Reference }}"
+
+# The firehose service writes the blocks in the Firehose format.
+[streaming.firehose]
+
+# path is the path of the file the stream is written to (empty to write it to the standard
+# output).
+path = "{{ .Streaming.Firehose.Path }}"
+
+# The admin listener serves the streaming endpoints which control the node, /streaming/ack,
+# /streaming/pause and /streaming/resume, apart from the public API server.
+[streaming.admin]
+
+# address is the address the admin endpoints are served on (empty to disable them).
+address = "{{ .Streaming.Admin.Address }}"
+
+# token is the bearer token the admin requests must carry, required unless address is a
+# loopback address. Rather than inlining it, it may reference the environment variable or
+# the file holding it, as "env:NAME" or "file:PATH".
+token = "{{ .Streaming.Admin.Token.Reference }}"
 `
 
 var configTemplate *template.Template
@@ -3,7 +3,10 @@ package server
 // DONTCOVER
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"runtime/pprof"
 	"time"
@@ -19,13 +22,17 @@ import (
 	"github.com/tendermint/tendermint/rpc/client/local"
 	"google.golang.org/grpc"
 
+	"github.com/cosmos/cosmos-sdk/baseapp"
 	"github.com/cosmos/cosmos-sdk/client"
 	"github.com/cosmos/cosmos-sdk/client/flags"
 	"github.com/cosmos/cosmos-sdk/server/api"
 	"github.com/cosmos/cosmos-sdk/server/config"
 	servergrpc "github.com/cosmos/cosmos-sdk/server/grpc"
+	"github.com/cosmos/cosmos-sdk/server/streaming"
+	streamingservices "github.com/cosmos/cosmos-sdk/server/streaming/services"
 	"github.com/cosmos/cosmos-sdk/server/types"
 	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/types/rest"
 )
 
 // Tendermint full-node start flags
@@ -63,6 +70,98 @@ const (
 	FlagStateSyncSnapshotKeepRecent = "state-sync.snapshot-keep-recent"
 )
 
+// State streaming-related flags, declared by the streaming services package.
+const (
+	FlagStreamingShutdownTimeout = streamingservices.FlagStreamingShutdownTimeout
+	FlagStreamingRetention       = streamingservices.FlagStreamingRetention
+	FlagStreamingServices        = streamingservices.FlagStreamingServices
+	FlagStreamingKeys            = streamingservices.FlagStreamingKeys
+	FlagStreamingNamespace       = streamingservices.FlagStreamingNamespace
+	FlagStreamingMetadata        = streamingservices.FlagStreamingMetadata
+
+	FlagStreamingPipelineWriters         = streamingservices.FlagStreamingPipelineWriters
+	FlagStreamingPipelineInFlightBatches = streamingservices.FlagStreamingPipelineInFlightBatches
+	FlagStreamingPipelineMaxBatchBytes   = streamingservices.FlagStreamingPipelineMaxBatchBytes
+	FlagStreamingPipelineLinger          = streamingservices.FlagStreamingPipelineLinger
+
+	FlagStreamingDestinationPath = streamingservices.FlagStreamingDestinationPath
+	FlagStreamingDestinationKind = streamingservices.FlagStreamingDestinationKind
+	FlagStreamingWebhookURLs     = streamingservices.FlagStreamingWebhookURLs
+	FlagStreamingWebhookSecret   = streamingservices.FlagStreamingWebhookSecret
+	FlagStreamingFirehosePath    = streamingservices.FlagStreamingFirehosePath
+	FlagStreamingAdminAddress    = streamingservices.FlagStreamingAdminAddress
+	FlagStreamingAdminToken      = streamingservices.FlagStreamingAdminToken
+)
+
+// streamingCloser is implemented by applications that stream state changes
+// and must drain their listeners on graceful shutdown (e.g. BaseApp).
+type streamingCloser interface {
+	CloseStreamingListeners(timeout time.Duration) error
+}
+
+// streamingStatusProvider is implemented by applications reporting the status
+// of their state streaming (e.g. BaseApp).
+type streamingStatusProvider interface {
+	StreamingStatus() baseapp.StreamingStatus
+}
+
+// streamingStatusHandler returns the REST handler reporting the streaming
+// status of the application.
+func streamingStatusHandler(sp streamingStatusProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bz, err := json.Marshal(sp.StreamingStatus())
+		if rest.CheckInternalServerError(w, err) {
+			return
+		}
+
+		rest.PostProcessResponseBare(w, client.Context{}, bz)
+	}
+}
+
+// streamingAcknowledger is implemented by applications whose pruning can wait
+// for the streamed heights to be acknowledged (e.g. BaseApp).
+type streamingAcknowledger interface {
+	AcknowledgeStreamedHeight(height int64)
+}
+
+// streamingAckHandler returns the REST handler acknowledging that the state
+// changes streamed up to the requested height were durably delivered.
+func streamingAckHandler(sa streamingAcknowledger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		height, ok := rest.ParseUint64OrReturnBadRequest(w, r.FormValue("height"))
+		if !ok {
+			return
+		}
+
+		sa.AcknowledgeStreamedHeight(int64(height))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// streamingPauser is implemented by applications whose streaming destinations
+// can be paused and resumed by name (e.g. by embedding a
+// streaming.PauseControl).
+type streamingPauser interface {
+	PauseStreaming(destination string) error
+	ResumeStreaming(destination string) error
+}
+
+// streamingPauseHandler returns the REST handler pausing or resuming the
+// requested streaming destination.
+func streamingPauseHandler(fn func(destination string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := fn(r.FormValue("destination"))
+		if errors.Is(err, streaming.ErrUnknownDestination) {
+			rest.WriteErrorResponse(w, http.StatusNotFound, err.Error())
+			return
+		} else if rest.CheckInternalServerError(w, err) {
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
 // StartCmd runs the service passed in, either stand-alone or in-process with
 // Tendermint.
 func StartCmd(appCreator types.AppCreator, defaultNodeHome string) *cobra.Command {
@@ -98,7 +197,11 @@ which accepts a path for the resulting pprof file.
 			// options accordingly.
 			serverCtx.Viper.BindPFlags(cmd.Flags())
 
-			_, err := GetPruningOptionsFromFlags(serverCtx.Viper)
+			if _, err := GetPruningOptionsFromFlags(serverCtx.Viper); err != nil {
+				return err
+			}
+
+			_, err := streamingservices.GetStreamingConfig(serverCtx.Viper)
 			return err
 		},
 		RunE: func(cmd *cobra.Command, _ []string) error {
@@ -144,6 +247,24 @@ which accepts a path for the resulting pprof file.
 	cmd.Flags().Uint64(FlagStateSyncSnapshotInterval, 0, "State sync snapshot interval")
 	cmd.Flags().Uint32(FlagStateSyncSnapshotKeepRecent, 2, "State sync snapshot to keep")
 
+	cmd.Flags().Duration(FlagStreamingShutdownTimeout, 10*time.Second, "Maximum time to wait for state streaming listeners to flush on shutdown (0 waits indefinitely)")
+	cmd.Flags().Bool(FlagStreamingRetention, false, "Retain the heights above the last one acknowledged by the streaming consumers through the /streaming/ack admin endpoint instead of pruning them")
+	cmd.Flags().Int(FlagStreamingPipelineWriters, streaming.DefaultPipelineWriters, "Number of writers of each pipelined streaming destination")
+	cmd.Flags().Int(FlagStreamingPipelineInFlightBatches, streaming.DefaultPipelineInFlightBatches, "Number of batches queued for each writer of a pipelined streaming destination before the writes block")
+	cmd.Flags().Int(FlagStreamingPipelineMaxBatchBytes, streaming.DefaultPipelineMaxBatchBytes, "Size in bytes past which a batch of writes is queued for its writer")
+	cmd.Flags().Duration(FlagStreamingPipelineLinger, streaming.DefaultPipelineLinger, "Longest a write waits for its batch to fill before it is queued (0 waits for the commit of its block)")
+	cmd.Flags().StringSlice(FlagStreamingServices, []string{}, "Streaming services to stream the state changes to (destination|webhook|firehose)")
+	cmd.Flags().StringSlice(FlagStreamingKeys, []string{}, "Names of the stores whose state changes are streamed (empty streams every store)")
+	cmd.Flags().Bool(FlagStreamingNamespace, false, "Move the files the streaming services write to or connect to into a directory named after the chain ID")
+	cmd.Flags().StringToString(FlagStreamingMetadata, map[string]string{}, "Metadata the streamed state changes are stamped with, such as the node moniker or region (key=value,...)")
+	cmd.Flags().String(FlagStreamingDestinationPath, "", "Path of the unix domain socket or named pipe of the streaming destination")
+	cmd.Flags().String(FlagStreamingDestinationKind, config.StreamingDestinationAuto, "Kind of file of the streaming destination (auto|socket|fifo)")
+	cmd.Flags().StringSlice(FlagStreamingWebhookURLs, []string{}, "URLs of the endpoints the streaming webhook posts the state changes to")
+	cmd.Flags().String(FlagStreamingWebhookSecret, "", "Reference to the secret the streaming webhook signs its requests with (env:NAME|file:PATH)")
+	cmd.Flags().String(FlagStreamingFirehosePath, "", "Path of the file the Firehose stream is written to (empty writes it to the standard output)")
+	cmd.Flags().String(FlagStreamingAdminAddress, config.DefaultStreamingAdminAddress, "Address the streaming admin endpoints are served on (empty disables them)")
+	cmd.Flags().String(FlagStreamingAdminToken, "", "Reference to the bearer token the streaming admin requests must carry, required unless the admin address is a loopback address (env:NAME|file:PATH)")
+
 	// add support for all Tendermint-specific command line options
 	tcmd.AddNodeFlags(cmd)
 	return cmd
@@ -183,6 +304,8 @@ func startStandAlone(ctx *Context, appCreator types.AppCreator) error {
 		if err = svr.Stop(); err != nil {
 			tmos.Exit(err.Error())
 		}
+
+		closeStreamingListeners(ctx, app)
 	}()
 
 	// Wait for SIGINT or SIGTERM signal
@@ -278,6 +401,10 @@ func startInProcess(ctx *Context, clientCtx client.Context, appCreator types.App
 
 		apiSrv = api.New(clientCtx, ctx.Logger.With("module", "api-server"))
 		app.RegisterAPIRoutes(apiSrv, config.API)
+
+		if sp, ok := app.(streamingStatusProvider); ok {
+			apiSrv.Router.HandleFunc("/streaming/status", streamingStatusHandler(sp)).Methods("GET")
+		}
 		errCh := make(chan error)
 
 		go func() {
@@ -301,11 +428,25 @@ func startInProcess(ctx *Context, clientCtx client.Context, appCreator types.App
 		}
 	}
 
+	streamingCfg, err := streamingservices.GetStreamingConfig(ctx.Viper)
+	if err != nil {
+		return err
+	}
+
+	adminSrv, err := startStreamingAdmin(ctx, app, streamingCfg.Admin)
+	if err != nil {
+		return err
+	}
+
 	defer func() {
 		if tmNode.IsRunning() {
 			_ = tmNode.Stop()
 		}
 
+		// the node no longer processes blocks, so it is safe to drain the
+		// streaming listeners
+		closeStreamingListeners(ctx, app)
+
 		if cpuProfileCleanup != nil {
 			cpuProfileCleanup()
 		}
@@ -318,9 +459,27 @@ func startInProcess(ctx *Context, clientCtx client.Context, appCreator types.App
 			grpcSrv.Stop()
 		}
 
+		if adminSrv != nil {
+			_ = adminSrv.Close()
+		}
+
 		ctx.Logger.Info("exiting...")
 	}()
 
 	// Wait for SIGINT or SIGTERM signal
 	return WaitForQuitSignals()
 }
+
+// closeStreamingListeners drains the application's streaming listeners, if
+// any, waiting at most for the configured shutdown timeout.
+func closeStreamingListeners(ctx *Context, app types.Application) {
+	sc, ok := app.(streamingCloser)
+	if !ok {
+		return
+	}
+
+	timeout := ctx.Viper.GetDuration(FlagStreamingShutdownTimeout)
+	if err := sc.CloseStreamingListeners(timeout); err != nil {
+		ctx.Logger.Error("failed to close streaming listeners", "err", err)
+	}
+}
@@ -0,0 +1,194 @@
+package streaming
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// DefaultMaxAlertsPerBlock is the default number of alerts notified per
+// block, the others being summarized in a single message.
+const DefaultMaxAlertsPerBlock = 10
+
+var (
+	_ types.WriteListener    = (*Alerter)(nil)
+	_ baseapp.CommitListener = (*Alerter)(nil)
+)
+
+// AlertRule raises an alert for the writes it matches. Only the writes to the
+// store named StoreKey, any if empty, with keys starting with KeyPrefix are
+// passed to Match, which returns the alert message and whether the write
+// matches. Match is typically a closure decoding the value with the app's
+// codec; it may keep state, e.g. the previous balance of an address, as the
+// writes are passed to it in order.
+type AlertRule struct {
+	Name      string
+	StoreKey  string
+	KeyPrefix []byte
+	Match     func(event Event) (string, bool)
+}
+
+// matches returns the alert message if the rule matches the write.
+func (r AlertRule) matches(event Event) (string, bool) {
+	if r.StoreKey != "" && r.StoreKey != event.StoreKey {
+		return "", false
+	}
+
+	if !bytes.HasPrefix(event.Key, r.KeyPrefix) {
+		return "", false
+	}
+
+	return r.Match(event)
+}
+
+// Notifier sends alert messages to a chat service.
+type Notifier interface {
+	Notify(message string) error
+}
+
+// webhookNotifier posts the JSON encoding of the message built by body to a
+// chat webhook URL.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+	body   func(message string) interface{}
+}
+
+// NewSlackNotifier returns a Notifier posting to a Slack incoming webhook.
+func NewSlackNotifier(webhookURL string) Notifier {
+	return &webhookNotifier{
+		url:    webhookURL,
+		client: &http.Client{Timeout: DefaultWebhookTimeout},
+		body:   func(message string) interface{} { return map[string]string{"text": message} },
+	}
+}
+
+// NewDiscordNotifier returns a Notifier posting to a Discord webhook.
+func NewDiscordNotifier(webhookURL string) Notifier {
+	return &webhookNotifier{
+		url:    webhookURL,
+		client: &http.Client{Timeout: DefaultWebhookTimeout},
+		body:   func(message string) interface{} { return map[string]string{"content": message} },
+	}
+}
+
+// NewTelegramNotifier returns a Notifier sending messages to a Telegram chat
+// through the Bot API.
+func NewTelegramNotifier(botToken, chatID string) Notifier {
+	return &webhookNotifier{
+		url:    fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", url.PathEscape(botToken)),
+		client: &http.Client{Timeout: DefaultWebhookTimeout},
+		body: func(message string) interface{} {
+			return map[string]string{"chat_id": chatID, "text": message}
+		},
+	}
+}
+
+// Notify implements the Notifier interface.
+func (n *webhookNotifier) Notify(message string) error {
+	bz, err := json.Marshal(n.body(message))
+	if err != nil {
+		return err
+	}
+
+	res, err := n.client.Post(n.url, "application/json", bytes.NewReader(bz))
+	if err != nil {
+		return redactURLError(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("notification rejected: %s", res.Status)
+	}
+
+	return nil
+}
+
+// Alerter is a WriteListener and CommitListener evaluating AlertRules over the
+// streamed writes. The alerts raised by the writes of a block are sent to
+// every Notifier once the block is committed, up to a maximum number of
+// alerts per block so a burst of matching writes can't flood the chats.
+//
+// Notifications are sent synchronously, so the BaseApp should stream in async
+// mode.
+type Alerter struct {
+	mtx       sync.Mutex
+	rules     []AlertRule
+	notifiers []Notifier
+	maxAlerts int
+	alerts    []string
+	err       error
+}
+
+// NewAlerter returns a new Alerter evaluating the rules and sending their
+// alerts to the notifiers.
+func NewAlerter(rules []AlertRule, notifiers ...Notifier) *Alerter {
+	return &Alerter{
+		rules:     rules,
+		notifiers: notifiers,
+		maxAlerts: DefaultMaxAlertsPerBlock,
+	}
+}
+
+// SetMaxAlertsPerBlock sets the number of alerts notified per block.
+func (a *Alerter) SetMaxAlertsPerBlock(maxAlerts int) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.maxAlerts = maxAlerts
+}
+
+// OnWrite implements the WriteListener interface.
+func (a *Alerter) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	event := writeEvent(storeKey, key, value)
+	for _, rule := range a.rules {
+		if msg, ok := rule.matches(event); ok {
+			a.alerts = append(a.alerts, fmt.Sprintf("%s: %s", rule.Name, msg))
+		}
+	}
+}
+
+// OnCommit implements the baseapp CommitListener interface. It notifies the
+// alerts raised by the writes of the block.
+func (a *Alerter) OnCommit(height int64) {
+	a.mtx.Lock()
+	alerts := a.alerts
+	a.alerts = nil
+	maxAlerts := a.maxAlerts
+	a.mtx.Unlock()
+
+	if len(alerts) == 0 {
+		return
+	}
+
+	if maxAlerts > 0 && len(alerts) > maxAlerts {
+		alerts = append(alerts[:maxAlerts], fmt.Sprintf("%d more alerts", len(alerts)-maxAlerts))
+	}
+
+	message := fmt.Sprintf("Block %d\n%s", height, strings.Join(alerts, "\n"))
+	for _, n := range a.notifiers {
+		if err := n.Notify(message); err != nil {
+			a.mtx.Lock()
+			a.err = fmt.Errorf("failed to notify the alerts of block %d: %w", height, err)
+			a.mtx.Unlock()
+		}
+	}
+}
+
+// Err returns the last error encountered sending a notification.
+func (a *Alerter) Err() error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.err
+}
@@ -0,0 +1,73 @@
+package streaming
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockNotifier struct {
+	messages []string
+}
+
+func (n *mockNotifier) Notify(message string) error {
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+func TestAlerter(t *testing.T) {
+	rules := []AlertRule{
+		{
+			Name:      "balance",
+			StoreKey:  "bank",
+			KeyPrefix: []byte("balance/"),
+			Match: func(event Event) (string, bool) {
+				return string(event.Key) + " changed", len(event.Value) > 1
+			},
+		},
+		{
+			Name:  "deleted",
+			Match: func(event Event) (string, bool) { return string(event.Key), event.Delete },
+		},
+	}
+
+	notifier := &mockNotifier{}
+	a := NewAlerter(rules, notifier)
+	a.SetMaxAlertsPerBlock(2)
+
+	a.OnWrite(bankKey, []byte("balance/addr1"), []byte("10"))
+	a.OnWrite(bankKey, []byte("balance/addr2"), []byte("1"))
+	a.OnWrite(accKey, []byte("balance/addr3"), []byte("10"))
+	a.OnCommit(1)
+
+	// blocks without alerts are not notified
+	a.OnWrite(bankKey, []byte("balance/addr1"), []byte("1"))
+	a.OnCommit(2)
+
+	a.OnWrite(bankKey, []byte("balance/addr1"), []byte("10"))
+	a.OnWrite(accKey, []byte("addr1"), nil)
+	a.OnWrite(accKey, []byte("addr2"), nil)
+	a.OnCommit(3)
+
+	require.NoError(t, a.Err())
+	require.Equal(t, []string{
+		"Block 1\nbalance: balance/addr1 changed",
+		"Block 3\nbalance: balance/addr1 changed\ndeleted: addr1\n1 more alerts",
+	}, notifier.messages)
+}
+
+func TestSlackNotifier(t *testing.T) {
+	var body map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer srv.Close()
+
+	require.NoError(t, NewSlackNotifier(srv.URL).Notify("alert"))
+	require.Equal(t, map[string]string{"text": "alert"}, body)
+
+	require.Error(t, NewDiscordNotifier(srv.URL+"/%zz").Notify("alert"))
+}
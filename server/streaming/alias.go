@@ -0,0 +1,135 @@
+package streaming
+
+import (
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+var (
+	_ types.WriteListener    = (*StoreAliases)(nil)
+	_ baseapp.CommitListener = (*StoreAliases)(nil)
+	_ io.Closer              = (*StoreAliases)(nil)
+)
+
+// StoreAlias maps the name of a store to the stable logical name of its
+// records, e.g. the name of a store before it was renamed by a store upgrade.
+type StoreAlias struct {
+	StoreKey    string `json:"store_key"`
+	LogicalName string `json:"logical_name"`
+}
+
+// StoreAliasListener is implemented by sinks notified of the store aliases in
+// effect, so the consumers can keep their topics and tables continuous across
+// store upgrades.
+type StoreAliasListener interface {
+	OnStoreAliases(height int64, aliases []StoreAlias)
+}
+
+// StoreAliases is a WriteListener passing the writes on to a sink, notifying
+// it of the store aliases in effect along with the first commit, if it
+// implements StoreAliasListener. With rewriting enabled, the writes to the
+// aliased stores are passed on under their logical names.
+type StoreAliases struct {
+	mtx      sync.Mutex
+	sink     types.WriteListener
+	aliases  map[string]string
+	keys     map[string]types.StoreKey
+	rewrite  bool
+	notified bool
+}
+
+// NewStoreAliases returns a new StoreAliases passing the writes on to sink,
+// aliasing every store renamed by the upgrades, if any, to its name before
+// the upgrade.
+func NewStoreAliases(sink types.WriteListener, upgrades *types.StoreUpgrades) *StoreAliases {
+	a := &StoreAliases{
+		sink:    sink,
+		aliases: make(map[string]string),
+		keys:    make(map[string]types.StoreKey),
+	}
+
+	if upgrades != nil {
+		for _, rename := range upgrades.Renamed {
+			a.Alias(rename.NewKey, rename.OldKey)
+		}
+	}
+
+	return a
+}
+
+// Alias aliases the store to the logical name. A store renamed several times
+// is aliased to the logical name of its first name.
+func (a *StoreAliases) Alias(storeKey, logicalName string) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if name, ok := a.aliases[logicalName]; ok {
+		logicalName = name
+	}
+
+	a.aliases[storeKey] = logicalName
+	a.notified = false
+}
+
+// SetRewrite sets whether the writes to the aliased stores are passed on
+// under their logical names.
+func (a *StoreAliases) SetRewrite(rewrite bool) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.rewrite = rewrite
+}
+
+// OnWrite implements the WriteListener interface.
+func (a *StoreAliases) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	a.mtx.Lock()
+	if name, ok := a.aliases[storeKey.Name()]; ok && a.rewrite {
+		logical, ok := a.keys[name]
+		if !ok {
+			logical = types.NewKVStoreKey(name)
+			a.keys[name] = logical
+		}
+		storeKey = logical
+	}
+	a.mtx.Unlock()
+
+	a.sink.OnWrite(storeKey, key, value)
+}
+
+// OnCommit implements the baseapp CommitListener interface. It notifies the
+// sink of the aliases in effect on the first commit, and after they change,
+// then passes the commit on.
+func (a *StoreAliases) OnCommit(height int64) {
+	a.mtx.Lock()
+	var aliases []StoreAlias
+	if !a.notified {
+		a.notified = true
+		for storeKey, name := range a.aliases {
+			aliases = append(aliases, StoreAlias{StoreKey: storeKey, LogicalName: name})
+		}
+		sort.Slice(aliases, func(i, j int) bool { return aliases[i].StoreKey < aliases[j].StoreKey })
+	}
+	a.mtx.Unlock()
+
+	if al, ok := a.sink.(StoreAliasListener); ok && len(aliases) > 0 {
+		al.OnStoreAliases(height, aliases)
+	}
+
+	if cl, ok := a.sink.(baseapp.CommitListener); ok {
+		cl.OnCommit(height)
+	}
+}
+
+// Close implements the io.Closer interface. It closes the sink if it
+// implements io.Closer.
+func (a *StoreAliases) Close() error {
+	if closer, ok := a.sink.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
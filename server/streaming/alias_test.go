@@ -0,0 +1,44 @@
+package streaming
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+type aliasListener struct {
+	routedListener
+	notices [][]StoreAlias
+}
+
+func (l *aliasListener) OnStoreAliases(_ int64, aliases []StoreAlias) {
+	l.notices = append(l.notices, aliases)
+}
+
+func TestStoreAliases(t *testing.T) {
+	sink := &aliasListener{}
+	a := NewStoreAliases(sink, &types.StoreUpgrades{
+		Renamed: []types.StoreRename{{OldKey: "bank", NewKey: "bank2"}},
+	})
+	a.Alias("bank3", "bank2")
+
+	a.OnWrite(types.NewKVStoreKey("bank2"), []byte("a"), []byte("1"))
+	a.OnCommit(1)
+	a.OnCommit(2)
+	require.Equal(t, []string{"bank2/a"}, sink.writes)
+	require.Equal(t, 2, sink.commits)
+	require.Equal(t, [][]StoreAlias{{
+		{StoreKey: "bank2", LogicalName: "bank"},
+		{StoreKey: "bank3", LogicalName: "bank"},
+	}}, sink.notices, "the aliases should be notified once")
+
+	a.SetRewrite(true)
+	a.OnWrite(types.NewKVStoreKey("bank3"), []byte("b"), []byte("1"))
+	a.OnWrite(accKey, []byte("c"), []byte("1"))
+	require.Equal(t, []string{"bank2/a", "bank/b", "acc/c"}, sink.writes)
+
+	require.NoError(t, a.Close())
+	require.True(t, sink.closed)
+}
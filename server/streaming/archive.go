@@ -0,0 +1,176 @@
+package streaming
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// DefaultArchivePrefixLength is the default length of the key prefixes
+// recorded in the bloom filters of an archive index.
+const DefaultArchivePrefixLength = 2
+
+// ArchiveIndexEntry locates a block in a Firehose archive. Prefixes is a
+// bloom filter of the store keys of the writes of the block, combined with
+// every prefix of their keys up to PrefixLength bytes long.
+type ArchiveIndexEntry struct {
+	Height       int64  `json:"height"`
+	Offset       int64  `json:"offset"`
+	Length       int64  `json:"length"`
+	PrefixLength int    `json:"prefix_length"`
+	Prefixes     *Bloom `json:"prefixes"`
+}
+
+// mayTouch returns false if no write of the block is to a key of the store
+// starting with the prefix.
+func (e ArchiveIndexEntry) mayTouch(storeKey string, prefix []byte) bool {
+	return mayTouch(e.Prefixes, e.PrefixLength, storeKey, prefix)
+}
+
+// BuildArchiveIndex reads a Firehose archive, i.e. the output of a Firehose
+// written to a file, and writes the index of its blocks to index as JSON
+// lines of ArchiveIndexEntry, in the order of the archive. Key prefixes of up
+// to prefixLength bytes are recorded in the bloom filters of the blocks.
+func BuildArchiveIndex(archive io.Reader, index io.Writer, prefixLength int) error {
+	r := bufio.NewReader(archive)
+	enc := json.NewEncoder(index)
+
+	for offset := int64(0); ; {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		} else if err != nil && err != io.EOF {
+			return err
+		}
+
+		block, ok, err := parseFirehoseLine(string(line))
+		if err != nil {
+			return fmt.Errorf("invalid archive line at offset %d: %w", offset, err)
+		}
+
+		if ok {
+			entry, err := indexBlock(block, prefixLength)
+			if err != nil {
+				return err
+			}
+
+			entry.Offset, entry.Length = offset, int64(len(line))
+			if err := enc.Encode(entry); err != nil {
+				return err
+			}
+		}
+
+		offset += int64(len(line))
+	}
+}
+
+// indexBlock returns the index entry of the block, without its location.
+func indexBlock(block FirehoseBlock, prefixLength int) (ArchiveIndexEntry, error) {
+	records, err := block.Records()
+	if err != nil {
+		return ArchiveIndexEntry{}, fmt.Errorf("invalid block %d: %w", block.Height, err)
+	}
+
+	prefixes := make(prefixSet)
+	for _, record := range records {
+		prefixes.add(record.StoreKey, record.Key, prefixLength)
+	}
+
+	return ArchiveIndexEntry{Height: block.Height, PrefixLength: prefixLength, Prefixes: prefixes.bloom()}, nil
+}
+
+// ArchiveRecord is a write read from a Firehose archive.
+type ArchiveRecord struct {
+	Height int64 `json:"height"`
+	types.FrameRecord
+}
+
+// Archive reads the writes of a Firehose archive by height and key prefix,
+// reading from the archive only the blocks whose index entry may contain the
+// prefix.
+type Archive struct {
+	file  *os.File
+	index []ArchiveIndexEntry
+}
+
+// OpenArchive opens the Firehose archive at path, indexed by the index at
+// indexPath written by BuildArchiveIndex.
+func OpenArchive(path, indexPath string) (*Archive, error) {
+	indexFile, err := os.Open(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer indexFile.Close()
+
+	var index []ArchiveIndexEntry
+	for dec := json.NewDecoder(indexFile); ; {
+		var entry ArchiveIndexEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid archive index %s: %w", indexPath, err)
+		}
+
+		if entry.Prefixes == nil {
+			return nil, fmt.Errorf("invalid archive index %s: block %d without prefixes", indexPath, entry.Height)
+		}
+
+		index = append(index, entry)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Archive{file: file, index: index}, nil
+}
+
+// Query returns the writes to the keys of the store starting with the prefix
+// committed in the blocks from fromHeight to toHeight, both included, in the
+// order of the archive.
+func (a *Archive) Query(storeKey string, prefix []byte, fromHeight, toHeight int64) ([]ArchiveRecord, error) {
+	var result []ArchiveRecord
+
+	for _, entry := range a.index {
+		if entry.Height < fromHeight || entry.Height > toHeight || !entry.mayTouch(storeKey, prefix) {
+			continue
+		}
+
+		line := make([]byte, entry.Length)
+		if _, err := a.file.ReadAt(line, entry.Offset); err != nil {
+			return nil, fmt.Errorf("failed to read block %d: %w", entry.Height, err)
+		}
+
+		block, ok, err := parseFirehoseLine(string(line))
+		if err == nil && (!ok || block.Height != entry.Height) {
+			err = fmt.Errorf("archive index does not match the archive at offset %d", entry.Offset)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		records, err := block.Records()
+		if err != nil {
+			return nil, fmt.Errorf("invalid block %d: %w", block.Height, err)
+		}
+
+		for _, record := range records {
+			if record.StoreKey == storeKey && bytes.HasPrefix(record.Key, prefix) {
+				result = append(result, ArchiveRecord{Height: block.Height, FrameRecord: record})
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Close implements the io.Closer interface.
+func (a *Archive) Close() error {
+	return a.file.Close()
+}
@@ -0,0 +1,73 @@
+package streaming
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	stream := firehoseStream(1,
+		[]types.FrameRecord{{StoreKey: "bank", Key: []byte("balances/a"), Value: []byte("1")}},
+		[]types.FrameRecord{{StoreKey: "acc", Key: []byte("a"), Value: []byte("1")}},
+		[]types.FrameRecord{
+			{StoreKey: "bank", Key: []byte("supply/stake"), Value: []byte("1")},
+			{StoreKey: "bank", Key: []byte("balances/b"), Delete: true},
+		},
+	)
+
+	path, indexPath := filepath.Join(dir, "archive"), filepath.Join(dir, "archive.index")
+	require.NoError(t, ioutil.WriteFile(path, stream.Bytes(), 0600))
+
+	var index bytes.Buffer
+	require.NoError(t, BuildArchiveIndex(bytes.NewReader(stream.Bytes()), &index, 2))
+	require.NoError(t, ioutil.WriteFile(indexPath, index.Bytes(), 0600))
+
+	archive, err := OpenArchive(path, indexPath)
+	require.NoError(t, err)
+	defer archive.Close()
+	require.Len(t, archive.index, 3)
+
+	// blocks not writing to the prefix are skipped using the index
+	require.False(t, archive.index[1].mayTouch("bank", []byte("balances/")))
+	require.True(t, archive.index[2].mayTouch("bank", []byte("ba")))
+	require.True(t, archive.index[2].mayTouch("bank", nil))
+
+	records, err := archive.Query("bank", []byte("balances/"), 1, 3)
+	require.NoError(t, err)
+	require.Equal(t, []ArchiveRecord{
+		{Height: 1, FrameRecord: types.FrameRecord{StoreKey: "bank", Key: []byte("balances/a"), Value: []byte("1")}},
+		{Height: 3, FrameRecord: types.FrameRecord{StoreKey: "bank", Key: []byte("balances/b"), Delete: true}},
+	}, records)
+
+	records, err = archive.Query("bank", []byte("balances/"), 2, 3)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, int64(3), records[0].Height)
+}
+
+func TestBloom(t *testing.T) {
+	b := NewBloom(100, 0.01)
+	for i := 0; i < 100; i++ {
+		b.Add([]byte{byte(i), 0})
+	}
+
+	falsePositives := 0
+	for i := 0; i < 100; i++ {
+		require.True(t, b.MayContain([]byte{byte(i), 0}))
+		if b.MayContain([]byte{byte(i), 1}) {
+			falsePositives++
+		}
+	}
+	require.Less(t, falsePositives, 10)
+}
@@ -0,0 +1,93 @@
+package streaming
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// AllStores may be returned by an Authorizer to allow streaming every store.
+const AllStores = "*"
+
+var (
+	// ErrUnauthenticated is returned when a request carries no valid credentials.
+	ErrUnauthenticated = errors.New("streaming: unauthenticated")
+	// ErrForbidden is returned when a request is not allowed to stream a store.
+	ErrForbidden = errors.New("streaming: forbidden")
+)
+
+// Authorizer authenticates streaming requests and returns the names of the
+// stores they are allowed to stream. AllStores allows every store. Identify
+// returns the identity an authorized request authenticated as, which the
+// Server counts the subscriptions of its clients by.
+type Authorizer interface {
+	Authorize(r *http.Request) ([]string, error)
+	Identify(r *http.Request) string
+}
+
+// TokenAuthorizer authorizes requests by API token. The token is read from
+// the "Authorization: Bearer <token>" header or, as browsers can't set headers
+// on an EventSource, from the "token" query parameter. It maps each token to
+// the stores it may stream.
+type TokenAuthorizer map[string][]string
+
+var _ Authorizer = TokenAuthorizer{}
+
+// Authorize implements the Authorizer interface.
+func (a TokenAuthorizer) Authorize(r *http.Request) ([]string, error) {
+	token := requestToken(r)
+
+	stores, ok := a[token]
+	if token == "" || !ok {
+		return nil, ErrUnauthenticated
+	}
+
+	return stores, nil
+}
+
+// Identify implements the Authorizer interface. It returns the API token of
+// the request.
+func (a TokenAuthorizer) Identify(r *http.Request) string {
+	return requestToken(r)
+}
+
+// requestToken returns the API token of the request, read from its
+// Authorization header or else from its "token" query parameter.
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+
+	return r.URL.Query().Get("token")
+}
+
+// CertAuthorizer authorizes requests by mTLS client certificate. It maps the
+// subject common name of the verified client certificate to the stores it may
+// stream. The server must be configured to verify client certificates.
+type CertAuthorizer map[string][]string
+
+var _ Authorizer = CertAuthorizer{}
+
+// Authorize implements the Authorizer interface.
+func (a CertAuthorizer) Authorize(r *http.Request) ([]string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrUnauthenticated
+	}
+
+	stores, ok := a[a.Identify(r)]
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+
+	return stores, nil
+}
+
+// Identify implements the Authorizer interface. It returns the subject common
+// name of the verified client certificate of the request.
+func (a CertAuthorizer) Identify(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName
+}
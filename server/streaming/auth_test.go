@@ -0,0 +1,77 @@
+package streaming
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenAuthorizer(t *testing.T) {
+	a := TokenAuthorizer{
+		"public": {"bank"},
+		"admin":  {AllStores},
+		"none":   {},
+	}
+
+	testCases := []struct {
+		name    string
+		target  string
+		token   string
+		status  int
+		follows []string
+	}{
+		{"no token", "/", "", http.StatusUnauthorized, nil},
+		{"unknown token", "/", "secret", http.StatusUnauthorized, nil},
+		{"no allowed stores", "/", "none", http.StatusForbidden, nil},
+		{"disallowed store", "/?store=acc", "public", http.StatusForbidden, nil},
+		{"allowed store", "/?store=bank", "public", http.StatusOK, []string{"bank"}},
+		{"defaults to allowed stores", "/", "public", http.StatusOK, []string{"bank"}},
+		{"token in query", "/?token=public", "", http.StatusOK, []string{"bank"}},
+		{"all stores", "/?store=acc", "admin", http.StatusOK, []string{"acc"}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+
+			stores, status, err := authorizedStores(r, a)
+			require.Equal(t, tc.status, status)
+			if tc.status != http.StatusOK {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.follows, stores)
+		})
+	}
+}
+
+func TestCertAuthorizer(t *testing.T) {
+	a := CertAuthorizer{"indexer": {"bank"}}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err := a.Authorize(r)
+	require.True(t, errors.Is(err, ErrUnauthenticated))
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "indexer"}}
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	stores, err := a.Authorize(r)
+	require.NoError(t, err)
+	require.Equal(t, []string{"bank"}, stores)
+	require.Equal(t, "indexer", a.Identify(r))
+
+	cert.Subject.CommonName = "unknown"
+	_, err = a.Authorize(r)
+	require.True(t, errors.Is(err, ErrUnauthenticated))
+}
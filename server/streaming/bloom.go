@@ -0,0 +1,118 @@
+package streaming
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// prefixFalsePositiveRate is the false positive rate of the bloom filters of
+// the key prefixes written to in a block.
+const prefixFalsePositiveRate = 0.01
+
+// Bloom is a bloom filter, answering whether an item may have been added to
+// it with no false negatives and a bounded rate of false positives.
+type Bloom struct {
+	Bits   []byte `json:"bits"`
+	Hashes uint32 `json:"hashes"`
+}
+
+// NewBloom returns a new Bloom sized for n items with the given false
+// positive rate.
+func NewBloom(n int, falsePositiveRate float64) *Bloom {
+	if n < 1 {
+		n = 1
+	}
+
+	bits := math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	hashes := math.Round(bits / float64(n) * math.Ln2)
+	if hashes < 1 {
+		hashes = 1
+	}
+
+	return &Bloom{
+		Bits:   make([]byte, (int(bits)+7)/8),
+		Hashes: uint32(hashes),
+	}
+}
+
+// Add adds the item to the filter.
+func (b *Bloom) Add(item []byte) {
+	b.forEachBit(item, func(i uint64) bool {
+		b.Bits[i/8] |= 1 << (i % 8)
+		return true
+	})
+}
+
+// MayContain returns false if the item was never added to the filter.
+func (b *Bloom) MayContain(item []byte) bool {
+	return b.forEachBit(item, func(i uint64) bool {
+		return b.Bits[i/8]&(1<<(i%8)) != 0
+	})
+}
+
+// forEachBit calls fn with the index of every bit of the item, derived by
+// double hashing, until fn returns false. It returns false if fn did.
+func (b *Bloom) forEachBit(item []byte, fn func(i uint64) bool) bool {
+	m := uint64(len(b.Bits)) * 8
+	if m == 0 {
+		return false
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write(item)
+	h1 := h.Sum64()
+
+	h = fnv.New64()
+	_, _ = h.Write(item)
+	h2 := h.Sum64() | 1
+
+	for i := uint64(0); i < uint64(b.Hashes); i++ {
+		if !fn((h1 + i*h2) % m) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// prefixSet is the set of the bloom filter items of the key prefixes written
+// to in a block.
+type prefixSet map[string]struct{}
+
+// add adds the store key along with every prefix of the key up to
+// prefixLength bytes long to the set.
+func (s prefixSet) add(storeKey string, key []byte, prefixLength int) {
+	for n := 0; n <= prefixLength && n <= len(key); n++ {
+		s[string(prefixItem(storeKey, key[:n]))] = struct{}{}
+	}
+}
+
+// bloom returns a bloom filter of the items of the set.
+func (s prefixSet) bloom() *Bloom {
+	b := NewBloom(len(s), prefixFalsePositiveRate)
+	for item := range s {
+		b.Add([]byte(item))
+	}
+
+	return b
+}
+
+// mayTouch returns false if the bloom filter of the key prefixes of up to
+// prefixLength bytes written to in a block proves that no key of the store
+// starting with the prefix was written to.
+func mayTouch(prefixes *Bloom, prefixLength int, storeKey string, prefix []byte) bool {
+	if len(prefix) > prefixLength {
+		prefix = prefix[:prefixLength]
+	}
+
+	return prefixes.MayContain(prefixItem(storeKey, prefix))
+}
+
+// prefixItem returns the bloom filter item of a key prefix of a store.
+func prefixItem(storeKey string, prefix []byte) []byte {
+	item := make([]byte, 0, len(storeKey)+1+len(prefix))
+	item = append(item, storeKey...)
+	item = append(item, 0)
+
+	return append(item, prefix...)
+}
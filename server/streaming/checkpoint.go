@@ -0,0 +1,190 @@
+package streaming
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// CheckpointStore records the height of the last block fully delivered by a
+// sink, so the sink can resume after it on restart.
+type CheckpointStore interface {
+	// Load returns the recorded height, 0 if none was recorded.
+	Load() (int64, error)
+	// Save records the height.
+	Save(height int64) error
+}
+
+var _ CheckpointStore = (*FileCheckpoint)(nil)
+
+// FileCheckpoint is a CheckpointStore recording the height in a file. The file
+// is replaced atomically, so a crash while saving leaves the previous height.
+type FileCheckpoint struct {
+	path string
+}
+
+// NewFileCheckpoint returns a new FileCheckpoint recording the height in the
+// file at path.
+func NewFileCheckpoint(path string) *FileCheckpoint {
+	return &FileCheckpoint{path: path}
+}
+
+// Load implements the CheckpointStore interface.
+func (c *FileCheckpoint) Load() (int64, error) {
+	bz, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	height, err := strconv.ParseInt(strings.TrimSpace(string(bz)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid checkpoint %s: %w", c.path, err)
+	}
+
+	return height, nil
+}
+
+// Save implements the CheckpointStore interface.
+func (c *FileCheckpoint) Save(height int64) error {
+	return writeFileAtomic(c.path, []byte(strconv.FormatInt(height, 10)+"\n"))
+}
+
+// writeFileAtomic writes the data to a temporary file synced to disk, then
+// renames it to path, so path holds either its previous or its new content.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+var (
+	_ types.WriteListener    = (*Checkpointed)(nil)
+	_ baseapp.CommitListener = (*Checkpointed)(nil)
+	_ io.Closer              = (*Checkpointed)(nil)
+)
+
+// Checkpointed is a WriteListener and CommitListener delivering the writes of
+// every block to a sink, recording the height of every block fully delivered
+// in a CheckpointStore. Blocks at or below the recorded height, replayed by
+// the node after a crash, are not delivered again.
+//
+// The writes of a block are held until its commit, then passed to the sink
+// followed by its OnCommit. Sinks reporting their failures through an Err
+// method, such as the Webhook and the Firehose, stop the checkpoint from
+// advancing once Err returns an error, so the checkpoint keeps the last block
+// delivered before the failure and the following blocks can be backfilled.
+type Checkpointed struct {
+	mtx        sync.Mutex
+	sink       types.WriteListener
+	checkpoint CheckpointStore
+	height     int64
+	pending    []baseapp.StoreKVPair
+	err        error
+}
+
+// NewCheckpointed returns a new Checkpointed delivering the writes to sink,
+// resuming after the height recorded in checkpoint.
+func NewCheckpointed(sink types.WriteListener, checkpoint CheckpointStore) (*Checkpointed, error) {
+	height, err := checkpoint.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Checkpointed{sink: sink, checkpoint: checkpoint, height: height}, nil
+}
+
+// OnWrite implements the WriteListener interface.
+func (c *Checkpointed) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.pending = append(c.pending, baseapp.StoreKVPair{StoreKey: storeKey, Key: key, Value: value})
+}
+
+// OnCommit implements the baseapp CommitListener interface. It delivers the
+// writes of the block to the sink, unless the block is at or below the
+// checkpoint, then records its height.
+func (c *Checkpointed) OnCommit(height int64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	pending := c.pending
+	c.pending = nil
+
+	if height <= c.height {
+		return
+	}
+
+	for _, kv := range pending {
+		c.sink.OnWrite(kv.StoreKey, kv.Key, kv.Value)
+	}
+
+	if cl, ok := c.sink.(baseapp.CommitListener); ok {
+		cl.OnCommit(height)
+	}
+
+	if er, ok := c.sink.(interface{ Err() error }); ok && er.Err() != nil {
+		return
+	}
+
+	if err := c.checkpoint.Save(height); err != nil {
+		c.err = fmt.Errorf("failed to save checkpoint at height %d: %w", height, err)
+		return
+	}
+
+	c.height = height
+}
+
+// Height returns the height of the last block fully delivered to the sink.
+func (c *Checkpointed) Height() int64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.height
+}
+
+// Err returns the last error encountered saving the checkpoint.
+func (c *Checkpointed) Err() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.err
+}
+
+// Close implements the io.Closer interface. It closes the sink if it
+// implements io.Closer.
+func (c *Checkpointed) Close() error {
+	if closer, ok := c.sink.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
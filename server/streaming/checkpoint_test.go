@@ -0,0 +1,77 @@
+package streaming
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type failingSink struct {
+	routedListener
+	err error
+}
+
+func (s *failingSink) Err() error { return s.err }
+
+func TestCheckpointed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	checkpoint := NewFileCheckpoint(filepath.Join(dir, "checkpoint"))
+	height, err := checkpoint.Load()
+	require.NoError(t, err)
+	require.Zero(t, height)
+
+	sink := &failingSink{}
+	c, err := NewCheckpointed(sink, checkpoint)
+	require.NoError(t, err)
+
+	c.OnWrite(bankKey, []byte("a"), []byte("1"))
+	c.OnCommit(1)
+	c.OnWrite(bankKey, []byte("b"), []byte("1"))
+	c.OnCommit(2)
+	require.Equal(t, []string{"bank/a", "bank/b"}, sink.writes)
+	require.Equal(t, 2, sink.commits)
+	require.Equal(t, int64(2), c.Height())
+
+	// the checkpoint doesn't advance past a failed block
+	sink.err = errors.New("failed")
+	c.OnWrite(bankKey, []byte("c"), []byte("1"))
+	c.OnCommit(3)
+	require.Equal(t, int64(2), c.Height())
+	require.NoError(t, c.Err())
+
+	// a restarted sink skips the blocks delivered before the checkpoint
+	sink = &failingSink{}
+	c, err = NewCheckpointed(sink, checkpoint)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), c.Height())
+
+	for _, height := range []int64{2, 3} {
+		c.OnWrite(bankKey, []byte{byte('a' + height - 1)}, []byte("1"))
+		c.OnCommit(height)
+	}
+	require.Equal(t, []string{"bank/c"}, sink.writes)
+	require.Equal(t, 1, sink.commits)
+	require.Equal(t, int64(3), c.Height())
+
+	require.NoError(t, c.Close())
+	require.True(t, sink.closed)
+}
+
+func TestFileCheckpointInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "checkpoint")
+	require.NoError(t, ioutil.WriteFile(path, []byte("not a height"), 0600))
+
+	_, err = NewCheckpointed(&routedListener{}, NewFileCheckpoint(path))
+	require.Error(t, err)
+}
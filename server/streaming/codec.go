@@ -0,0 +1,262 @@
+package streaming
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// Codec serializes the writes streamed to a destination, so each destination
+// can be fed the encoding its consumer expects.
+type Codec interface {
+	Marshal(event Event) ([]byte, error)
+	Unmarshal(bz []byte) (Event, error)
+}
+
+// Names of the codecs returned by GetCodec.
+const (
+	CodecFrame     = "frame"
+	CodecJSON      = "json"
+	CodecProto     = "proto"
+	CodecProtoJSON = "proto-json"
+	CodecAminoJSON = "amino-json"
+)
+
+var codecs = map[string]Codec{
+	CodecFrame:     FrameCodec{},
+	CodecJSON:      JSONCodec{},
+	CodecProto:     ProtoCodec{},
+	CodecProtoJSON: ProtoJSONCodec{},
+	CodecAminoJSON: NewAminoJSONCodec(),
+}
+
+// GetCodec returns the codec with the given name, as set in configuration.
+func GetCodec(name string) (Codec, error) {
+	c, ok := codecs[name]
+	if !ok {
+		names := make([]string, 0, len(codecs))
+		for name := range codecs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return nil, fmt.Errorf("unknown streaming codec %q, expected one of %v", name, names)
+	}
+
+	return c, nil
+}
+
+// FrameCodec encodes each write as a FrameFormatV2 stream holding the write
+// alone. Only the store key, key, value and delete flag are encoded.
+type FrameCodec struct{}
+
+// Marshal implements the Codec interface.
+func (FrameCodec) Marshal(event Event) ([]byte, error) {
+	var buf bytes.Buffer
+
+	value := event.Value
+	if !event.Delete && value == nil {
+		value = []byte{}
+	}
+
+	l := types.NewFrameWriteListener(&buf)
+	l.OnWrite(types.NewKVStoreKey(event.StoreKey), event.Key, value)
+
+	return buf.Bytes(), l.Err()
+}
+
+// Unmarshal implements the Codec interface.
+func (FrameCodec) Unmarshal(bz []byte) (Event, error) {
+	record, err := types.NewFrameReader(bytes.NewReader(bz)).Next()
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{StoreKey: record.StoreKey, Key: record.Key, Value: record.Value, Delete: record.Delete}, nil
+}
+
+// JSONCodec encodes each write as the JSON encoding of its Event, the format
+// of the server-sent events, as controlled by its Options.
+type JSONCodec struct {
+	Options JSONOptions
+}
+
+// Marshal implements the Codec interface.
+func (c JSONCodec) Marshal(event Event) ([]byte, error) {
+	return c.Options.Marshal(event)
+}
+
+// Unmarshal implements the Codec interface.
+func (c JSONCodec) Unmarshal(bz []byte) (Event, error) {
+	var event Event
+	err := c.Options.Unmarshal(bz, &event)
+
+	return event, err
+}
+
+// ProtoCodec encodes each write in the protobuf binary format of the message
+//
+//	message StoreKVPair {
+//	  string store_key = 1;
+//	  bool   delete    = 2;
+//	  bytes  key       = 3;
+//	  bytes  value     = 4;
+//	}
+//
+// Only the store key, key, value and delete flag are encoded.
+type ProtoCodec struct{}
+
+// Field numbers of the StoreKVPair message.
+const (
+	protoFieldStoreKey protowire.Number = 1
+	protoFieldDelete   protowire.Number = 2
+	protoFieldKey      protowire.Number = 3
+	protoFieldValue    protowire.Number = 4
+)
+
+// Marshal implements the Codec interface.
+func (ProtoCodec) Marshal(event Event) ([]byte, error) {
+	var bz []byte
+
+	if event.StoreKey != "" {
+		bz = protowire.AppendTag(bz, protoFieldStoreKey, protowire.BytesType)
+		bz = protowire.AppendString(bz, event.StoreKey)
+	}
+	if event.Delete {
+		bz = protowire.AppendTag(bz, protoFieldDelete, protowire.VarintType)
+		bz = protowire.AppendVarint(bz, 1)
+	}
+	if len(event.Key) > 0 {
+		bz = protowire.AppendTag(bz, protoFieldKey, protowire.BytesType)
+		bz = protowire.AppendBytes(bz, event.Key)
+	}
+	if len(event.Value) > 0 {
+		bz = protowire.AppendTag(bz, protoFieldValue, protowire.BytesType)
+		bz = protowire.AppendBytes(bz, event.Value)
+	}
+
+	return bz, nil
+}
+
+// Unmarshal implements the Codec interface. Unknown fields are skipped.
+func (ProtoCodec) Unmarshal(bz []byte) (Event, error) {
+	var event Event
+
+	for len(bz) > 0 {
+		num, typ, n := protowire.ConsumeTag(bz)
+		if n < 0 {
+			return Event{}, protowire.ParseError(n)
+		}
+		bz = bz[n:]
+
+		switch {
+		case num == protoFieldStoreKey && typ == protowire.BytesType:
+			var v string
+			v, n = protowire.ConsumeString(bz)
+			event.StoreKey = v
+
+		case num == protoFieldDelete && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(bz)
+			event.Delete = v != 0
+
+		case num == protoFieldKey && typ == protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(bz)
+			event.Key = append([]byte(nil), v...)
+
+		case num == protoFieldValue && typ == protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(bz)
+			event.Value = append([]byte(nil), v...)
+
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, bz)
+		}
+
+		if n < 0 {
+			return Event{}, protowire.ParseError(n)
+		}
+		bz = bz[n:]
+	}
+
+	return event, nil
+}
+
+// protoJSONKVPair is the protobuf JSON mapping of the StoreKVPair message.
+type protoJSONKVPair struct {
+	StoreKey string `json:"storeKey,omitempty"`
+	Delete   bool   `json:"delete,omitempty"`
+	Key      []byte `json:"key,omitempty"`
+	Value    []byte `json:"value,omitempty"`
+}
+
+// ProtoJSONCodec encodes each write in the protobuf JSON mapping of the
+// StoreKVPair message of ProtoCodec.
+type ProtoJSONCodec struct{}
+
+// Marshal implements the Codec interface.
+func (ProtoJSONCodec) Marshal(event Event) ([]byte, error) {
+	return json.Marshal(protoJSONKVPair{
+		StoreKey: event.StoreKey,
+		Delete:   event.Delete,
+		Key:      event.Key,
+		Value:    event.Value,
+	})
+}
+
+// Unmarshal implements the Codec interface.
+func (ProtoJSONCodec) Unmarshal(bz []byte) (Event, error) {
+	var kv protoJSONKVPair
+	if err := json.Unmarshal(bz, &kv); err != nil {
+		return Event{}, err
+	}
+
+	return Event{StoreKey: kv.StoreKey, Key: kv.Key, Value: kv.Value, Delete: kv.Delete}, nil
+}
+
+// aminoKVPair is the write encoded by AminoJSONCodec.
+type aminoKVPair struct {
+	StoreKey string `json:"store_key"`
+	Delete   bool   `json:"delete"`
+	Key      []byte `json:"key"`
+	Value    []byte `json:"value"`
+}
+
+// AminoJSONCodec encodes each write in the Amino JSON format, for consumers
+// built on the legacy Amino codec. Only the store key, key, value and delete
+// flag are encoded.
+type AminoJSONCodec struct {
+	cdc *codec.LegacyAmino
+}
+
+// NewAminoJSONCodec returns a new AminoJSONCodec.
+func NewAminoJSONCodec() AminoJSONCodec {
+	return AminoJSONCodec{cdc: codec.NewLegacyAmino()}
+}
+
+// Marshal implements the Codec interface.
+func (c AminoJSONCodec) Marshal(event Event) ([]byte, error) {
+	return c.cdc.MarshalJSON(aminoKVPair{
+		StoreKey: event.StoreKey,
+		Delete:   event.Delete,
+		Key:      event.Key,
+		Value:    event.Value,
+	})
+}
+
+// Unmarshal implements the Codec interface.
+func (c AminoJSONCodec) Unmarshal(bz []byte) (Event, error) {
+	var kv aminoKVPair
+	if err := c.cdc.UnmarshalJSON(bz, &kv); err != nil {
+		return Event{}, err
+	}
+
+	return Event{StoreKey: kv.StoreKey, Key: kv.Key, Value: kv.Value, Delete: kv.Delete}, nil
+}
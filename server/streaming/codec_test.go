@@ -0,0 +1,45 @@
+package streaming
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodecs(t *testing.T) {
+	events := []Event{
+		{StoreKey: "acc", Key: []byte("key0"), Value: []byte("value0")},
+		{StoreKey: "acc", Key: []byte("key1"), Delete: true},
+	}
+
+	for _, name := range []string{CodecFrame, CodecJSON, CodecProto, CodecProtoJSON, CodecAminoJSON} {
+		codec, err := GetCodec(name)
+		require.NoError(t, err)
+
+		for _, event := range events {
+			bz, err := codec.Marshal(event)
+			require.NoError(t, err, name)
+
+			decoded, err := codec.Unmarshal(bz)
+			require.NoError(t, err, name)
+			require.Equal(t, event, decoded, name)
+		}
+	}
+
+	_, err := GetCodec("msgpack")
+	require.Error(t, err)
+}
+
+func TestProtoCodec(t *testing.T) {
+	bz, err := ProtoCodec{}.Marshal(Event{StoreKey: "acc", Key: []byte("k"), Delete: true})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x0a, 3, 'a', 'c', 'c', 0x10, 1, 0x1a, 1, 'k'}, bz)
+
+	// unknown fields are skipped
+	event, err := ProtoCodec{}.Unmarshal(append([]byte{0x28, 7}, bz...))
+	require.NoError(t, err)
+	require.Equal(t, Event{StoreKey: "acc", Key: []byte("k"), Delete: true}, event)
+
+	_, err = ProtoCodec{}.Unmarshal([]byte{0x0a, 3, 'a'})
+	require.Error(t, err)
+}
@@ -0,0 +1,276 @@
+package streaming
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// maxFirehoseLine is the maximum length of a line read by a FirehoseReader.
+const maxFirehoseLine = types.MaxFrameFieldLength
+
+// ErrHeightMismatch is returned when comparing streams whose blocks are not
+// at the same heights.
+var ErrHeightMismatch = errors.New("streaming: block heights do not match")
+
+// FirehoseBlock is a block emitted by a Firehose. Prefixes is the bloom
+// filter of the key prefixes of up to PrefixLength bytes written to in the
+// block, nil if the Firehose didn't emit prefix filters.
+type FirehoseBlock struct {
+	Height       int64
+	Payload      []byte
+	PrefixLength int
+	Prefixes     *Bloom
+}
+
+// MayTouch returns false if the prefix filter of the block proves that none
+// of its writes is to a key of the store starting with the prefix. It always
+// returns true for blocks without a prefix filter.
+func (b FirehoseBlock) MayTouch(storeKey string, prefix []byte) bool {
+	if b.Prefixes == nil {
+		return true
+	}
+
+	return mayTouch(b.Prefixes, b.PrefixLength, storeKey, prefix)
+}
+
+// Records decodes the writes of the block.
+func (b FirehoseBlock) Records() ([]types.FrameRecord, error) {
+	var records []types.FrameRecord
+
+	r := types.NewFrameReader(bytes.NewReader(b.Payload))
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+}
+
+// FirehoseReader reads the blocks emitted by a Firehose, along with their
+// prefix filters. Lines which are not FIRE lines, such as the logs of the
+// node sharing its output, are skipped.
+type FirehoseReader struct {
+	scanner  *bufio.Scanner
+	prefixes FirehoseBlock
+}
+
+// NewFirehoseReader returns a FirehoseReader reading from r.
+func NewFirehoseReader(r io.Reader) *FirehoseReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxFirehoseLine)
+
+	return &FirehoseReader{scanner: scanner}
+}
+
+// Next returns the next block. It returns io.EOF once the stream ends.
+func (r *FirehoseReader) Next() (FirehoseBlock, error) {
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+
+		prefixes, ok, err := parseFirehosePrefixesLine(line)
+		if err != nil {
+			return FirehoseBlock{}, err
+		} else if ok {
+			r.prefixes = prefixes
+			continue
+		}
+
+		block, ok, err := parseFirehoseLine(line)
+		if err != nil {
+			return FirehoseBlock{}, err
+		} else if !ok {
+			continue
+		}
+
+		if r.prefixes.Prefixes != nil && r.prefixes.Height == block.Height {
+			block.PrefixLength, block.Prefixes = r.prefixes.PrefixLength, r.prefixes.Prefixes
+		}
+		r.prefixes = FirehoseBlock{}
+
+		return block, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return FirehoseBlock{}, err
+	}
+
+	return FirehoseBlock{}, io.EOF
+}
+
+// parseFirehoseLine parses a FIRE BLOCK line. It returns false if the line is
+// not a FIRE BLOCK line.
+func parseFirehoseLine(line string) (FirehoseBlock, bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "FIRE" || fields[1] != "BLOCK" {
+		return FirehoseBlock{}, false, nil
+	}
+
+	if len(fields) != 4 {
+		return FirehoseBlock{}, false, fmt.Errorf("invalid FIRE BLOCK line with %d fields", len(fields))
+	}
+
+	height, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return FirehoseBlock{}, false, fmt.Errorf("invalid FIRE BLOCK height: %w", err)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(fields[3])
+	if err != nil {
+		return FirehoseBlock{}, false, fmt.Errorf("invalid FIRE BLOCK payload at height %d: %w", height, err)
+	}
+
+	return FirehoseBlock{Height: height, Payload: payload}, true, nil
+}
+
+// parseFirehosePrefixesLine parses a FIRE PREFIXES line into a FirehoseBlock
+// without payload. It returns false if the line is not a FIRE PREFIXES line.
+func parseFirehosePrefixesLine(line string) (FirehoseBlock, bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "FIRE" || fields[1] != "PREFIXES" {
+		return FirehoseBlock{}, false, nil
+	}
+
+	if len(fields) != 6 {
+		return FirehoseBlock{}, false, fmt.Errorf("invalid FIRE PREFIXES line with %d fields", len(fields))
+	}
+
+	height, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return FirehoseBlock{}, false, fmt.Errorf("invalid FIRE PREFIXES height: %w", err)
+	}
+
+	prefixLength, err := strconv.Atoi(fields[3])
+	if err != nil || prefixLength < 0 {
+		return FirehoseBlock{}, false, fmt.Errorf("invalid FIRE PREFIXES prefix length at height %d", height)
+	}
+
+	hashes, err := strconv.ParseUint(fields[4], 10, 32)
+	if err != nil {
+		return FirehoseBlock{}, false, fmt.Errorf("invalid FIRE PREFIXES hashes at height %d: %w", height, err)
+	}
+
+	bits, err := base64.StdEncoding.DecodeString(fields[5])
+	if err != nil {
+		return FirehoseBlock{}, false, fmt.Errorf("invalid FIRE PREFIXES bits at height %d: %w", height, err)
+	}
+
+	return FirehoseBlock{
+		Height:       height,
+		PrefixLength: prefixLength,
+		Prefixes:     &Bloom{Bits: bits, Hashes: uint32(hashes)},
+	}, true, nil
+}
+
+// BlockMismatch describes the first difference between the writes of a block
+// in two streams. A and B are the Index-th writes of the block in each
+// stream, nil if the stream has fewer writes.
+type BlockMismatch struct {
+	Height int64
+	Index  int
+	A      *types.FrameRecord
+	B      *types.FrameRecord
+}
+
+// String implements the fmt.Stringer interface.
+func (m BlockMismatch) String() string {
+	format := func(r *types.FrameRecord) string {
+		switch {
+		case r == nil:
+			return "no write"
+		case r.Delete:
+			return fmt.Sprintf("%s delete %X", r.StoreKey, r.Key)
+		default:
+			return fmt.Sprintf("%s set %X %X", r.StoreKey, r.Key, r.Value)
+		}
+	}
+
+	return fmt.Sprintf("block %d differs at write %d: %s != %s", m.Height, m.Index, format(m.A), format(m.B))
+}
+
+// CompareFirehose compares the blocks of two Firehose streams of the same
+// chain, e.g. emitted by two independent nodes, block by block. It returns
+// the number of identical blocks compared and the first mismatch, if any.
+// The comparison stops at the end of the shorter stream, so nodes at
+// different heights can be compared; both streams must start at the same
+// height.
+func CompareFirehose(a, b io.Reader) (int, *BlockMismatch, error) {
+	ra, rb := NewFirehoseReader(a), NewFirehoseReader(b)
+
+	for compared := 0; ; compared++ {
+		blockA, err := ra.Next()
+		if err == io.EOF {
+			return compared, nil, nil
+		} else if err != nil {
+			return compared, nil, err
+		}
+
+		blockB, err := rb.Next()
+		if err == io.EOF {
+			return compared, nil, nil
+		} else if err != nil {
+			return compared, nil, err
+		}
+
+		if blockA.Height != blockB.Height {
+			return compared, nil, fmt.Errorf("%w: %d != %d", ErrHeightMismatch, blockA.Height, blockB.Height)
+		}
+
+		if bytes.Equal(blockA.Payload, blockB.Payload) {
+			continue
+		}
+
+		mismatch, err := diffBlocks(blockA, blockB)
+		if mismatch != nil || err != nil {
+			return compared, mismatch, err
+		}
+	}
+}
+
+// diffBlocks returns the first difference between the writes of two blocks
+// whose payloads differ.
+func diffBlocks(a, b FirehoseBlock) (*BlockMismatch, error) {
+	recordsA, err := a.Records()
+	if err != nil {
+		return nil, err
+	}
+
+	recordsB, err := b.Records()
+	if err != nil {
+		return nil, err
+	}
+
+	mismatch := &BlockMismatch{Height: a.Height}
+	for ; mismatch.Index < len(recordsA) || mismatch.Index < len(recordsB); mismatch.Index++ {
+		mismatch.A, mismatch.B = nil, nil
+		if mismatch.Index < len(recordsA) {
+			mismatch.A = &recordsA[mismatch.Index]
+		}
+		if mismatch.Index < len(recordsB) {
+			mismatch.B = &recordsB[mismatch.Index]
+		}
+
+		if mismatch.A == nil || mismatch.B == nil || !recordsEqual(*mismatch.A, *mismatch.B) {
+			return mismatch, nil
+		}
+	}
+
+	// the payloads only differ in their encoding
+	return nil, nil
+}
+
+// recordsEqual returns true if both records are the same write.
+func recordsEqual(a, b types.FrameRecord) bool {
+	return a.StoreKey == b.StoreKey && a.Delete == b.Delete && bytes.Equal(a.Key, b.Key) && bytes.Equal(a.Value, b.Value)
+}
@@ -0,0 +1,59 @@
+package streaming
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// firehoseStream returns the Firehose output of the blocks, each given as its
+// writes.
+func firehoseStream(firstHeight int64, blocks ...[]types.FrameRecord) *bytes.Buffer {
+	var buf bytes.Buffer
+	buf.WriteString("node log line\n")
+
+	f := NewFirehose(&buf)
+	for i, block := range blocks {
+		for _, r := range block {
+			f.OnWrite(types.NewKVStoreKey(r.StoreKey), r.Key, r.Value)
+		}
+		f.OnCommit(firstHeight + int64(i))
+	}
+
+	return &buf
+}
+
+func TestCompareFirehose(t *testing.T) {
+	block1 := []types.FrameRecord{{StoreKey: "acc", Key: []byte("key0"), Value: []byte("value0")}}
+	block2 := []types.FrameRecord{
+		{StoreKey: "acc", Key: []byte("key1"), Value: []byte("value1")},
+		{StoreKey: "bank", Key: []byte("key2"), Value: []byte("value2")},
+	}
+	block2Diverged := []types.FrameRecord{
+		{StoreKey: "acc", Key: []byte("key1"), Value: []byte("value1")},
+		{StoreKey: "bank", Key: []byte("key2"), Value: []byte("other")},
+	}
+
+	compared, mismatch, err := CompareFirehose(firehoseStream(1, block1, block2), firehoseStream(1, block1, block2, block1))
+	require.NoError(t, err)
+	require.Nil(t, mismatch)
+	require.Equal(t, 2, compared)
+
+	compared, mismatch, err = CompareFirehose(firehoseStream(1, block1, block2), firehoseStream(1, block1, block2Diverged))
+	require.NoError(t, err)
+	require.Equal(t, 1, compared)
+	require.Equal(t, &BlockMismatch{Height: 2, Index: 1, A: &block2[1], B: &block2Diverged[1]}, mismatch)
+	require.Equal(t, "block 2 differs at write 1: bank set 6B657932 76616C756532 != bank set 6B657932 6F74686572", mismatch.String())
+
+	compared, mismatch, err = CompareFirehose(firehoseStream(1, block2), firehoseStream(1, block2[:1]))
+	require.NoError(t, err)
+	require.Equal(t, 0, compared)
+	require.Equal(t, &BlockMismatch{Height: 1, Index: 1, A: &block2[1]}, mismatch)
+
+	_, _, err = CompareFirehose(firehoseStream(1, block1), firehoseStream(2, block1))
+	require.True(t, errors.Is(err, ErrHeightMismatch))
+}
@@ -0,0 +1,262 @@
+package streaming
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// DefaultReconnectInterval is the default minimum delay between two attempts
+// of a Destination to connect to its consumer.
+const DefaultReconnectInterval = time.Second
+
+// DestinationKind is the kind of file a Destination streams to.
+type DestinationKind int
+
+const (
+	// DestinationAuto detects the kind of the file on every connection
+	// attempt, so the consumer may create it after the node starts.
+	DestinationAuto DestinationKind = iota
+	// DestinationUnixSocket is a unix domain socket the consumer listens on.
+	DestinationUnixSocket
+	// DestinationFIFO is a named pipe the consumer reads from.
+	DestinationFIFO
+)
+
+var _ types.WriteListener = (*Destination)(nil)
+
+// Destination is a WriteListener streaming the writes, encoded by a
+// FrameWriteListener, to a co-located consumer through a unix domain socket
+// or a named pipe (FIFO), so it can read the stream without polling files.
+//
+// The consumer may come and go: writes made while no consumer is connected
+// are dropped and counted, and the Destination reconnects at most once per
+// reconnect interval. FIFOs are opened without blocking, failing while no
+// reader has the FIFO open. Each connection starts a new frame stream, with
+// its own format version byte. With a Codec set, each write is instead
+// written as its uvarint length prefixed encoding. With the stream header
+// enabled, each connection starts with a StreamHeader announcing the framing
+// and encoding of the stream, read by a StreamReader.
+type Destination struct {
+	mtx               sync.Mutex
+	path              string
+	kind              DestinationKind
+	reconnectInterval time.Duration
+	conn              io.WriteCloser
+	frames            *types.FrameWriteListener
+	codec             Codec
+	header            bool
+	features          []string
+	envelope          Envelope
+	buf               []byte
+	lastAttempt       time.Time
+	dropped           uint64
+	closed            bool
+}
+
+// NewDestination returns a new Destination streaming to the file of the given
+// kind at path.
+func NewDestination(path string, kind DestinationKind) *Destination {
+	return &Destination{
+		path:              path,
+		kind:              kind,
+		reconnectInterval: DefaultReconnectInterval,
+	}
+}
+
+// SetReconnectInterval sets the minimum delay between two connection
+// attempts.
+func (d *Destination) SetReconnectInterval(interval time.Duration) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.reconnectInterval = interval
+}
+
+// SetCodec sets the Codec the writes are encoded with.
+func (d *Destination) SetCodec(codec Codec) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.codec = codec
+}
+
+// SetStreamHeader enables the StreamHeader at the start of every connection,
+// announcing the given features of the stream.
+func (d *Destination) SetStreamHeader(features ...string) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.header = true
+	d.features = features
+}
+
+// SetEnvelope sets the Envelope the stream of every connection, or every
+// write with a Codec set, is stamped with. It implements the EnvelopeSetter
+// interface.
+func (d *Destination) SetEnvelope(envelope Envelope) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.envelope = envelope.copy()
+}
+
+// OnWrite implements the WriteListener interface.
+func (d *Destination) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.closed {
+		return
+	}
+
+	if d.conn == nil && d.connect() != nil {
+		d.dropped++
+		return
+	}
+
+	if err := d.write(storeKey, key, value); err != nil {
+		d.disconnect()
+		d.dropped++
+	}
+}
+
+// write writes the write to the connection. It must be called with the lock
+// held.
+func (d *Destination) write(storeKey types.StoreKey, key []byte, value []byte) error {
+	if d.codec == nil {
+		d.frames.OnWrite(storeKey, key, value)
+		return d.frames.Err()
+	}
+
+	event := writeEvent(storeKey, key, value)
+	d.envelope.stamp(&event)
+
+	bz, err := d.codec.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(bz)))
+
+	d.buf = append(append(d.buf[:0], lenBuf[:n]...), bz...)
+	_, err = d.conn.Write(d.buf)
+
+	return err
+}
+
+// connect opens the connection to the consumer, unless the last attempt was
+// made less than the reconnect interval ago. It must be called with the lock
+// held.
+func (d *Destination) connect() error {
+	if time.Since(d.lastAttempt) < d.reconnectInterval {
+		return errors.New("waiting to reconnect")
+	}
+	d.lastAttempt = time.Now()
+
+	kind := d.kind
+	if kind == DestinationAuto {
+		info, err := os.Stat(d.path)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.Mode()&os.ModeSocket != 0:
+			kind = DestinationUnixSocket
+		case info.Mode()&os.ModeNamedPipe != 0:
+			kind = DestinationFIFO
+		default:
+			return fmt.Errorf("%s is neither a unix socket nor a named pipe", d.path)
+		}
+	}
+
+	var (
+		conn io.WriteCloser
+		err  error
+	)
+
+	switch kind {
+	case DestinationUnixSocket:
+		conn, err = net.DialTimeout("unix", d.path, d.reconnectInterval)
+	case DestinationFIFO:
+		// fails with ENXIO while no reader has the FIFO open
+		conn, err = os.OpenFile(d.path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	default:
+		err = fmt.Errorf("unknown destination kind %d", kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	if d.header {
+		if err := d.writeHeader(conn); err != nil {
+			_ = conn.Close()
+			return err
+		}
+	}
+
+	d.conn = conn
+	d.frames = d.envelope.newFrameWriteListener(conn)
+
+	return nil
+}
+
+// writeHeader writes the StreamHeader of the stream to a new connection. It
+// must be called with the lock held.
+func (d *Destination) writeHeader(conn io.Writer) error {
+	header := StreamHeader{Version: StreamFormatVersion, Framing: FramingFrameStream, Features: d.features}
+	if d.codec != nil {
+		header.Framing, header.Codec = FramingLengthPrefixed, codecName(d.codec)
+	}
+
+	var buf bytes.Buffer
+	if err := writeStreamHeader(&buf, header); err != nil {
+		return err
+	}
+
+	_, err := conn.Write(buf.Bytes())
+	return err
+}
+
+// disconnect closes the connection to the consumer. It must be called with
+// the lock held.
+func (d *Destination) disconnect() {
+	if d.conn != nil {
+		_ = d.conn.Close()
+	}
+
+	d.conn = nil
+	d.frames = nil
+}
+
+// Dropped returns the number of writes dropped while no consumer was
+// connected.
+func (d *Destination) Dropped() uint64 {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	return d.dropped
+}
+
+// Close implements the io.Closer interface. It closes the connection and
+// drops every subsequent write.
+func (d *Destination) Close() error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.closed = true
+	d.disconnect()
+
+	return nil
+}
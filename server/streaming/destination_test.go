@@ -0,0 +1,70 @@
+package streaming
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+func TestDestinationUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "destination")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "stream.sock")
+	d := NewDestination(path, DestinationAuto)
+	d.SetReconnectInterval(0)
+
+	// the consumer is not listening yet
+	d.OnWrite(accKey, []byte("key0"), []byte("value0"))
+	require.Equal(t, uint64(1), d.Dropped())
+
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	d.OnWrite(accKey, []byte("key1"), []byte("value1"))
+	d.OnWrite(bankKey, []byte("key2"), nil)
+	require.Equal(t, uint64(1), d.Dropped())
+
+	conn := <-accepted
+	defer conn.Close()
+
+	r := types.NewFrameReader(conn)
+	record, err := r.Next()
+	require.NoError(t, err)
+	require.Equal(t, types.FrameRecord{StoreKey: "acc", Key: []byte("key1"), Value: []byte("value1")}, record)
+
+	record, err = r.Next()
+	require.NoError(t, err)
+	require.Equal(t, types.FrameRecord{StoreKey: "bank", Key: []byte("key2"), Delete: true}, record)
+
+	require.NoError(t, d.Close())
+	d.OnWrite(accKey, []byte("key3"), []byte("value3"))
+	require.Equal(t, uint64(1), d.Dropped(), "writes after Close should be ignored")
+}
+
+func TestDestinationInvalidFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "destination")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	f.Close()
+
+	d := NewDestination(f.Name(), DestinationAuto)
+	d.OnWrite(accKey, []byte("key"), []byte("value"))
+	require.Equal(t, uint64(1), d.Dropped())
+}
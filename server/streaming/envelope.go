@@ -0,0 +1,96 @@
+package streaming
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// Envelope identifies the network, application and node the state changes
+// are streamed from, so consumers can ingest several networks, or the streams
+// of a fleet of nodes, without collisions. Metadata holds the labels of the
+// node, such as its moniker or region, like the Metadata of a tracekv
+// TraceOperation.
+//
+// Every sink of this package is stamped with the Envelope set through
+// SetEnvelope: the Events it sends carry it, and the frame streams it writes
+// start with its JSON encoding in a metadata frame, read back with
+// ReadEnvelope.
+type Envelope struct {
+	ChainID    string                 `json:"chain_id,omitempty"`
+	AppVersion string                 `json:"app_version,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// EnvelopeSetter is implemented by the sinks stamped with an Envelope.
+type EnvelopeSetter interface {
+	SetEnvelope(envelope Envelope)
+}
+
+// IsEmpty returns true if the Envelope stamps nothing.
+func (e Envelope) IsEmpty() bool {
+	return e.ChainID == "" && e.AppVersion == "" && len(e.Metadata) == 0
+}
+
+// Namespace returns the path of a file or directory of a sink namespaced by
+// the chain ID, i.e. moved into a directory named after it, so the sinks of
+// nodes of several networks sharing a host don't collide. The path is
+// returned as is if the chain ID is empty.
+func (e Envelope) Namespace(path string) string {
+	if e.ChainID == "" || path == "" {
+		return path
+	}
+
+	return filepath.Join(filepath.Dir(path), e.ChainID, filepath.Base(path))
+}
+
+// copy returns a copy of the Envelope, not sharing its metadata.
+func (e Envelope) copy() Envelope {
+	if e.Metadata == nil {
+		return e
+	}
+
+	metadata := make(map[string]interface{}, len(e.Metadata))
+	for k, v := range e.Metadata {
+		metadata[k] = v
+	}
+	e.Metadata = metadata
+
+	return e
+}
+
+// stamp stamps the event with the Envelope.
+func (e Envelope) stamp(event *Event) {
+	event.ChainID, event.AppVersion, event.Metadata = e.ChainID, e.AppVersion, e.Metadata
+}
+
+// newFrameWriteListener returns a FrameWriteListener writing to w, stamped
+// with the Envelope unless it is empty.
+func (e Envelope) newFrameWriteListener(w io.Writer) *types.FrameWriteListener {
+	l := types.NewFrameWriteListener(w)
+	if e.IsEmpty() {
+		return l
+	}
+
+	// the Envelope only holds values decoded from JSON or the configuration
+	if bz, err := json.Marshal(e); err == nil {
+		l.SetMetadata(bz)
+	}
+
+	return l
+}
+
+// ReadEnvelope returns the Envelope a frame stream is stamped with, once
+// read by the FrameReader, or an empty Envelope if it has none.
+func ReadEnvelope(r *types.FrameReader) (Envelope, error) {
+	var envelope Envelope
+	if bz := r.Metadata(); len(bz) > 0 {
+		if err := json.Unmarshal(bz, &envelope); err != nil {
+			return Envelope{}, err
+		}
+	}
+
+	return envelope, nil
+}
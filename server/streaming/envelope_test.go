@@ -0,0 +1,121 @@
+package streaming
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+var testEnvelope = Envelope{
+	ChainID:    "testnet-1",
+	AppVersion: "v1.0.0",
+	Metadata:   map[string]interface{}{"moniker": "node0"},
+}
+
+func TestEnvelopeNamespace(t *testing.T) {
+	require.Equal(t, filepath.Join("home", "testnet-1", "firehose"), testEnvelope.Namespace(filepath.Join("home", "firehose")))
+	require.Equal(t, "", testEnvelope.Namespace(""))
+	require.Equal(t, "firehose", Envelope{}.Namespace("firehose"))
+}
+
+func TestFirehoseEnvelope(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFirehose(&buf)
+	f.SetEnvelope(testEnvelope)
+
+	f.OnWrite(accKey, []byte("key0"), []byte("value0"))
+	f.OnCommit(1)
+	require.NoError(t, f.Err())
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, []types.FrameRecord{
+		{StoreKey: "acc", Key: []byte("key0"), Value: []byte("value0")},
+	}, readFirehoseBlock(t, lines[1]))
+
+	payload, err := base64.StdEncoding.DecodeString(strings.Fields(lines[1])[3])
+	require.NoError(t, err)
+
+	r := types.NewFrameReader(bytes.NewReader(payload))
+	_, err = r.Next()
+	require.NoError(t, err)
+
+	envelope, err := ReadEnvelope(r)
+	require.NoError(t, err)
+	require.Equal(t, testEnvelope, envelope)
+}
+
+func TestWebhookEnvelope(t *testing.T) {
+	var payloads []WebhookPayload
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var payload WebhookPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		payloads = append(payloads, payload)
+	}))
+	defer srv.Close()
+
+	w := NewWebhook(WebhookEndpoint{URL: srv.URL})
+	w.SetEnvelope(testEnvelope)
+
+	w.OnWrite(accKey, []byte("key0"), []byte("value0"))
+	w.OnCommit(1)
+	require.NoError(t, w.Err())
+
+	require.Len(t, payloads, 1)
+	require.Equal(t, []Event{{
+		StoreKey:   "acc",
+		Key:        []byte("key0"),
+		Value:      []byte("value0"),
+		ChainID:    "testnet-1",
+		AppVersion: "v1.0.0",
+		Metadata:   map[string]interface{}{"moniker": "node0"},
+	}}, payloads[0].Events)
+}
+
+// envelopeSink records the Envelope it is stamped with.
+type envelopeSink struct {
+	envelope Envelope
+}
+
+func (s *envelopeSink) OnWrite(types.StoreKey, []byte, []byte) {}
+
+func (s *envelopeSink) SetEnvelope(envelope Envelope) {
+	s.envelope = envelope
+}
+
+func TestPipelineEnvelope(t *testing.T) {
+	var sinks []*envelopeSink
+
+	options := DefaultPipelineOptions()
+	options.Writers = 2
+
+	p, err := NewPipeline(options, func(int) types.WriteListener {
+		sink := &envelopeSink{}
+		sinks = append(sinks, sink)
+
+		return sink
+	})
+	require.NoError(t, err)
+
+	p.SetEnvelope(testEnvelope)
+	require.NoError(t, p.Close())
+
+	require.Len(t, sinks, 2)
+	for _, sink := range sinks {
+		require.Equal(t, testEnvelope, sink.envelope)
+	}
+}
@@ -0,0 +1,185 @@
+package streaming
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// Formats of the tables written by a TableExporter.
+const (
+	// ExportFormatCSV writes a CSV file with a header, holding the hex encoded
+	// key and value of every pair.
+	ExportFormatCSV = "csv"
+	// ExportFormatJSON writes JSON lines of objects holding the base64
+	// encoded key and value of every pair.
+	ExportFormatJSON = "json"
+)
+
+var _ types.WriteListener = (*TableExporter)(nil)
+
+// exportTable is the file a table is written to.
+type exportTable struct {
+	file *os.File
+	buf  *bufio.Writer
+	csv  *csv.Writer
+	rows int64
+}
+
+// TableExporter is a WriteListener writing the key-value pairs passed to it
+// to a table per store, the file named after the store in a directory, e.g.
+// to dump a snapshot of the state at a height for an audit or an analysis.
+// The pairs are written in the order they are passed, and the tables are
+// only complete once the exporter is closed. The first error encountered is
+// returned from Err and Close, and stops the export. It is safe for
+// concurrent use.
+type TableExporter struct {
+	mtx    sync.Mutex
+	dir    string
+	format string
+	tables map[string]*exportTable
+	err    error
+}
+
+// NewTableExporter returns a TableExporter writing tables in the given format
+// to dir, which is created if it doesn't exist.
+func NewTableExporter(dir, format string) (*TableExporter, error) {
+	if format != ExportFormatCSV && format != ExportFormatJSON {
+		return nil, fmt.Errorf("unknown export format %q, expected one of [%s %s]", format, ExportFormatCSV, ExportFormatJSON)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &TableExporter{dir: dir, format: format, tables: make(map[string]*exportTable)}, nil
+}
+
+// OnWrite implements the WriteListener interface.
+func (e *TableExporter) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if e.err != nil {
+		return
+	}
+
+	table, err := e.table(storeKey.Name())
+	if err == nil {
+		err = e.writeRow(table, key, value)
+	}
+
+	if err != nil {
+		e.err = fmt.Errorf("failed to export store %s: %w", storeKey.Name(), err)
+		return
+	}
+
+	table.rows++
+}
+
+// table returns the table of the store, creating its file on the first write.
+// It must be called with the lock held.
+func (e *TableExporter) table(name string) (*exportTable, error) {
+	if table, ok := e.tables[name]; ok {
+		return table, nil
+	}
+
+	file, err := os.Create(filepath.Join(e.dir, name+"."+e.format))
+	if err != nil {
+		return nil, err
+	}
+
+	table := &exportTable{file: file, buf: bufio.NewWriter(file)}
+	e.tables[name] = table
+
+	if e.format == ExportFormatCSV {
+		table.csv = csv.NewWriter(table.buf)
+		if err := table.csv.Write([]string{"key", "value"}); err != nil {
+			return nil, err
+		}
+	}
+
+	return table, nil
+}
+
+// writeRow writes a pair to the table. It must be called with the lock held.
+func (e *TableExporter) writeRow(table *exportTable, key, value []byte) error {
+	if table.csv != nil {
+		return table.csv.Write([]string{hex.EncodeToString(key), hex.EncodeToString(value)})
+	}
+
+	bz, err := json.Marshal(struct {
+		Key   []byte `json:"key"`
+		Value []byte `json:"value"`
+	}{key, value})
+	if err != nil {
+		return err
+	}
+
+	_, err = table.buf.Write(append(bz, '\n'))
+	return err
+}
+
+// Rows returns the number of rows written to each table, by store name.
+func (e *TableExporter) Rows() map[string]int64 {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	rows := make(map[string]int64, len(e.tables))
+	for name, table := range e.tables {
+		rows[name] = table.rows
+	}
+
+	return rows
+}
+
+// Err returns the first error encountered while exporting.
+func (e *TableExporter) Err() error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	return e.err
+}
+
+// Close flushes and closes the files of the tables. It returns the first
+// error encountered while exporting or closing. The exporter must not be
+// written to once closed.
+func (e *TableExporter) Close() error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	names := make([]string, 0, len(e.tables))
+	for name := range e.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		table := e.tables[name]
+
+		if table.csv != nil {
+			table.csv.Flush()
+			if err := table.csv.Error(); err != nil && e.err == nil {
+				e.err = err
+			}
+		}
+
+		if err := table.buf.Flush(); err != nil && e.err == nil {
+			e.err = err
+		}
+
+		if err := table.file.Close(); err != nil && e.err == nil {
+			e.err = err
+		}
+	}
+
+	return e.err
+}
@@ -0,0 +1,59 @@
+package streaming
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+func TestTableExporter(t *testing.T) {
+	acc, bank := types.NewKVStoreKey("acc"), types.NewKVStoreKey("bank")
+
+	testCases := []struct {
+		format string
+		acc    string
+		bank   string
+	}{
+		{
+			ExportFormatCSV,
+			"key,value\n01,0a\n02,\n",
+			"key,value\nff,0b\n",
+		},
+		{
+			ExportFormatJSON,
+			`{"key":"AQ==","value":"Cg=="}` + "\n" + `{"key":"Ag==","value":""}` + "\n",
+			`{"key":"/w==","value":"Cw=="}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.format, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "export")
+
+			exporter, err := NewTableExporter(dir, tc.format)
+			require.NoError(t, err)
+
+			exporter.OnWrite(acc, []byte{0x01}, []byte{0x0a})
+			exporter.OnWrite(bank, []byte{0xff}, []byte{0x0b})
+			exporter.OnWrite(acc, []byte{0x02}, []byte{})
+			require.NoError(t, exporter.Close())
+			require.Equal(t, map[string]int64{"acc": 2, "bank": 1}, exporter.Rows())
+
+			bz, err := ioutil.ReadFile(filepath.Join(dir, "acc."+tc.format))
+			require.NoError(t, err)
+			require.Equal(t, tc.acc, string(bz))
+
+			bz, err = ioutil.ReadFile(filepath.Join(dir, "bank."+tc.format))
+			require.NoError(t, err)
+			require.Equal(t, tc.bank, string(bz))
+		})
+	}
+
+	_, err := NewTableExporter(t.TempDir(), "parquet")
+	require.EqualError(t, err, `unknown export format "parquet", expected one of [csv json]`)
+}
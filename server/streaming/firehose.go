@@ -0,0 +1,173 @@
+package streaming
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+const (
+	// FirehoseVersion is the version of the framing emitted by a Firehose,
+	// announced in its FIRE INIT line.
+	FirehoseVersion = "1.0"
+	// FirehosePayloadType names the encoding of the FIRE BLOCK payloads,
+	// announced in the FIRE INIT line.
+	FirehosePayloadType = "cosmos.store.FrameFormatV2"
+)
+
+var (
+	_ types.WriteListener    = (*Firehose)(nil)
+	_ baseapp.CommitListener = (*Firehose)(nil)
+)
+
+// Firehose is a WriteListener and CommitListener emitting the writes of every
+// committed block as a single line, in the line framing read by Firehose
+// extractors:
+//
+//	FIRE INIT <version> <payload type>
+//	FIRE BLOCK <height> <base64 payload>
+//
+// The INIT line is emitted once, before the first block. The payload of a
+// block is the stream of its writes in the order they were committed, as
+// encoded by a FrameWriteListener, so it can be decoded with a FrameReader.
+// Blocks without writes are emitted too, with a stream of no writes, keeping
+// the sequence of heights gapless.
+//
+// With prefix filters enabled, every BLOCK line is preceded by a line
+// carrying a bloom filter of the key prefixes written to in the block:
+//
+//	FIRE PREFIXES <height> <prefix length> <hashes> <base64 bits>
+//
+// so consumers backfilling from the stream can skip the blocks irrelevant to
+// the keys they watch without decoding their writes. Readers not aware of
+// the PREFIXES lines, such as Firehose extractors, ignore them.
+type Firehose struct {
+	mtx          sync.Mutex
+	writer       io.Writer
+	block        bytes.Buffer
+	frames       *types.FrameWriteListener
+	envelope     Envelope
+	prefixLength int
+	prefixes     prefixSet
+	initialized  bool
+	err          error
+}
+
+// NewFirehose returns a new Firehose writing to w, typically the standard
+// output of the node read by the Firehose extractor.
+func NewFirehose(w io.Writer) *Firehose {
+	f := &Firehose{writer: w}
+	f.frames = types.NewFrameWriteListener(&f.block)
+
+	return f
+}
+
+// SetEnvelope sets the Envelope the frames of the payload of every block are
+// stamped with. It implements the EnvelopeSetter interface.
+func (f *Firehose) SetEnvelope(envelope Envelope) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.envelope = envelope.copy()
+	if f.block.Len() == 0 {
+		f.frames = f.envelope.newFrameWriteListener(&f.block)
+	}
+}
+
+// SetPrefixFilters enables the PREFIXES lines, with bloom filters of the key
+// prefixes of up to prefixLength bytes written to in every block. A negative
+// prefixLength disables them.
+func (f *Firehose) SetPrefixFilters(prefixLength int) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.prefixLength = prefixLength
+	f.prefixes = nil
+	if prefixLength >= 0 {
+		f.prefixes = make(prefixSet)
+	}
+}
+
+// OnWrite implements the WriteListener interface.
+func (f *Firehose) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.frames.OnWrite(storeKey, key, value)
+	if f.prefixes != nil {
+		f.prefixes.add(storeKey.Name(), key, f.prefixLength)
+	}
+}
+
+// OnCommit implements the baseapp CommitListener interface. It emits the
+// writes of the block. Once a write to the underlying io.Writer fails every
+// subsequent block is dropped and the error is returned from Err.
+func (f *Firehose) OnCommit(height int64) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if f.block.Len() == 0 {
+		f.block.WriteByte(types.FrameFormatV2)
+	}
+
+	block := FirehoseBlock{Height: height, Payload: f.block.Bytes(), PrefixLength: f.prefixLength}
+	if f.prefixes != nil {
+		block.Prefixes = f.prefixes.bloom()
+		f.prefixes = make(prefixSet)
+	}
+
+	defer func() {
+		f.block.Reset()
+		f.frames = f.envelope.newFrameWriteListener(&f.block)
+	}()
+
+	if f.err != nil {
+		return
+	}
+
+	if !f.initialized {
+		if err := writeFirehoseInit(f.writer); err != nil {
+			f.err = err
+			return
+		}
+
+		f.initialized = true
+	}
+
+	if err := writeFirehoseBlock(f.writer, block); err != nil {
+		f.err = err
+	}
+}
+
+// writeFirehoseInit writes the FIRE INIT line starting a Firehose stream.
+func writeFirehoseInit(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "FIRE INIT %s %s\n", FirehoseVersion, FirehosePayloadType)
+	return err
+}
+
+// writeFirehoseBlock writes the FIRE BLOCK line of the block, preceded by its
+// FIRE PREFIXES line if it has a prefix filter.
+func writeFirehoseBlock(w io.Writer, block FirehoseBlock) error {
+	if block.Prefixes != nil {
+		bits := base64.StdEncoding.EncodeToString(block.Prefixes.Bits)
+		if _, err := fmt.Fprintf(w, "FIRE PREFIXES %d %d %d %s\n", block.Height, block.PrefixLength, block.Prefixes.Hashes, bits); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "FIRE BLOCK %d %s\n", block.Height, base64.StdEncoding.EncodeToString(block.Payload))
+	return err
+}
+
+// Err returns the first error encountered emitting a block.
+func (f *Firehose) Err() error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	return f.err
+}
@@ -0,0 +1,99 @@
+package streaming
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// readFirehoseBlock decodes the writes of a FIRE BLOCK line.
+func readFirehoseBlock(t *testing.T, line string) []types.FrameRecord {
+	fields := strings.Fields(line)
+	require.Len(t, fields, 4)
+
+	payload, err := base64.StdEncoding.DecodeString(fields[3])
+	require.NoError(t, err)
+
+	var records []types.FrameRecord
+	r := types.NewFrameReader(bytes.NewReader(payload))
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return records
+		}
+		require.NoError(t, err)
+
+		records = append(records, record)
+	}
+}
+
+func TestFirehose(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFirehose(&buf)
+
+	f.OnWrite(accKey, []byte("key0"), []byte("value0"))
+	f.OnWrite(bankKey, []byte("key1"), nil)
+	f.OnCommit(1)
+	f.OnCommit(2)
+	f.OnWrite(accKey, []byte("key2"), []byte("value2"))
+	f.OnCommit(3)
+	require.NoError(t, f.Err())
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 4)
+	require.Equal(t, "FIRE INIT "+FirehoseVersion+" "+FirehosePayloadType, lines[0])
+
+	require.True(t, strings.HasPrefix(lines[1], "FIRE BLOCK 1 "))
+	require.Equal(t, []types.FrameRecord{
+		{StoreKey: "acc", Key: []byte("key0"), Value: []byte("value0")},
+		{StoreKey: "bank", Key: []byte("key1"), Delete: true},
+	}, readFirehoseBlock(t, lines[1]))
+
+	require.True(t, strings.HasPrefix(lines[2], "FIRE BLOCK 2 "))
+	require.Empty(t, readFirehoseBlock(t, lines[2]))
+
+	require.True(t, strings.HasPrefix(lines[3], "FIRE BLOCK 3 "))
+	require.Equal(t, []types.FrameRecord{
+		{StoreKey: "acc", Key: []byte("key2"), Value: []byte("value2")},
+	}, readFirehoseBlock(t, lines[3]))
+}
+
+func TestFirehosePrefixFilters(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFirehose(&buf)
+	f.SetPrefixFilters(2)
+
+	f.OnWrite(bankKey, []byte("balances/addr1"), []byte("1"))
+	f.OnCommit(1)
+	f.OnWrite(accKey, []byte("addr1"), []byte("1"))
+	f.OnCommit(2)
+	require.NoError(t, f.Err())
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 5)
+	require.True(t, strings.HasPrefix(lines[1], "FIRE PREFIXES 1 2 "))
+	require.True(t, strings.HasPrefix(lines[2], "FIRE BLOCK 1 "))
+
+	r := NewFirehoseReader(&buf)
+	block, err := r.Next()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), block.Height)
+	require.True(t, block.MayTouch("bank", []byte("balances/")))
+	require.True(t, block.MayTouch("bank", nil))
+	require.False(t, block.MayTouch("acc", nil))
+
+	block, err = r.Next()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), block.Height)
+	require.False(t, block.MayTouch("bank", []byte("balances/")))
+	require.True(t, block.MayTouch("acc", []byte("ad")))
+
+	_, err = r.Next()
+	require.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,202 @@
+package streaming
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+const (
+	// StreamFormatVersion is the version of the stream format announced in
+	// the StreamHeader of the streams written by this package.
+	StreamFormatVersion = 1
+
+	// FramingFrameStream is the framing of streams holding the writes encoded
+	// by a FrameWriteListener.
+	FramingFrameStream = "frame-stream"
+	// FramingLengthPrefixed is the framing of streams holding the writes
+	// encoded by a Codec, each prefixed by its uvarint length.
+	FramingLengthPrefixed = "length-prefixed"
+
+	// FeatureLargeValues announces that values may be references to values
+	// moved aside by a LargeValues, resolved with ReadLargeValue.
+	FeatureLargeValues = "large-values"
+
+	// maxStreamHeaderLength bounds the length of a StreamHeader read from a
+	// stream.
+	maxStreamHeaderLength = 1 << 16
+)
+
+// streamMagic starts every stream with a StreamHeader. Its first byte is
+// neither a frame format version nor the start of a short length prefix, so
+// readers not expecting a header fail on it rather than misread the stream.
+var streamMagic = []byte("\x89CSS")
+
+var (
+	// ErrNoStreamHeader is returned when reading a StreamHeader from a stream
+	// which doesn't start with one.
+	ErrNoStreamHeader = errors.New("streaming: stream doesn't start with a header")
+	// ErrUnsupportedStreamVersion is returned when reading a stream of a
+	// newer format version than StreamFormatVersion.
+	ErrUnsupportedStreamVersion = errors.New("streaming: unsupported stream format version")
+)
+
+// StreamHeader describes the format of a stream, so readers don't have to
+// guess its framing and encoding, and can handle the evolution of the format.
+// It is written at the start of the stream as the magic bytes "\x89CSS"
+// followed by its uvarint length prefixed JSON encoding.
+type StreamHeader struct {
+	Version int    `json:"version"`
+	Framing string `json:"framing"`
+	// Codec is the name of the Codec of length prefixed streams, as accepted
+	// by GetCodec.
+	Codec    string   `json:"codec,omitempty"`
+	Features []string `json:"features,omitempty"`
+}
+
+// HasFeature returns true if the stream has the feature enabled.
+func (h StreamHeader) HasFeature(feature string) bool {
+	for _, f := range h.Features {
+		if f == feature {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeStreamHeader writes the encoding of the header to buf.
+func writeStreamHeader(buf *bytes.Buffer, header StreamHeader) error {
+	bz, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(bz)))
+
+	buf.Write(streamMagic)
+	buf.Write(lenBuf[:n])
+	buf.Write(bz)
+
+	return nil
+}
+
+// codecName returns the name of the codec as accepted by GetCodec, or its Go
+// type for codecs GetCodec doesn't know.
+func codecName(codec Codec) string {
+	for name, c := range codecs {
+		if reflect.TypeOf(c) == reflect.TypeOf(codec) {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("%T", codec)
+}
+
+// StreamReader reads the writes of a stream starting with a StreamHeader, in
+// whichever framing and encoding the header announces.
+type StreamReader struct {
+	reader *bufio.Reader
+	header StreamHeader
+	frames *types.FrameReader
+	codec  Codec
+}
+
+// NewStreamReader reads the StreamHeader at the start of r and returns a
+// StreamReader reading the writes following it.
+func NewStreamReader(r io.Reader) (*StreamReader, error) {
+	reader := bufio.NewReader(r)
+
+	magic := make([]byte, len(streamMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil {
+		return nil, truncatedHeader(err)
+	} else if !bytes.Equal(magic, streamMagic) {
+		return nil, ErrNoStreamHeader
+	}
+
+	n, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, truncatedHeader(err)
+	} else if n > maxStreamHeaderLength {
+		return nil, fmt.Errorf("stream header of %d bytes is too large", n)
+	}
+
+	bz := make([]byte, n)
+	if _, err := io.ReadFull(reader, bz); err != nil {
+		return nil, truncatedHeader(err)
+	}
+
+	var header StreamHeader
+	if err := json.Unmarshal(bz, &header); err != nil {
+		return nil, fmt.Errorf("invalid stream header: %w", err)
+	}
+
+	if header.Version > StreamFormatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedStreamVersion, header.Version)
+	}
+
+	sr := &StreamReader{reader: reader, header: header}
+	switch header.Framing {
+	case FramingFrameStream:
+		sr.frames = types.NewFrameReader(reader)
+	case FramingLengthPrefixed:
+		if sr.codec, err = GetCodec(header.Codec); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown stream framing %q", header.Framing)
+	}
+
+	return sr, nil
+}
+
+// truncatedHeader converts an unexpected end of the stream while reading a
+// StreamHeader into an error.
+func truncatedHeader(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.New("stream header is truncated")
+	}
+
+	return err
+}
+
+// Header returns the StreamHeader of the stream.
+func (r *StreamReader) Header() StreamHeader {
+	return r.header
+}
+
+// Next decodes the next write. It returns io.EOF once the stream ends.
+func (r *StreamReader) Next() (Event, error) {
+	if r.frames != nil {
+		record, err := r.frames.Next()
+		if err != nil {
+			return Event{}, err
+		}
+
+		return Event{StoreKey: record.StoreKey, Key: record.Key, Value: record.Value, Delete: record.Delete}, nil
+	}
+
+	n, err := binary.ReadUvarint(r.reader)
+	if err == io.EOF {
+		return Event{}, io.EOF
+	} else if err != nil {
+		return Event{}, types.ErrFrameTruncated
+	} else if n > types.MaxFrameFieldLength {
+		return Event{}, types.ErrFrameTooLarge
+	}
+
+	bz := make([]byte, n)
+	if _, err := io.ReadFull(r.reader, bz); err != nil {
+		return Event{}, types.ErrFrameTruncated
+	}
+
+	return r.codec.Unmarshal(bz)
+}
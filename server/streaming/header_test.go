@@ -0,0 +1,91 @@
+package streaming
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+func TestDestinationStreamHeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "header")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "stream.sock")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer l.Close()
+
+	d := NewDestination(path, DestinationUnixSocket)
+	d.SetCodec(JSONCodec{})
+	d.SetStreamHeader(FeatureLargeValues)
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	d.OnWrite(accKey, []byte("key0"), []byte("value0"))
+	require.Zero(t, d.Dropped())
+	require.NoError(t, d.Close())
+
+	conn := <-accepted
+	defer conn.Close()
+
+	r, err := NewStreamReader(conn)
+	require.NoError(t, err)
+	require.Equal(t, StreamHeader{
+		Version:  StreamFormatVersion,
+		Framing:  FramingLengthPrefixed,
+		Codec:    CodecJSON,
+		Features: []string{FeatureLargeValues},
+	}, r.Header())
+	require.True(t, r.Header().HasFeature(FeatureLargeValues))
+
+	event, err := r.Next()
+	require.NoError(t, err)
+	require.Equal(t, Event{StoreKey: "acc", Key: []byte("key0"), Value: []byte("value0")}, event)
+
+	_, err = r.Next()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestStreamReader(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeStreamHeader(&buf, StreamHeader{Version: StreamFormatVersion, Framing: FramingFrameStream}))
+
+	l := types.NewFrameWriteListener(&buf)
+	l.OnWrite(bankKey, []byte("key0"), nil)
+	require.NoError(t, l.Err())
+
+	r, err := NewStreamReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.False(t, r.Header().HasFeature(FeatureLargeValues))
+
+	event, err := r.Next()
+	require.NoError(t, err)
+	require.Equal(t, Event{StoreKey: "bank", Key: []byte("key0"), Delete: true}, event)
+
+	// streams without a header, or of a newer version, are rejected
+	buf.Reset()
+	types.NewFrameWriteListener(&buf).OnWrite(bankKey, []byte("key0"), nil)
+	_, err = NewStreamReader(&buf)
+	require.Equal(t, ErrNoStreamHeader, err)
+
+	buf.Reset()
+	require.NoError(t, writeStreamHeader(&buf, StreamHeader{Version: StreamFormatVersion + 1, Framing: FramingFrameStream}))
+	_, err = NewStreamReader(&buf)
+	require.True(t, errors.Is(err, ErrUnsupportedStreamVersion))
+}
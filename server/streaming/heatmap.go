@@ -0,0 +1,261 @@
+package streaming
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/armon/go-metrics"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/telemetry"
+)
+
+const (
+	// DefaultHeatmapPrefixLength is the default length of the key prefixes
+	// the accesses of a Heatmap are aggregated by.
+	DefaultHeatmapPrefixLength = 1
+	// DefaultHeatmapMaxKeys is the default number of hot keys tracked by a
+	// Heatmap.
+	DefaultHeatmapMaxKeys = 1024
+	// DefaultHotKeys is the default number of hot keys served by a Heatmap.
+	DefaultHotKeys = 10
+)
+
+var (
+	_ types.WriteListener          = (*Heatmap)(nil)
+	_ types.ReadListener           = (*Heatmap)(nil)
+	_ baseapp.BlockSummaryListener = (*Heatmap)(nil)
+)
+
+// AccessStats counts the reads and writes of a key, or of every key with a
+// given prefix, of a store. Key is base64 encoded in JSON.
+type AccessStats struct {
+	StoreKey string `json:"store_key"`
+	Key      []byte `json:"key"`
+	Reads    uint64 `json:"reads"`
+	Writes   uint64 `json:"writes"`
+}
+
+// Accesses returns the total number of reads and writes.
+func (s AccessStats) Accesses() uint64 {
+	return s.Reads + s.Writes
+}
+
+// HeatmapResponse is the JSON response of the Heatmap query endpoint.
+type HeatmapResponse struct {
+	Prefixes []AccessStats `json:"prefixes"`
+	HotKeys  []AccessStats `json:"hot_keys"`
+}
+
+// Heatmap is a WriteListener and ReadListener which counts the accesses to
+// the listened stores, so the hot spots of the state can be found when
+// optimizing store layouts and caches. Accesses are aggregated by store and
+// key prefix, and the most accessed keys are tracked individually.
+//
+// To bound its memory only maxKeys keys are tracked, using the space-saving
+// algorithm: once the limit is reached a newly accessed key replaces the least
+// accessed one and inherits its counts. The counts of hot keys are therefore
+// upper bounds, exact only as long as fewer than maxKeys keys were accessed.
+//
+// Only the reads reaching the committed stores are observed, those served by
+// the caches of a block or transaction are not.
+type Heatmap struct {
+	mtx       sync.Mutex
+	prefixLen int
+	maxKeys   int
+	prefixes  map[string]*AccessStats
+	keys      map[string]*AccessStats
+}
+
+// NewHeatmap returns a new Heatmap aggregating accesses by key prefixes of
+// prefixLen bytes and tracking up to maxKeys hot keys.
+func NewHeatmap(prefixLen, maxKeys int) *Heatmap {
+	if prefixLen <= 0 {
+		prefixLen = DefaultHeatmapPrefixLength
+	}
+	if maxKeys <= 0 {
+		maxKeys = DefaultHeatmapMaxKeys
+	}
+
+	return &Heatmap{
+		prefixLen: prefixLen,
+		maxKeys:   maxKeys,
+		prefixes:  make(map[string]*AccessStats),
+		keys:      make(map[string]*AccessStats),
+	}
+}
+
+// OnWrite implements the WriteListener interface.
+func (h *Heatmap) OnWrite(storeKey types.StoreKey, key []byte, _ []byte) {
+	h.record(storeKey, key, false)
+}
+
+// OnRead implements the ReadListener interface.
+func (h *Heatmap) OnRead(storeKey types.StoreKey, key []byte, _ []byte) {
+	h.record(storeKey, key, true)
+}
+
+// OnBlockSummary implements the baseapp BlockSummaryListener interface. It
+// reports the access counts of every prefix as telemetry gauges once per
+// block.
+func (h *Heatmap) OnBlockSummary(baseapp.BlockStateSummary) {
+	h.ReportMetrics()
+}
+
+// record counts an access to the key of the given store.
+func (h *Heatmap) record(storeKey types.StoreKey, key []byte, read bool) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	prefix := key
+	if len(prefix) > h.prefixLen {
+		prefix = prefix[:h.prefixLen]
+	}
+
+	id := accessID(storeKey.Name(), prefix)
+	stats, ok := h.prefixes[id]
+	if !ok {
+		stats = &AccessStats{StoreKey: storeKey.Name(), Key: append([]byte(nil), prefix...)}
+		h.prefixes[id] = stats
+	}
+	count(stats, read)
+
+	id = accessID(storeKey.Name(), key)
+	stats, ok = h.keys[id]
+	if !ok {
+		stats = &AccessStats{}
+		if len(h.keys) >= h.maxKeys {
+			stats = h.evictColdest()
+		}
+
+		stats.StoreKey = storeKey.Name()
+		stats.Key = append([]byte(nil), key...)
+		h.keys[id] = stats
+	}
+	count(stats, read)
+}
+
+// evictColdest removes the least accessed key and returns its stats. It must
+// be called with the lock held.
+func (h *Heatmap) evictColdest() *AccessStats {
+	var (
+		coldestID string
+		coldest   *AccessStats
+	)
+
+	for id, stats := range h.keys {
+		if coldest == nil || stats.Accesses() < coldest.Accesses() {
+			coldestID, coldest = id, stats
+		}
+	}
+
+	delete(h.keys, coldestID)
+	return coldest
+}
+
+// Prefixes returns the access counts of every accessed key prefix, most
+// accessed first.
+func (h *Heatmap) Prefixes() []AccessStats {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	return sortedStats(h.prefixes, len(h.prefixes))
+}
+
+// HotKeys returns the access counts of the n most accessed keys, most accessed
+// first.
+func (h *Heatmap) HotKeys(n int) []AccessStats {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	return sortedStats(h.keys, n)
+}
+
+// Reset clears every access count.
+func (h *Heatmap) Reset() {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.prefixes = make(map[string]*AccessStats)
+	h.keys = make(map[string]*AccessStats)
+}
+
+// ReportMetrics reports the read and write counts of every key prefix as the
+// streaming_heatmap_reads and streaming_heatmap_writes telemetry gauges,
+// labeled by store and hex encoded prefix.
+func (h *Heatmap) ReportMetrics() {
+	for _, stats := range h.Prefixes() {
+		labels := []metrics.Label{
+			telemetry.NewLabel("store", stats.StoreKey),
+			telemetry.NewLabel("prefix", hex.EncodeToString(stats.Key)),
+		}
+
+		telemetry.SetGaugeWithLabels([]string{"streaming", "heatmap", "reads"}, float32(stats.Reads), labels)
+		telemetry.SetGaugeWithLabels([]string{"streaming", "heatmap", "writes"}, float32(stats.Writes), labels)
+	}
+}
+
+// ServeHTTP implements the http.Handler interface. It responds with a
+// HeatmapResponse holding every prefix and the number of hot keys given by
+// the "n" query parameter, DefaultHotKeys if omitted.
+func (h *Heatmap) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := DefaultHotKeys
+	if param := r.URL.Query().Get("n"); param != "" {
+		var err error
+		if n, err = strconv.Atoi(param); err != nil || n < 0 {
+			http.Error(w, "invalid number of hot keys: "+param, http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(HeatmapResponse{
+		Prefixes: h.Prefixes(),
+		HotKeys:  h.HotKeys(n),
+	})
+}
+
+// accessID identifies a key or prefix of a store.
+func accessID(storeKey string, key []byte) string {
+	return storeKey + "/" + string(key)
+}
+
+// count increments the reads or writes of stats.
+func count(stats *AccessStats, read bool) {
+	if read {
+		stats.Reads++
+	} else {
+		stats.Writes++
+	}
+}
+
+// sortedStats returns copies of up to n of the stats, most accessed first and
+// then by store and key.
+func sortedStats(stats map[string]*AccessStats, n int) []AccessStats {
+	sorted := make([]AccessStats, 0, len(stats))
+	for _, s := range stats {
+		sorted = append(sorted, *s)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Accesses() != sorted[j].Accesses() {
+			return sorted[i].Accesses() > sorted[j].Accesses()
+		}
+		if sorted[i].StoreKey != sorted[j].StoreKey {
+			return sorted[i].StoreKey < sorted[j].StoreKey
+		}
+		return bytes.Compare(sorted[i].Key, sorted[j].Key) < 0
+	})
+
+	if n < len(sorted) {
+		sorted = sorted[:n]
+	}
+
+	return sorted
+}
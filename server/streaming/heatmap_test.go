@@ -0,0 +1,63 @@
+package streaming
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeatmap(t *testing.T) {
+	h := NewHeatmap(1, 2)
+
+	h.OnRead(accKey, []byte("a1"), []byte("value"))
+	h.OnRead(accKey, []byte("a1"), nil)
+	h.OnWrite(accKey, []byte("a1"), []byte("value"))
+	h.OnRead(accKey, []byte("a2"), nil)
+	h.OnWrite(bankKey, []byte("b1"), nil)
+
+	require.Equal(t, []AccessStats{
+		{StoreKey: "acc", Key: []byte("a"), Reads: 3, Writes: 1},
+		{StoreKey: "bank", Key: []byte("b"), Writes: 1},
+	}, h.Prefixes())
+
+	// b1 replaced a2, the least accessed key, and inherited its count
+	require.Equal(t, []AccessStats{
+		{StoreKey: "acc", Key: []byte("a1"), Reads: 2, Writes: 1},
+		{StoreKey: "bank", Key: []byte("b1"), Reads: 1, Writes: 1},
+	}, h.HotKeys(10))
+	require.Len(t, h.HotKeys(1), 1)
+
+	h.Reset()
+	require.Empty(t, h.Prefixes())
+	require.Empty(t, h.HotKeys(10))
+}
+
+func TestHeatmapServeHTTP(t *testing.T) {
+	h := NewHeatmap(0, 0)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	h.OnRead(accKey, []byte("a1"), nil)
+	h.OnRead(accKey, []byte("a2"), nil)
+	h.OnRead(accKey, []byte("a2"), nil)
+
+	res, err := http.Get(srv.URL + "?n=1")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var heatmap HeatmapResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&heatmap))
+	require.Equal(t, HeatmapResponse{
+		Prefixes: []AccessStats{{StoreKey: "acc", Key: []byte("a"), Reads: 3}},
+		HotKeys:  []AccessStats{{StoreKey: "acc", Key: []byte("a2"), Reads: 2}},
+	}, heatmap)
+
+	res, err = http.Get(srv.URL + "?n=x")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
@@ -0,0 +1,382 @@
+package streaming
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// JSONFieldNaming is the naming convention of the fields of the JSON objects
+// emitted by the sinks.
+type JSONFieldNaming int
+
+const (
+	// JSONSnakeCase names the fields in snake_case, e.g. store_key.
+	JSONSnakeCase JSONFieldNaming = iota
+	// JSONCamelCase names the fields in camelCase, e.g. storeKey, like the
+	// protobuf JSON mapping.
+	JSONCamelCase
+)
+
+var (
+	intType = reflect.TypeOf(sdk.Int{})
+	decType = reflect.TypeOf(sdk.Dec{})
+)
+
+// JSONOptions control the JSON emitted by the JSON producing sinks, such as
+// the JSONCodec, the Server and the Webhook, so their output matches the
+// schemas of existing consumers. The zero value emits the default encoding
+// of the sinks.
+type JSONOptions struct {
+	// FieldNaming is the naming convention of the fields of the objects. The
+	// keys of maps, such as the metadata of an Event, are never renamed.
+	FieldNaming JSONFieldNaming
+	// NativeNumbers encodes sdk.Int and sdk.Dec values as JSON numbers
+	// rather than strings. Consumers must then decode them with arbitrary
+	// precision.
+	NativeNumbers bool
+	// EmitDefaults emits the fields with zero values which are omitted by
+	// default.
+	EmitDefaults bool
+}
+
+// Marshal returns the JSON encoding of v according to the options.
+func (o JSONOptions) Marshal(v interface{}) ([]byte, error) {
+	if o == (JSONOptions{}) {
+		return json.Marshal(v)
+	}
+
+	tree, err := o.convert(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(tree)
+}
+
+// Unmarshal parses the JSON encoding of v emitted with the options. Fields
+// named in camelCase are matched to the snake_case names of the fields of v.
+func (o JSONOptions) Unmarshal(bz []byte, v interface{}) error {
+	if o.FieldNaming != JSONCamelCase {
+		return json.Unmarshal(bz, v)
+	}
+
+	var raw interface{}
+	dec := json.NewDecoder(bytes.NewReader(bz))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	bz, err := json.Marshal(snakeCaseKeys(raw, reflect.TypeOf(v)))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(bz, v)
+}
+
+// jsonField is a field of a jsonObject.
+type jsonField struct {
+	name  string
+	value interface{}
+}
+
+// jsonObject is a JSON object keeping the order of its fields.
+type jsonObject []jsonField
+
+// MarshalJSON implements the json.Marshaler interface.
+func (obj jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	for i, f := range obj {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		name, err := json.Marshal(f.name)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// convert converts the value into a tree encoded by encoding/json as the
+// options require.
+func (o JSONOptions) convert(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil, nil
+	}
+
+	if v.Type() == intType || v.Type() == decType {
+		if v.Interface().(interface{ IsNil() bool }).IsNil() {
+			return nil, nil
+		}
+
+		s := v.Interface().(interface{ String() string }).String()
+		if o.NativeNumbers {
+			return json.Number(s), nil
+		}
+
+		return s, nil
+	}
+
+	if m, ok := v.Interface().(json.Marshaler); ok && v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface {
+		bz, err := m.MarshalJSON()
+		return json.RawMessage(bz), err
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+
+		return o.convert(v.Elem())
+
+	case reflect.Struct:
+		obj := jsonObject{}
+		if err := o.convertFields(v, &obj); err != nil {
+			return nil, err
+		}
+
+		return obj, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface(), nil
+		}
+
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+		obj := make(jsonObject, 0, len(keys))
+		for _, key := range keys {
+			value, err := o.convert(v.MapIndex(key))
+			if err != nil {
+				return nil, err
+			}
+
+			obj = append(obj, jsonField{name: key.String(), value: value})
+		}
+
+		return obj, nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface(), nil
+		}
+
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+
+		values := make([]interface{}, v.Len())
+		for i := range values {
+			value, err := o.convert(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+
+			values[i] = value
+		}
+
+		return values, nil
+
+	default:
+		return v.Interface(), nil
+	}
+}
+
+// convertFields appends the exported fields of the struct to obj, following
+// their json tags, with the fields of embedded structs without tags inlined.
+func (o JSONOptions) convertFields(v reflect.Value, obj *jsonObject) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			if err := o.convertFields(v.Field(i), obj); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		value := v.Field(i)
+		if !o.EmitDefaults && strings.Contains(opts, "omitempty") && isEmptyValue(value) {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		converted, err := o.convert(value)
+		if err != nil {
+			return err
+		}
+
+		*obj = append(*obj, jsonField{name: o.fieldName(name), value: converted})
+	}
+
+	return nil
+}
+
+// fieldName returns the name of the field following the naming convention of
+// the options.
+func (o JSONOptions) fieldName(name string) string {
+	if o.FieldNaming != JSONCamelCase {
+		return name
+	}
+
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+
+	return strings.Join(parts, "")
+}
+
+// isEmptyValue returns true for the values omitted by encoding/json from the
+// fields tagged omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+// snakeCaseKeys renames the camelCase keys of the objects of the parsed JSON
+// value to snake_case. The keys of the objects decoded into maps of t are
+// kept.
+func snakeCaseKeys(raw interface{}, t reflect.Type) interface{} {
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+
+	switch raw := raw.(type) {
+	case map[string]interface{}:
+		if t != nil && t.Kind() == reflect.Map {
+			return raw
+		}
+
+		renamed := make(map[string]interface{}, len(raw))
+		for key, value := range raw {
+			name := snakeCase(key)
+			renamed[name] = snakeCaseKeys(value, structFieldType(t, name))
+		}
+
+		return renamed
+
+	case []interface{}:
+		for i, value := range raw {
+			raw[i] = snakeCaseKeys(value, t)
+		}
+
+		return raw
+
+	default:
+		return raw
+	}
+}
+
+// structFieldType returns the type of the field of the struct type t encoded
+// under the given name, nil if there is none.
+func structFieldType(t reflect.Type, name string) reflect.Type {
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if i := strings.Index(tag, ","); i >= 0 {
+			tag = tag[:i]
+		}
+
+		if field.Anonymous && tag == "" {
+			if ft := structFieldType(field.Type, name); ft != nil {
+				return ft
+			}
+			continue
+		}
+
+		if tag == name || (tag == "" && field.Name == name) {
+			return field.Type
+		}
+	}
+
+	return nil
+}
+
+// snakeCase converts a camelCase name to snake_case.
+func snakeCase(name string) string {
+	var b strings.Builder
+
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
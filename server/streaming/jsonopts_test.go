@@ -0,0 +1,73 @@
+package streaming
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestJSONOptions(t *testing.T) {
+	event := Event{
+		StoreKey: "bank",
+		Key:      []byte{0x01},
+		Metadata: map[string]interface{}{
+			"amount": sdk.NewInt(100),
+			"rate":   sdk.NewDecWithPrec(5, 1),
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		options  JSONOptions
+		expected string
+	}{
+		{
+			"default",
+			JSONOptions{},
+			`{"store_key":"bank","key":"AQ==","delete":false,"metadata":{"amount":"100","rate":"0.500000000000000000"}}`,
+		},
+		{
+			"camel case and native numbers",
+			JSONOptions{FieldNaming: JSONCamelCase, NativeNumbers: true},
+			`{"storeKey":"bank","key":"AQ==","delete":false,"metadata":{"amount":100,"rate":0.500000000000000000}}`,
+		},
+		{
+			"emit defaults",
+			JSONOptions{EmitDefaults: true},
+			`{"chain_id":"","app_version":"","store_key":"bank","key":"AQ==","value":null,"delete":false,"genesis":false,"ephemeral":false,"speculative":false,"metadata":{"amount":"100","rate":"0.500000000000000000"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			bz, err := tc.options.Marshal(event)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, string(bz))
+		})
+	}
+}
+
+func TestJSONCodecOptions(t *testing.T) {
+	codec := JSONCodec{Options: JSONOptions{FieldNaming: JSONCamelCase, EmitDefaults: true}}
+	event := Event{
+		ChainID:  "chain",
+		StoreKey: "bank",
+		Key:      []byte{0x01},
+		Value:    []byte{0x02},
+		Metadata: map[string]interface{}{"node_name": "validator"},
+	}
+
+	bz, err := codec.Marshal(event)
+	require.NoError(t, err)
+	require.Contains(t, string(bz), `"chainId":"chain","appVersion":"","storeKey":"bank"`)
+
+	// the keys of the metadata are not renamed
+	require.Contains(t, string(bz), `"metadata":{"node_name":"validator"}`)
+
+	decoded, err := codec.Unmarshal(bz)
+	require.NoError(t, err)
+	require.Equal(t, event, decoded)
+}
@@ -0,0 +1,154 @@
+package streaming
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// largeValueMagic starts every large value reference.
+const largeValueMagic = "\xfflarge-value:"
+
+// ErrNotLargeValueRef is returned when resolving a value which is not a large
+// value reference.
+var ErrNotLargeValueRef = errors.New("streaming: not a large value reference")
+
+var (
+	_ types.WriteListener    = (*LargeValues)(nil)
+	_ baseapp.CommitListener = (*LargeValues)(nil)
+	_ io.Closer              = (*LargeValues)(nil)
+)
+
+// LargeValues is a WriteListener passing the writes on to a sink, after
+// moving the values larger than a threshold to a side directory. The sink is
+// passed a reference to the value instead, which consumers resolve with
+// ReadLargeValue, so sinks bounding the size of their messages, such as a
+// RingDestination, never lose oversized values like wasm code.
+//
+// Values are stored in files named after their SHA-256 digest, so a value
+// written several times is stored once. The modification time of a file is
+// updated every time its value is written, so a DiskQuota dropping the oldest
+// files drops the least recently written values. Values which look like a reference
+// are always moved, so every value looking like a reference is one. If a
+// value can't be stored, the write is passed on with the value itself and the
+// error is returned from Err.
+type LargeValues struct {
+	mtx       sync.Mutex
+	sink      types.WriteListener
+	dir       string
+	threshold int
+	err       error
+}
+
+// NewLargeValues returns a new LargeValues passing the writes to sink, moving
+// the values of more than threshold bytes to the directory dir, created if it
+// doesn't exist.
+func NewLargeValues(sink types.WriteListener, dir string, threshold int) (*LargeValues, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &LargeValues{sink: sink, dir: dir, threshold: threshold}, nil
+}
+
+// OnWrite implements the WriteListener interface.
+func (l *LargeValues) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	if value != nil && (len(value) > l.threshold || IsLargeValueRef(value)) {
+		ref, err := l.store(value)
+		if err == nil {
+			value = ref
+		} else {
+			l.mtx.Lock()
+			l.err = fmt.Errorf("failed to store value of %d bytes: %w", len(value), err)
+			l.mtx.Unlock()
+		}
+	}
+
+	l.sink.OnWrite(storeKey, key, value)
+}
+
+// store writes the value to its file, unless it exists, in which case its
+// modification time is updated, and returns its reference.
+func (l *LargeValues) store(value []byte) ([]byte, error) {
+	digest := sha256.Sum256(value)
+	path := filepath.Join(l.dir, hex.EncodeToString(digest[:]))
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := writeFileAtomic(path, value); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else {
+		// the value is referenced again, so it is no longer the oldest
+		now := time.Now()
+		if err := os.Chtimes(path, now, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return append([]byte(largeValueMagic), digest[:]...), nil
+}
+
+// OnCommit implements the baseapp CommitListener interface. It passes the
+// commit on to the sink if it implements CommitListener.
+func (l *LargeValues) OnCommit(height int64) {
+	if cl, ok := l.sink.(baseapp.CommitListener); ok {
+		cl.OnCommit(height)
+	}
+}
+
+// Err returns the last error encountered storing a value.
+func (l *LargeValues) Err() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.err
+}
+
+// Close implements the io.Closer interface. It closes the sink if it
+// implements io.Closer.
+func (l *LargeValues) Close() error {
+	if closer, ok := l.sink.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// IsLargeValueRef returns true if the value is a reference to a value stored
+// by a LargeValues.
+func IsLargeValueRef(value []byte) bool {
+	return len(value) == len(largeValueMagic)+sha256.Size && bytes.HasPrefix(value, []byte(largeValueMagic))
+}
+
+// ReadLargeValue returns the value referenced by ref from the directory dir
+// of a LargeValues, after checking it against the digest of the reference.
+func ReadLargeValue(dir string, ref []byte) ([]byte, error) {
+	if !IsLargeValueRef(ref) {
+		return nil, ErrNotLargeValueRef
+	}
+
+	digest := ref[len(largeValueMagic):]
+	value, err := ioutil.ReadFile(filepath.Join(dir, hex.EncodeToString(digest)))
+	if err != nil {
+		return nil, err
+	}
+
+	if actual := sha256.Sum256(value); !bytes.Equal(actual[:], digest) {
+		return nil, fmt.Errorf("large value %X is corrupted", digest)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,80 @@
+package streaming
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+type valuesListener struct {
+	routedListener
+	values [][]byte
+}
+
+func (l *valuesListener) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	l.routedListener.OnWrite(storeKey, key, value)
+	l.values = append(l.values, value)
+}
+
+func TestLargeValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "large")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sink := &valuesListener{}
+	l, err := NewLargeValues(sink, dir, 8)
+	require.NoError(t, err)
+
+	large := bytes.Repeat([]byte{0xab}, 1024)
+	l.OnWrite(accKey, []byte("small"), []byte("value"))
+	l.OnWrite(accKey, []byte("large"), large)
+	l.OnWrite(accKey, []byte("deleted"), nil)
+	l.OnCommit(1)
+	require.NoError(t, l.Err())
+	require.NoError(t, l.Close())
+
+	require.Equal(t, 1, sink.commits)
+	require.True(t, sink.closed)
+	require.Len(t, sink.values, 3)
+	require.Equal(t, []byte("value"), sink.values[0])
+	require.Nil(t, sink.values[2])
+
+	ref := sink.values[1]
+	require.True(t, IsLargeValueRef(ref))
+	require.False(t, IsLargeValueRef(sink.values[0]))
+
+	value, err := ReadLargeValue(dir, ref)
+	require.NoError(t, err)
+	require.Equal(t, large, value)
+
+	_, err = ReadLargeValue(dir, []byte("value"))
+	require.Equal(t, ErrNotLargeValueRef, err)
+
+	// a small value looking like a reference is moved too
+	l.OnWrite(accKey, []byte("ref"), ref)
+	require.NotEqual(t, ref, sink.values[3])
+
+	value, err = ReadLargeValue(dir, sink.values[3])
+	require.NoError(t, err)
+	require.Equal(t, ref, value)
+
+	// writing a stored value again touches its file
+	path := filepath.Join(dir, hex.EncodeToString(ref[len(largeValueMagic):]))
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(path, old, old))
+
+	l.OnWrite(accKey, []byte("again"), large)
+	require.NoError(t, l.Err())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.True(t, info.ModTime().After(old.Add(time.Minute)))
+}
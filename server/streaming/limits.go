@@ -0,0 +1,87 @@
+package streaming
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Limits bounds the resources each streaming client may use, so a single
+// misbehaving consumer can't exhaust the node. A zero value disables the
+// corresponding limit.
+type Limits struct {
+	// MaxSubscriptions is the maximum number of concurrent subscriptions of a
+	// single client, identified by its authenticated identity or, without an
+	// Authorizer, by its remote address.
+	MaxSubscriptions int
+	// EventsPerSecond is the maximum rate at which events are sent to a
+	// subscription.
+	EventsPerSecond float64
+	// BytesPerSecond is the maximum rate at which event bytes are sent to a
+	// subscription.
+	BytesPerSecond float64
+}
+
+// clientID identifies the client of the request by the identity it
+// authenticated as with the authorizer or, without one, by its remote host.
+// Unauthenticated clients behind the same proxy share their limits.
+func clientID(r *http.Request, authorizer Authorizer) string {
+	if authorizer != nil {
+		return authorizer.Identify(r)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// tokenBucket is a token bucket rate limiter allowing bursts of up to one
+// second worth of tokens. It is not safe for concurrent use.
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a new full tokenBucket, or nil if rate is not
+// positive.
+func newTokenBucket(rate float64) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+
+	return &tokenBucket{rate: rate, tokens: rate, last: time.Now()}
+}
+
+// wait takes n tokens from the bucket, blocking until they are available or
+// the context is done. A nil bucket never blocks.
+func (b *tokenBucket) wait(ctx context.Context, n float64) error {
+	if b == nil {
+		return nil
+	}
+
+	now := time.Now()
+	b.tokens = math.Min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(-b.tokens / b.rate * float64(time.Second)))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+
+	case <-timer.C:
+		return nil
+	}
+}
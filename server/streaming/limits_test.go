@@ -0,0 +1,71 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	var unlimited *tokenBucket
+	require.NoError(t, unlimited.wait(context.Background(), 1e9))
+	require.Nil(t, newTokenBucket(0))
+
+	b := newTokenBucket(100)
+
+	// a full bucket allows a burst of one second worth of tokens
+	start := time.Now()
+	require.NoError(t, b.wait(context.Background(), 100))
+	require.Less(t, int64(time.Since(start)), int64(50*time.Millisecond))
+
+	start = time.Now()
+	require.NoError(t, b.wait(context.Background(), 10))
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(90*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, b.wait(ctx, 100))
+}
+
+func TestServerMaxSubscriptions(t *testing.T) {
+	s := NewServer(0)
+	s.SetLimits(Limits{MaxSubscriptions: 1})
+
+	first := &subscriber{client: "10.0.0.1", events: make(chan []byte)}
+	_, _, err := s.subscribe(first)
+	require.NoError(t, err)
+
+	_, status, err := s.subscribe(&subscriber{client: "10.0.0.1", events: make(chan []byte)})
+	require.True(t, errors.Is(err, ErrTooManySubscriptions))
+	require.Equal(t, http.StatusTooManyRequests, status)
+
+	// other clients are not affected
+	_, _, err = s.subscribe(&subscriber{client: "10.0.0.2", events: make(chan []byte)})
+	require.NoError(t, err)
+
+	s.mtx.Lock()
+	s.unsubscribe(first)
+	s.mtx.Unlock()
+
+	_, _, err = s.subscribe(&subscriber{client: "10.0.0.1", events: make(chan []byte)})
+	require.NoError(t, err)
+}
+
+func TestClientID(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?token=public", nil)
+	r.RemoteAddr = "10.0.0.1:26657"
+
+	// without an Authorizer, clients are identified by their remote host
+	require.Equal(t, "10.0.0.1", clientID(r, nil))
+
+	// with one, by the identity they authenticated as
+	require.Equal(t, "public", clientID(r, TokenAuthorizer{"public": {"bank"}}))
+
+	r.Header.Set("Authorization", "Bearer admin")
+	require.Equal(t, "admin", clientID(r, TokenAuthorizer{"admin": {AllStores}}))
+}
@@ -0,0 +1,525 @@
+package streaming
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// ErrUnknownDestination is returned when pausing or resuming a destination
+// which was not registered.
+var ErrUnknownDestination = errors.New("streaming: unknown destination")
+
+// SpillLimitAction is what a Pausable does once its spill file exceeds its
+// size limit.
+type SpillLimitAction int
+
+const (
+	// SpillLimitNone keeps spilling, ignoring the limit.
+	SpillLimitNone SpillLimitAction = iota
+	// SpillLimitBlock blocks the commit of every block until the Pausable is
+	// resumed. Block processing stops once the backlog of the streaming
+	// dispatcher, if any, is full, so no block is committed without being
+	// streamed.
+	SpillLimitBlock
+	// SpillLimitHalt stops the node gracefully, as if it reached its halt
+	// height, keeping the spill file to be resumed on restart.
+	SpillLimitHalt
+	// SpillLimitSlow delays the commit of every block by the delay set with
+	// SetSpillSlowDown, bounding the rate at which the node commits blocks, and
+	// so the rate at which the spill file grows, until the Pausable is resumed.
+	SpillLimitSlow
+)
+
+// DefaultSpillSlowDown is the default delay of the commit of every block once
+// the spill file of a Pausable exceeds its limit with SpillLimitSlow.
+const DefaultSpillSlowDown = time.Second
+
+// haltNode stops the node gracefully, like the BaseApp does at its halt
+// height.
+var haltNode = func() {
+	if p, err := os.FindProcess(os.Getpid()); err == nil {
+		if p.Signal(syscall.SIGINT) == nil || p.Signal(syscall.SIGTERM) == nil {
+			return
+		}
+	}
+
+	os.Exit(0)
+}
+
+var (
+	_ types.WriteListener          = (*Pausable)(nil)
+	_ baseapp.CommitListener       = (*Pausable)(nil)
+	_ baseapp.GenesisWriteListener = (*Pausable)(nil)
+	_ io.Closer                    = (*Pausable)(nil)
+)
+
+// Types of the stores of the spilled writes, other than KVStores.
+const (
+	spillStoreTransient = "transient"
+	spillStoreMemory    = "memory"
+)
+
+// spillEntry is a write, or the commit of a block, spilled while a Pausable
+// is paused. StoreType is the type of the store of the write, so its key can
+// be recreated if the spill is resumed after a restart.
+type spillEntry struct {
+	Event     *Event `json:"event,omitempty"`
+	StoreType string `json:"store_type,omitempty"`
+	Height    int64  `json:"height,omitempty"`
+}
+
+// newSpillEntry returns the spillEntry of a write.
+func newSpillEntry(storeKey types.StoreKey, key []byte, value []byte, genesis bool) spillEntry {
+	event := writeEvent(storeKey, key, value)
+	event.Genesis = genesis
+
+	entry := spillEntry{Event: &event}
+	switch storeKey.(type) {
+	case *types.TransientStoreKey:
+		entry.StoreType = spillStoreTransient
+	case *types.MemoryStoreKey:
+		entry.StoreType = spillStoreMemory
+	}
+
+	return entry
+}
+
+// Pausable is a WriteListener and CommitListener passing the writes and
+// commits on to a sink, unless it is paused. While paused, they are appended
+// to a spill file instead, and passed on in order once it is resumed, so the
+// consumer of the sink can be taken down for maintenance without stopping
+// the node or losing data.
+//
+// A Pausable closed while paused keeps its spill file, and a Pausable created
+// while its spill file exists starts paused, so the spilled writes survive a
+// restart of the node.
+//
+// The spilled writes are passed on with the StoreKeys they were made with, or
+// with those set with SetStoreKeys when resumed after a restart, so sinks
+// comparing StoreKeys by identity keep working.
+//
+// The spill file may be bounded with SetSpillLimit, for consumers which need
+// a complete stream and would rather slow, block or stop the node than have
+// it fill its disk.
+type Pausable struct {
+	mtx       sync.Mutex
+	resumed   *sync.Cond
+	sink      types.WriteListener
+	keys      map[string]types.StoreKey
+	spillPath string
+	spill     *os.File
+	buf       *bufio.Writer
+	enc       *json.Encoder
+	spilled   uint64
+	spillSize int64
+	maxSpill  int64
+	action    SpillLimitAction
+	slowDown  time.Duration
+	halted    bool
+	closed    bool
+	err       error
+}
+
+// NewPausable returns a new Pausable passing the writes to sink, spilling
+// them to the file at spillPath while paused.
+func NewPausable(sink types.WriteListener, spillPath string) (*Pausable, error) {
+	p := &Pausable{
+		sink:      sink,
+		keys:      make(map[string]types.StoreKey),
+		spillPath: spillPath,
+		slowDown:  DefaultSpillSlowDown,
+	}
+	p.resumed = sync.NewCond(&p.mtx)
+
+	if info, err := os.Stat(spillPath); err == nil {
+		if err := p.openSpill(os.O_APPEND); err != nil {
+			return nil, err
+		}
+		p.spillSize = info.Size()
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// SetSpillLimit sets the size in bytes above which the spill file triggers
+// the given action, checked on the commit of every block.
+func (p *Pausable) SetSpillLimit(maxBytes int64, action SpillLimitAction) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.maxSpill = maxBytes
+	p.action = action
+	p.resumed.Broadcast()
+}
+
+// SetStoreKeys sets the StoreKeys the spilled writes are passed on with, by
+// name. The StoreKeys of the writes made since the Pausable was created are
+// known already, so it only matters for a Pausable resuming the spill file
+// of a previous run.
+func (p *Pausable) SetStoreKeys(keys ...types.StoreKey) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for _, key := range keys {
+		p.keys[key.Name()] = key
+	}
+}
+
+// SetSpillSlowDown sets the delay of the commit of every block once the spill
+// file exceeds its limit with SpillLimitSlow.
+func (p *Pausable) SetSpillSlowDown(delay time.Duration) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.slowDown = delay
+}
+
+// openSpill opens the spill file with the given extra flags, pausing the
+// Pausable. It must be called with the lock held.
+func (p *Pausable) openSpill(flag int) error {
+	f, err := os.OpenFile(p.spillPath, os.O_RDWR|os.O_CREATE|flag, 0600)
+	if err != nil {
+		return err
+	}
+
+	p.spill = f
+	p.buf = bufio.NewWriter(f)
+	p.enc = json.NewEncoder(&countingWriter{writer: p.buf, count: &p.spillSize})
+
+	return nil
+}
+
+// Pause spills the subsequent writes and commits until Resume is called.
+func (p *Pausable) Pause() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.spill != nil {
+		return nil
+	}
+
+	return p.openSpill(os.O_TRUNC)
+}
+
+// Resume passes the spilled writes and commits on to the sink, in order, then
+// resumes passing the subsequent ones on directly. The writes made while the
+// spill is replayed wait for it to complete.
+func (p *Pausable) Resume() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.spill == nil {
+		return nil
+	}
+
+	if err := p.buf.Flush(); err != nil {
+		return err
+	}
+
+	if _, err := p.spill.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(p.spill))
+	for {
+		var entry spillEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("invalid spill file %s: %w", p.spillPath, err)
+		}
+
+		p.pass(entry)
+	}
+
+	if err := p.spill.Close(); err != nil {
+		return err
+	}
+
+	p.spill, p.buf, p.enc, p.spilled, p.spillSize, p.halted = nil, nil, nil, 0, 0, false
+	p.resumed.Broadcast()
+
+	return os.Remove(p.spillPath)
+}
+
+// pass passes the entry on to the sink.
+func (p *Pausable) pass(entry spillEntry) {
+	if entry.Event == nil {
+		if cl, ok := p.sink.(baseapp.CommitListener); ok {
+			cl.OnCommit(entry.Height)
+		}
+
+		return
+	}
+
+	value := entry.Event.Value
+	if !entry.Event.Delete && value == nil {
+		value = []byte{}
+	}
+
+	p.passWrite(p.storeKey(entry), entry.Event.Key, value, entry.Event.Genesis)
+}
+
+// passWrite passes the write on to the sink, through OnGenesisWrite for the
+// writes of the genesis state if the sink tells them apart.
+func (p *Pausable) passWrite(storeKey types.StoreKey, key []byte, value []byte, genesis bool) {
+	if gl, ok := p.sink.(baseapp.GenesisWriteListener); ok && genesis {
+		gl.OnGenesisWrite(storeKey, key, value)
+		return
+	}
+
+	p.sink.OnWrite(storeKey, key, value)
+}
+
+// storeKey returns the StoreKey of the spilled write, the one it was made
+// with if known, else a new one of the type of its store. It must be called
+// with the lock held.
+func (p *Pausable) storeKey(entry spillEntry) types.StoreKey {
+	name := entry.Event.StoreKey
+	if key, ok := p.keys[name]; ok {
+		return key
+	}
+
+	var key types.StoreKey
+	switch entry.StoreType {
+	case spillStoreTransient:
+		key = types.NewTransientStoreKey(name)
+	case spillStoreMemory:
+		key = types.NewMemoryStoreKey(name)
+	default:
+		key = types.NewKVStoreKey(name)
+	}
+	p.keys[name] = key
+
+	return key
+}
+
+// OnWrite implements the WriteListener interface.
+func (p *Pausable) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	p.onWrite(storeKey, key, value, false)
+}
+
+// OnGenesisWrite implements the baseapp GenesisWriteListener interface. The
+// writes of the genesis state are passed on to the OnGenesisWrite of the sink
+// if it implements it, and to its OnWrite otherwise.
+func (p *Pausable) OnGenesisWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	p.onWrite(storeKey, key, value, true)
+}
+
+// onWrite passes the write on to the sink, or spills it while paused.
+func (p *Pausable) onWrite(storeKey types.StoreKey, key []byte, value []byte, genesis bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.keys[storeKey.Name()] = storeKey
+
+	if p.spill == nil {
+		p.passWrite(storeKey, key, value, genesis)
+		return
+	}
+
+	p.spillEntry(newSpillEntry(storeKey, key, value, genesis))
+}
+
+// OnCommit implements the baseapp CommitListener interface.
+func (p *Pausable) OnCommit(height int64) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.spill == nil {
+		p.pass(spillEntry{Height: height})
+		return
+	}
+
+	p.spillEntry(spillEntry{Height: height})
+
+	// persist the spill once per block
+	if err := p.buf.Flush(); err != nil {
+		p.err = fmt.Errorf("failed to spill block %d: %w", height, err)
+	}
+
+	if !p.overLimit() {
+		return
+	}
+
+	switch p.action {
+	case SpillLimitBlock:
+		for p.overLimit() && p.action == SpillLimitBlock && !p.closed {
+			p.resumed.Wait()
+		}
+
+	case SpillLimitHalt:
+		if !p.halted {
+			p.halted = true
+			haltNode()
+		}
+
+	case SpillLimitSlow:
+		// release the lock so the Pausable can be resumed meanwhile
+		delay := p.slowDown
+		p.mtx.Unlock()
+		time.Sleep(delay)
+		p.mtx.Lock()
+	}
+}
+
+// overLimit returns true if the spill file exceeds its limit. It must be
+// called with the lock held.
+func (p *Pausable) overLimit() bool {
+	return p.spill != nil && p.maxSpill > 0 && p.spillSize > p.maxSpill
+}
+
+// spillEntry appends the entry to the spill file. It must be called with the
+// lock held.
+func (p *Pausable) spillEntry(entry spillEntry) {
+	if err := p.enc.Encode(entry); err != nil {
+		p.err = fmt.Errorf("failed to spill: %w", err)
+		return
+	}
+
+	p.spilled++
+}
+
+// Paused returns true if the Pausable is paused.
+func (p *Pausable) Paused() bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.spill != nil
+}
+
+// Spilled returns the number of writes and commits spilled since the
+// Pausable was paused, or since it was created for a paused Pausable.
+func (p *Pausable) Spilled() uint64 {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.spilled
+}
+
+// SpillSize returns the size in bytes of the spill file.
+func (p *Pausable) SpillSize() int64 {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.spillSize
+}
+
+// Err returns the last error encountered spilling a write or commit.
+func (p *Pausable) Err() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.err
+}
+
+// Close implements the io.Closer interface. It keeps the spill file of a
+// paused Pausable, releases the commit blocked on its limit, if any, and
+// closes the sink if it implements io.Closer.
+func (p *Pausable) Close() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.closed = true
+	p.resumed.Broadcast()
+
+	if p.spill != nil {
+		err := p.buf.Flush()
+		if closeErr := p.spill.Close(); err == nil {
+			err = closeErr
+		}
+		p.spill, p.buf, p.enc = nil, nil, nil
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if closer, ok := p.sink.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// countingWriter is an io.Writer adding the number of bytes written through
+// it to count.
+type countingWriter struct {
+	writer io.Writer
+	count  *int64
+}
+
+// Write implements the io.Writer interface.
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	*w.count += int64(n)
+
+	return n, err
+}
+
+// PauseControl pauses and resumes the Pausables registered with it by name.
+// Applications embedding it are served the /streaming/pause and
+// /streaming/resume endpoints by the streaming admin listener, configured in
+// the [streaming.admin] section of app.toml.
+type PauseControl struct {
+	mtx          sync.RWMutex
+	destinations map[string]*Pausable
+}
+
+// NewPauseControl returns a new PauseControl without destinations.
+func NewPauseControl() *PauseControl {
+	return &PauseControl{destinations: make(map[string]*Pausable)}
+}
+
+// Register registers the Pausable under the given name.
+func (c *PauseControl) Register(name string, p *Pausable) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.destinations[name] = p
+}
+
+// PauseStreaming pauses the destination registered under the given name.
+func (c *PauseControl) PauseStreaming(name string) error {
+	p, err := c.get(name)
+	if err != nil {
+		return err
+	}
+
+	return p.Pause()
+}
+
+// ResumeStreaming resumes the destination registered under the given name.
+func (c *PauseControl) ResumeStreaming(name string) error {
+	p, err := c.get(name)
+	if err != nil {
+		return err
+	}
+
+	return p.Resume()
+}
+
+// get returns the destination registered under the given name.
+func (c *PauseControl) get(name string) (*Pausable, error) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	p, ok := c.destinations[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDestination, name)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,180 @@
+package streaming
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+type keysListener struct {
+	keys    []types.StoreKey
+	genesis []string
+}
+
+func (l *keysListener) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	l.keys = append(l.keys, storeKey)
+}
+
+func (l *keysListener) OnGenesisWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	l.OnWrite(storeKey, key, value)
+	l.genesis = append(l.genesis, storeKey.Name()+"/"+string(key))
+}
+
+func TestPausable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pause")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	spillPath := filepath.Join(dir, "spill")
+	sink := &valuesListener{}
+	p, err := NewPausable(sink, spillPath)
+	require.NoError(t, err)
+
+	control := NewPauseControl()
+	control.Register("sink", p)
+	require.True(t, errors.Is(control.PauseStreaming("other"), ErrUnknownDestination))
+
+	p.OnWrite(accKey, []byte("a"), []byte("1"))
+	p.OnCommit(1)
+	require.Equal(t, []string{"acc/a"}, sink.writes)
+
+	require.NoError(t, control.PauseStreaming("sink"))
+	require.True(t, p.Paused())
+	p.OnWrite(accKey, []byte("b"), []byte{})
+	p.OnWrite(bankKey, []byte("c"), nil)
+	p.OnCommit(2)
+	require.Equal(t, []string{"acc/a"}, sink.writes)
+	require.Equal(t, 1, sink.commits)
+	require.Equal(t, uint64(3), p.Spilled())
+
+	// the spill survives a restart
+	require.NoError(t, p.Close())
+	require.True(t, sink.closed)
+	p, err = NewPausable(sink, spillPath)
+	require.NoError(t, err)
+	require.True(t, p.Paused())
+	p.OnWrite(accKey, []byte("d"), []byte("1"))
+	p.OnCommit(3)
+
+	control.Register("sink", p)
+	require.NoError(t, control.ResumeStreaming("sink"))
+	require.False(t, p.Paused())
+	require.NoError(t, p.Err())
+	require.Equal(t, []string{"acc/a", "acc/b", "bank/c", "acc/d"}, sink.writes)
+	require.Equal(t, [][]byte{[]byte("1"), {}, nil, []byte("1")}, sink.values)
+	require.Equal(t, 3, sink.commits)
+	require.NoFileExists(t, spillPath)
+
+	p.OnWrite(accKey, []byte("e"), []byte("1"))
+	require.Equal(t, "acc/e", sink.writes[len(sink.writes)-1])
+}
+
+func TestPausableSpillLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pause")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	halted := 0
+	defer func(halt func()) { haltNode = halt }(haltNode)
+	haltNode = func() { halted++ }
+
+	sink := &valuesListener{}
+	p, err := NewPausable(sink, filepath.Join(dir, "spill"))
+	require.NoError(t, err)
+	p.SetSpillLimit(1, SpillLimitHalt)
+	require.NoError(t, p.Pause())
+
+	p.OnWrite(accKey, []byte("a"), []byte("1"))
+	p.OnCommit(1)
+	p.OnCommit(2)
+	require.Equal(t, 1, halted, "the node should be halted once")
+	require.True(t, p.SpillSize() > 0)
+
+	// commits block until the destination is resumed
+	p.SetSpillLimit(1, SpillLimitBlock)
+	committed := make(chan struct{})
+	go func() {
+		p.OnCommit(3)
+		close(committed)
+	}()
+
+	select {
+	case <-committed:
+		t.Fatal("commit should block while the spill exceeds its limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, p.Resume())
+	<-committed
+	require.Equal(t, 3, sink.commits)
+	require.Zero(t, p.SpillSize())
+
+	// commits are delayed while the spill exceeds its limit
+	p.SetSpillLimit(1, SpillLimitSlow)
+	p.SetSpillSlowDown(50 * time.Millisecond)
+	require.NoError(t, p.Pause())
+
+	p.OnWrite(accKey, []byte("b"), []byte("2"))
+	start := time.Now()
+	p.OnCommit(4)
+	require.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	require.NoError(t, p.Resume())
+	start = time.Now()
+	p.OnCommit(5)
+	require.True(t, time.Since(start) < 50*time.Millisecond)
+	require.Equal(t, 5, sink.commits)
+}
+
+func TestPausableStoreKeys(t *testing.T) {
+	spillPath := filepath.Join(t.TempDir(), "spill")
+	memKey := types.NewMemoryStoreKey("mem")
+
+	sink := &keysListener{}
+	p, err := NewPausable(sink, spillPath)
+	require.NoError(t, err)
+
+	// the spilled writes are replayed with their StoreKeys and genesis flag
+	require.NoError(t, p.Pause())
+	p.OnGenesisWrite(accKey, []byte("a"), []byte("1"))
+	p.OnWrite(memKey, []byte("b"), []byte("2"))
+	p.OnCommit(1)
+	require.NoError(t, p.Resume())
+
+	require.Len(t, sink.keys, 2)
+	require.True(t, sink.keys[0] == accKey)
+	require.True(t, sink.keys[1] == memKey)
+	require.Equal(t, []string{"acc/a"}, sink.genesis)
+
+	// after a restart, the keys are recreated with their type unless set
+	require.NoError(t, p.Pause())
+	p.OnWrite(memKey, []byte("c"), []byte("3"))
+	p.OnCommit(2)
+	require.NoError(t, p.Close())
+
+	sink.keys = nil
+	p, err = NewPausable(sink, spillPath)
+	require.NoError(t, err)
+	require.NoError(t, p.Resume())
+	require.IsType(t, &types.MemoryStoreKey{}, sink.keys[0])
+	require.False(t, sink.keys[0] == memKey)
+
+	require.NoError(t, p.Pause())
+	p.OnWrite(memKey, []byte("d"), []byte("4"))
+	p.OnCommit(3)
+	require.NoError(t, p.Close())
+
+	sink.keys = nil
+	p, err = NewPausable(sink, spillPath)
+	require.NoError(t, err)
+	p.SetStoreKeys(memKey)
+	require.NoError(t, p.Resume())
+	require.True(t, sink.keys[0] == memKey)
+}
@@ -0,0 +1,272 @@
+package streaming
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// Default PipelineOptions.
+const (
+	DefaultPipelineWriters         = 1
+	DefaultPipelineInFlightBatches = 16
+	DefaultPipelineMaxBatchBytes   = 1 << 20
+	DefaultPipelineLinger          = 10 * time.Millisecond
+)
+
+var (
+	_ types.WriteListener    = (*Pipeline)(nil)
+	_ baseapp.CommitListener = (*Pipeline)(nil)
+	_ io.Closer              = (*Pipeline)(nil)
+)
+
+// PipelineOptions tune the concurrency and batching of a Pipeline.
+type PipelineOptions struct {
+	// Writers is the number of goroutines writing to the destination, each
+	// through a sink of its own.
+	Writers int
+	// InFlightBatches is the number of batches which may be queued for each
+	// writer before the writes block on the destination.
+	InFlightBatches int
+	// MaxBatchBytes is the size of the keys and values of the writes of a
+	// batch past which it is queued for its writer.
+	MaxBatchBytes int
+	// Linger is the longest a write waits for its batch to fill before it is
+	// queued, 0 holding writes until their batch is full or their block is
+	// committed.
+	Linger time.Duration
+}
+
+// DefaultPipelineOptions returns the default PipelineOptions, a single
+// writer with a backlog of batches of up to 1MiB.
+func DefaultPipelineOptions() PipelineOptions {
+	return PipelineOptions{
+		Writers:         DefaultPipelineWriters,
+		InFlightBatches: DefaultPipelineInFlightBatches,
+		MaxBatchBytes:   DefaultPipelineMaxBatchBytes,
+		Linger:          DefaultPipelineLinger,
+	}
+}
+
+// Validate returns an error if the options are invalid.
+func (o PipelineOptions) Validate() error {
+	switch {
+	case o.Writers < 1:
+		return fmt.Errorf("invalid pipeline writers %d, must be at least 1", o.Writers)
+	case o.InFlightBatches < 0:
+		return fmt.Errorf("invalid pipeline in-flight batches %d, must not be negative", o.InFlightBatches)
+	case o.MaxBatchBytes < 1:
+		return fmt.Errorf("invalid pipeline max batch bytes %d, must be at least 1", o.MaxBatchBytes)
+	case o.Linger < 0:
+		return fmt.Errorf("invalid pipeline linger %s, must not be negative", o.Linger)
+	}
+
+	return nil
+}
+
+// pipelineBatch is a batch of writes queued for a writer, or the commit of a
+// block once the writes of the block were queued.
+type pipelineBatch struct {
+	writes []baseapp.StoreKVPair
+	commit bool
+	height int64
+}
+
+// pipelineWriter is a goroutine writing the batches queued for it to its
+// sink, and the batch being filled for it.
+type pipelineWriter struct {
+	sink    types.WriteListener
+	batches chan pipelineBatch
+	batch   []baseapp.StoreKVPair
+	size    int
+	// generation identifies the batch being filled, so the linger timer of a
+	// batch which was already queued is ignored
+	generation uint64
+	timer      *time.Timer
+}
+
+// Pipeline is a WriteListener and CommitListener passing the writes on to a
+// destination through several writers, each writing batches of writes to a
+// sink of its own on a goroutine, so a destination whose writes are slow,
+// e.g. on a high-throughput chain, does not hold back the node. Writes are
+// assigned to the writers by store and key, so the writes to a key are
+// written in order, but writes to different keys may be written in any
+// order. Each sink is committed once every write of the block assigned to it
+// was written.
+//
+// Writes block once InFlightBatches batches are queued for their writer.
+// Closing the Pipeline writes the pending batches and closes the sinks
+// implementing io.Closer.
+type Pipeline struct {
+	mtx     sync.Mutex
+	options PipelineOptions
+	writers []*pipelineWriter
+	wg      sync.WaitGroup
+	closed  bool
+}
+
+// NewPipeline returns a new Pipeline with the given options, passing the
+// writes to the sinks returned by newSink for the index of every writer, e.g.
+// a Destination connected to the same consumer.
+func NewPipeline(options PipelineOptions, newSink func(writer int) types.WriteListener) (*Pipeline, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	p := &Pipeline{options: options, writers: make([]*pipelineWriter, options.Writers)}
+	for i := range p.writers {
+		w := &pipelineWriter{
+			sink:    newSink(i),
+			batches: make(chan pipelineBatch, options.InFlightBatches),
+		}
+		p.writers[i] = w
+
+		p.wg.Add(1)
+		go p.write(w)
+	}
+
+	return p, nil
+}
+
+// write writes the batches queued for the writer to its sink until the
+// Pipeline is closed.
+func (p *Pipeline) write(w *pipelineWriter) {
+	defer p.wg.Done()
+
+	for batch := range w.batches {
+		for _, kv := range batch.writes {
+			w.sink.OnWrite(kv.StoreKey, kv.Key, kv.Value)
+		}
+
+		if cl, ok := w.sink.(baseapp.CommitListener); ok && batch.commit {
+			cl.OnCommit(batch.height)
+		}
+	}
+}
+
+// OnWrite implements the WriteListener interface.
+func (p *Pipeline) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.closed {
+		return
+	}
+
+	w := p.writers[p.writerOf(storeKey, key)]
+	if len(w.batch) == 0 && p.options.Linger > 0 {
+		generation := w.generation
+		w.timer = time.AfterFunc(p.options.Linger, func() {
+			p.mtx.Lock()
+			defer p.mtx.Unlock()
+
+			if !p.closed && w.generation == generation {
+				p.queue(w)
+			}
+		})
+	}
+
+	w.batch = append(w.batch, baseapp.StoreKVPair{StoreKey: storeKey, Key: key, Value: value})
+	w.size += len(key) + len(value)
+
+	if w.size >= p.options.MaxBatchBytes {
+		p.queue(w)
+	}
+}
+
+// writerOf returns the index of the writer the writes to the key are
+// assigned to.
+func (p *Pipeline) writerOf(storeKey types.StoreKey, key []byte) int {
+	if len(p.writers) == 1 {
+		return 0
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(storeKey.Name())) //nolint:errcheck
+	h.Write([]byte{0})               //nolint:errcheck
+	h.Write(key)                     //nolint:errcheck
+
+	return int(h.Sum32() % uint32(len(p.writers)))
+}
+
+// queue queues the batch being filled for the writer, blocking while its
+// queue is full. It must be called with the lock held.
+func (p *Pipeline) queue(w *pipelineWriter) {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+
+	if len(w.batch) == 0 {
+		return
+	}
+
+	w.batches <- pipelineBatch{writes: w.batch}
+	w.batch, w.size = nil, 0
+	w.generation++
+}
+
+// SetEnvelope sets the Envelope of the sinks which implement the
+// EnvelopeSetter interface. It implements the EnvelopeSetter interface.
+func (p *Pipeline) SetEnvelope(envelope Envelope) {
+	for _, w := range p.writers {
+		if es, ok := w.sink.(EnvelopeSetter); ok {
+			es.SetEnvelope(envelope)
+		}
+	}
+}
+
+// OnCommit implements the baseapp CommitListener interface.
+func (p *Pipeline) OnCommit(height int64) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.closed {
+		return
+	}
+
+	for _, w := range p.writers {
+		p.queue(w)
+		w.batches <- pipelineBatch{commit: true, height: height}
+	}
+}
+
+// Close implements the io.Closer interface. It waits for the pending batches
+// to be written, then closes the sinks.
+func (p *Pipeline) Close() error {
+	p.mtx.Lock()
+	if p.closed {
+		p.mtx.Unlock()
+		return nil
+	}
+
+	for _, w := range p.writers {
+		p.queue(w)
+		close(w.batches)
+	}
+	p.closed = true
+	p.mtx.Unlock()
+
+	p.wg.Wait()
+
+	var errs []string
+	for i, w := range p.writers {
+		if c, ok := w.sink.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, fmt.Sprintf("writer %d: %s", i, err))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to close pipeline sinks: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package streaming
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// pipelineSink records the writes and commits passed to a writer of a
+// Pipeline.
+type pipelineSink struct {
+	mtx     sync.Mutex
+	writes  []string
+	commits []int64
+	closed  bool
+}
+
+func (s *pipelineSink) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.writes = append(s.writes, fmt.Sprintf("%s/%s=%s", storeKey.Name(), key, value))
+}
+
+func (s *pipelineSink) OnCommit(height int64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.commits = append(s.commits, height)
+}
+
+func (s *pipelineSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *pipelineSink) numWrites() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return len(s.writes)
+}
+
+func newPipeline(t *testing.T, options PipelineOptions) (*Pipeline, []*pipelineSink) {
+	sinks := make([]*pipelineSink, options.Writers)
+	p, err := NewPipeline(options, func(writer int) types.WriteListener {
+		sinks[writer] = &pipelineSink{}
+		return sinks[writer]
+	})
+	require.NoError(t, err)
+
+	return p, sinks
+}
+
+func TestPipeline(t *testing.T) {
+	options := DefaultPipelineOptions()
+	options.Writers = 3
+	options.MaxBatchBytes = 8
+	p, sinks := newPipeline(t, options)
+
+	for height := int64(1); height <= 2; height++ {
+		for i := 0; i < 20; i++ {
+			p.OnWrite(bankKey, []byte(fmt.Sprintf("key%d", i%5)), []byte(fmt.Sprintf("%d", height*100+int64(i))))
+		}
+		p.OnCommit(height)
+	}
+	require.NoError(t, p.Close())
+
+	// every write is written once, the writes to a key in order
+	var writes int
+	for _, sink := range sinks {
+		require.True(t, sink.closed)
+		require.Equal(t, []int64{1, 2}, sink.commits)
+
+		last := make(map[string]string)
+		for _, write := range sink.writes {
+			var key, value string
+			_, err := fmt.Sscanf(write, "bank/%4s=%s", &key, &value)
+			require.NoError(t, err)
+
+			if prev, ok := last[key]; ok {
+				require.Less(t, prev, value)
+			}
+			last[key] = value
+		}
+
+		writes += len(sink.writes)
+	}
+	require.Equal(t, 40, writes)
+
+	// writes after the pipeline is closed are dropped
+	p.OnWrite(bankKey, []byte("key0"), []byte("1"))
+	p.OnCommit(3)
+	require.NoError(t, p.Close())
+}
+
+func TestPipelineOrder(t *testing.T) {
+	options := DefaultPipelineOptions()
+	options.Linger = 0
+	p, sinks := newPipeline(t, options)
+
+	p.OnWrite(bankKey, []byte("b"), []byte("1"))
+	p.OnWrite(accKey, []byte("a"), nil)
+	p.OnCommit(1)
+	require.NoError(t, p.Close())
+
+	// a single writer keeps the order of every write
+	require.Equal(t, []string{"bank/b=1", "acc/a="}, sinks[0].writes)
+	require.Equal(t, []int64{1}, sinks[0].commits)
+}
+
+func TestPipelineLinger(t *testing.T) {
+	options := DefaultPipelineOptions()
+	options.Linger = 10 * time.Millisecond
+	p, sinks := newPipeline(t, options)
+	defer p.Close()
+
+	// the partial batch is queued once the write lingered
+	p.OnWrite(bankKey, []byte("b"), []byte("1"))
+	require.Eventually(t, func() bool { return sinks[0].numWrites() == 1 }, time.Second, time.Millisecond)
+}
+
+func TestPipelineOptionsValidate(t *testing.T) {
+	require.NoError(t, DefaultPipelineOptions().Validate())
+	require.Error(t, PipelineOptions{Writers: 0, MaxBatchBytes: 1}.Validate())
+	require.Error(t, PipelineOptions{Writers: 1, InFlightBatches: -1, MaxBatchBytes: 1}.Validate())
+	require.Error(t, PipelineOptions{Writers: 1}.Validate())
+	require.Error(t, PipelineOptions{Writers: 1, MaxBatchBytes: 1, Linger: -1}.Validate())
+
+	_, err := NewPipeline(PipelineOptions{}, nil)
+	require.Error(t, err)
+}
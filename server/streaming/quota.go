@@ -0,0 +1,194 @@
+package streaming
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/telemetry"
+)
+
+// QuotaAction is what a DiskQuota does once its directory exceeds its quota.
+type QuotaAction int
+
+const (
+	// QuotaAlert notifies the notifiers of the DiskQuota once the quota is
+	// exceeded, and again every time it is exceeded after being respected.
+	QuotaAlert QuotaAction = iota
+	// QuotaDropOldest removes the least recently modified files of the
+	// directory until the quota is respected. The files must not be written
+	// to anymore, like rotated files, or be expendable. The files of a
+	// LargeValues are touched every time their value is written again, so the
+	// values dropped are the least recently written ones, whose references
+	// can't be resolved anymore.
+	QuotaDropOldest
+	// QuotaHalt stops the node gracefully, as if it reached its halt height.
+	QuotaHalt
+)
+
+var (
+	_ types.WriteListener    = (*DiskQuota)(nil)
+	_ baseapp.CommitListener = (*DiskQuota)(nil)
+)
+
+// DiskQuota is a CommitListener bounding the disk usage of a directory of
+// streaming outputs, such as the directory of a LargeValues or of spill
+// files, checked on the commit of every block, so the streaming subsystem
+// can't fill the disk of the node unnoticed. The usage is reported as the
+// streaming_disk_usage_bytes gauge, labeled by directory.
+//
+// It is registered through AddStreamingListeners like any sink, and ignores
+// the writes.
+type DiskQuota struct {
+	mtx       sync.Mutex
+	dir       string
+	maxBytes  int64
+	action    QuotaAction
+	notifiers []Notifier
+	usage     int64
+	exceeded  bool
+	halted    bool
+	err       error
+}
+
+// NewDiskQuota returns a new DiskQuota bounding the usage of the directory dir
+// to maxBytes, taking the given action once it is exceeded. Notifiers are
+// notified when the quota is exceeded, whatever the action.
+func NewDiskQuota(dir string, maxBytes int64, action QuotaAction, notifiers ...Notifier) *DiskQuota {
+	return &DiskQuota{dir: dir, maxBytes: maxBytes, action: action, notifiers: notifiers}
+}
+
+// OnWrite implements the WriteListener interface. Writes are ignored.
+func (q *DiskQuota) OnWrite(types.StoreKey, []byte, []byte) {}
+
+// OnCommit implements the baseapp CommitListener interface. It checks the
+// usage of the directory.
+func (q *DiskQuota) OnCommit(height int64) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if err := q.check(height); err != nil {
+		q.err = fmt.Errorf("failed to check the disk usage of %s: %w", q.dir, err)
+	}
+}
+
+// check measures the usage of the directory and takes the action of the
+// quota if it is exceeded. It must be called with the lock held.
+func (q *DiskQuota) check(height int64) error {
+	files, err := dirFiles(q.dir)
+	if err != nil {
+		return err
+	}
+
+	q.usage = 0
+	for _, f := range files {
+		q.usage += f.size
+	}
+	defer func() {
+		telemetry.SetGaugeWithLabels(
+			[]string{"streaming", "disk_usage_bytes"}, float32(q.usage),
+			[]metrics.Label{{Name: "dir", Value: q.dir}},
+		)
+	}()
+
+	if q.usage <= q.maxBytes {
+		q.exceeded = false
+		return nil
+	}
+
+	if !q.exceeded {
+		q.exceeded = true
+		q.notify(fmt.Sprintf("Block %d\nstreaming output %s uses %d bytes, exceeding its quota of %d bytes", height, q.dir, q.usage, q.maxBytes))
+	}
+
+	switch q.action {
+	case QuotaDropOldest:
+		sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+		for _, f := range files {
+			if q.usage <= q.maxBytes {
+				break
+			}
+
+			if err := os.Remove(f.path); err != nil {
+				return err
+			}
+			q.usage -= f.size
+		}
+
+		q.exceeded = q.usage > q.maxBytes
+
+	case QuotaHalt:
+		if !q.halted {
+			q.halted = true
+			haltNode()
+		}
+	}
+
+	return nil
+}
+
+// notify sends the message to every notifier. It must be called with the
+// lock held.
+func (q *DiskQuota) notify(message string) {
+	var errs []string
+	for _, n := range q.notifiers {
+		if err := n.Notify(message); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		q.err = fmt.Errorf("failed to notify: %s", strings.Join(errs, "; "))
+	}
+}
+
+// Usage returns the usage in bytes of the directory as of the last check.
+func (q *DiskQuota) Usage() int64 {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	return q.usage
+}
+
+// Err returns the last error encountered checking the quota.
+func (q *DiskQuota) Err() error {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	return q.err
+}
+
+// dirFile is a regular file of a directory tree.
+type dirFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// dirFiles returns the regular files of the directory tree rooted at dir.
+func dirFiles(dir string) ([]dirFile, error) {
+	var files []dirFile
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			files = append(files, dirFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		}
+
+		return nil
+	})
+
+	return files, err
+}
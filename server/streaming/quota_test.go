@@ -0,0 +1,54 @@
+package streaming
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskQuota(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quota")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	halted := 0
+	defer func(halt func()) { haltNode = halt }(haltNode)
+	haltNode = func() { halted++ }
+
+	now := time.Now()
+	for i, name := range []string{"a", "b", "c"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, make([]byte, 10), 0600))
+		require.NoError(t, os.Chtimes(path, now, now.Add(time.Duration(i)*time.Minute)))
+	}
+
+	// alerts are sent once per crossing of the quota
+	notifier := &mockNotifier{}
+	q := NewDiskQuota(dir, 25, QuotaAlert, notifier)
+	q.OnCommit(1)
+	q.OnCommit(2)
+	require.Equal(t, int64(30), q.Usage())
+	require.Equal(t, []string{"Block 1\nstreaming output " + dir + " uses 30 bytes, exceeding its quota of 25 bytes"}, notifier.messages)
+
+	q = NewDiskQuota(dir, 30, QuotaHalt)
+	q.OnCommit(1)
+	require.Zero(t, halted)
+
+	q = NewDiskQuota(dir, 25, QuotaHalt)
+	q.OnCommit(1)
+	q.OnCommit(2)
+	require.Equal(t, 1, halted)
+
+	// the oldest files are dropped
+	q = NewDiskQuota(dir, 15, QuotaDropOldest)
+	q.OnCommit(1)
+	require.NoError(t, q.Err())
+	require.Equal(t, int64(10), q.Usage())
+	require.NoFileExists(t, filepath.Join(dir, "a"))
+	require.NoFileExists(t, filepath.Join(dir, "b"))
+	require.FileExists(t, filepath.Join(dir, "c"))
+}
@@ -0,0 +1,29 @@
+package streaming
+
+import (
+	"errors"
+	"net/url"
+)
+
+// RedactURL returns the URL reduced to its scheme and host, so it can be
+// logged or reported without leaking the credentials chat and webhook
+// services embed in the user info, path or query of their URLs.
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "<redacted>"
+	}
+
+	return u.Scheme + "://" + u.Host
+}
+
+// redactURLError redacts the URL of the *url.Error returned by an HTTP client,
+// which holds the full URL of the request.
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return &url.Error{Op: urlErr.Op, URL: RedactURL(urlErr.URL), Err: urlErr.Err}
+	}
+
+	return err
+}
@@ -0,0 +1,189 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrRelayGap is returned when relaying streams none of which can provide
+// the next height, e.g. because the sources lagging behind ended.
+var ErrRelayGap = errors.New("streaming: no source can provide the next block")
+
+// RelayStats reports what a relay did.
+type RelayStats struct {
+	// Blocks is the number of blocks relayed.
+	Blocks int64
+	// Failovers is the number of times the relay switched its active source.
+	Failovers int
+	// Active is the index of the source the last block was relayed from.
+	Active int
+	// SourceErrors holds the error each source ended with, nil for the
+	// sources which ended normally or were still being read.
+	SourceErrors []error
+}
+
+// relayBlock is a block, or the error ending a stream, read from a source.
+type relayBlock struct {
+	source int
+	block  FirehoseBlock
+	err    error
+}
+
+// RelayFirehose merges the Firehose streams of several redundant nodes of the
+// same chain into a single gap-free stream written to w, starting at the first
+// height read from any of them. Every height is relayed once, from the active
+// source, initially the first one. Blocks are relayed whole, so the writes of
+// a block keep their order. Once another source provides the next height, the
+// active source must provide it within stallTimeout, or the relay fails over
+// to that source. A source ending, or failing, is failed over immediately.
+//
+// RelayFirehose returns once every source ended, once ctx is done, or with
+// ErrRelayGap once none of the sources can provide the next height. The
+// blocks read ahead of the active source are held in memory until they are
+// relayed or superseded. Callers must close the sources which don't end on
+// their own for the goroutines reading them to exit.
+func RelayFirehose(ctx context.Context, w io.Writer, stallTimeout time.Duration, sources ...io.Reader) (RelayStats, error) {
+	stats := RelayStats{SourceErrors: make([]error, len(sources))}
+	if len(sources) == 0 {
+		return stats, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	blocks := make(chan relayBlock)
+	for i, source := range sources {
+		go readRelaySource(ctx, i, source, blocks)
+	}
+
+	var (
+		live    = len(sources)
+		ended   = make([]bool, len(sources))
+		last    = make([]int64, len(sources))
+		pending = make(map[int64]relayBlock)
+		next    int64
+		started bool
+		stalled bool
+		timer   *time.Timer
+		stall   <-chan time.Time
+	)
+
+	stopStall := func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		timer, stall, stalled = nil, nil, false
+	}
+	defer stopStall()
+
+	for {
+		// relay the pending blocks in order, from the active source unless
+		// it stalled or can't provide them
+		for {
+			rb, ok := pending[next]
+			if !ok {
+				break
+			}
+
+			if rb.source != stats.Active {
+				if !ended[stats.Active] && last[stats.Active] < next && !stalled {
+					if stall == nil {
+						timer = time.NewTimer(stallTimeout)
+						stall = timer.C
+					}
+					break
+				}
+
+				stats.Active = rb.source
+				stats.Failovers++
+			}
+			stopStall()
+
+			if stats.Blocks == 0 {
+				if err := writeFirehoseInit(w); err != nil {
+					return stats, err
+				}
+			}
+
+			if err := writeFirehoseBlock(w, rb.block); err != nil {
+				return stats, err
+			}
+
+			delete(pending, next)
+			next++
+			stats.Blocks++
+		}
+
+		if started && relayGap(ended, last, next) {
+			if len(pending) == 0 && live == 0 {
+				return stats, nil
+			}
+
+			return stats, fmt.Errorf("%w: height %d", ErrRelayGap, next)
+		} else if live == 0 {
+			return stats, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return stats, ctx.Err()
+
+		case <-stall:
+			stalled = true
+
+		case rb := <-blocks:
+			if rb.err != nil {
+				ended[rb.source] = true
+				live--
+				if rb.err != io.EOF {
+					stats.SourceErrors[rb.source] = rb.err
+				}
+				continue
+			}
+
+			height := rb.block.Height
+			last[rb.source] = height
+			if !started {
+				next, started = height, true
+			}
+
+			// blocks of the active source supersede the blocks of the others
+			if _, ok := pending[height]; height >= next && (!ok || rb.source == stats.Active) {
+				pending[height] = rb
+			}
+		}
+	}
+}
+
+// relayGap returns true if none of the sources which are still being read
+// can provide the block at the next height, as they read past it.
+func relayGap(ended []bool, last []int64, next int64) bool {
+	for i := range ended {
+		if !ended[i] && last[i] < next {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readRelaySource reads the blocks of a source until it ends or ctx is done.
+func readRelaySource(ctx context.Context, source int, r io.Reader, blocks chan<- relayBlock) {
+	reader := NewFirehoseReader(r)
+	for {
+		block, err := reader.Next()
+
+		select {
+		case blocks <- relayBlock{source: source, block: block, err: err}:
+		case <-ctx.Done():
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package streaming
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+func relayBlocks(n int) [][]types.FrameRecord {
+	blocks := make([][]types.FrameRecord, n)
+	for i := range blocks {
+		blocks[i] = []types.FrameRecord{{StoreKey: "acc", Key: []byte{byte(i)}, Value: []byte("value")}}
+	}
+
+	return blocks
+}
+
+func TestRelayFirehose(t *testing.T) {
+	blocks := relayBlocks(5)
+	expected := strings.TrimPrefix(firehoseStream(1, blocks...).String(), "node log line\n")
+
+	// the first source ends early, the relay fails over to the second one
+	var out bytes.Buffer
+	stats, err := RelayFirehose(context.Background(), &out, time.Minute, firehoseStream(1, blocks[:3]...), firehoseStream(1, blocks...))
+	require.NoError(t, err)
+	require.Equal(t, expected, out.String())
+	require.Equal(t, int64(5), stats.Blocks)
+	require.Equal(t, 1, stats.Failovers)
+	require.Equal(t, 1, stats.Active)
+	require.Equal(t, []error{nil, nil}, stats.SourceErrors)
+
+	// a source failing is failed over like a source ending
+	out.Reset()
+	corrupted := firehoseStream(1, blocks[:2]...)
+	corrupted.WriteString("FIRE BLOCK 3 !\n")
+	stats, err = RelayFirehose(context.Background(), &out, time.Minute, corrupted, firehoseStream(1, blocks...))
+	require.NoError(t, err)
+	require.Equal(t, expected, out.String())
+	require.Error(t, stats.SourceErrors[0])
+	require.NoError(t, stats.SourceErrors[1])
+}
+
+func TestRelayFirehoseStall(t *testing.T) {
+	blocks := relayBlocks(3)
+
+	// the first source stalls after the first block
+	stalled, pw := io.Pipe()
+	defer pw.Close()
+	go pw.Write(firehoseStream(1, blocks[0]).Bytes()) //nolint:errcheck
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	var out bytes.Buffer
+	stats, err := RelayFirehose(ctx, &out, 10*time.Millisecond, stalled, firehoseStream(1, blocks...))
+
+	// the relay keeps waiting for the stalled source to catch up
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Equal(t, strings.TrimPrefix(firehoseStream(1, blocks...).String(), "node log line\n"), out.String())
+	require.Equal(t, int64(3), stats.Blocks)
+	require.Equal(t, 1, stats.Failovers)
+	require.Equal(t, 1, stats.Active)
+}
+
+func TestRelayFirehoseGap(t *testing.T) {
+	blocks := relayBlocks(3)
+
+	// no source provides the block at height 2
+	skipping := firehoseStream(1, blocks[0])
+	skipping.Write(firehoseStream(3, blocks[2]).Bytes())
+
+	var out bytes.Buffer
+	stats, err := RelayFirehose(context.Background(), &out, time.Minute, firehoseStream(1, blocks[0]), skipping)
+	require.True(t, errors.Is(err, ErrRelayGap))
+	require.Equal(t, int64(1), stats.Blocks)
+}
@@ -0,0 +1,308 @@
+// +build linux darwin freebsd netbsd openbsd
+
+package streaming
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+const (
+	// ringMagic identifies the files holding a Ring.
+	ringMagic uint64 = 0x676e697273736d63 // "cmssring"
+
+	// The header holds the magic and capacity, then the write and read
+	// offsets on cache lines of their own, as they are owned by different
+	// processes.
+	ringMagicOffset    = 0
+	ringCapacityOffset = 8
+	ringWriteOffset    = 64
+	ringReadOffset     = 128
+	ringHeaderSize     = 192
+
+	// ringLengthSize is the size of the length prefix of every message.
+	ringLengthSize = 4
+)
+
+var (
+	// ErrRingFull is returned when pushing a message to a Ring without
+	// enough free space.
+	ErrRingFull = errors.New("streaming: ring buffer full")
+	// ErrRingEmpty is returned when popping a message from an empty Ring.
+	ErrRingEmpty = errors.New("streaming: ring buffer empty")
+)
+
+// Ring is a single-producer single-consumer ring buffer of messages in a
+// memory-mapped file, shared between the node and a co-located consumer so
+// the stream is passed without any system call. The producer and consumer
+// each own one of the offsets of the ring, which only ever grow, and publish
+// them with atomic operations.
+//
+// A Ring is not safe for concurrent use: a single goroutine may push, and a
+// single goroutine may pop, messages.
+type Ring struct {
+	file     *os.File
+	mem      []byte
+	data     []byte
+	capacity uint64
+}
+
+// CreateRing creates, or truncates, the file at path and maps a Ring of the
+// given capacity in bytes onto it.
+func CreateRing(path string, capacity int) (*Ring, error) {
+	if capacity <= ringLengthSize {
+		return nil, fmt.Errorf("invalid ring buffer capacity %d", capacity)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := file.Truncate(int64(ringHeaderSize + capacity)); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	r, err := mapRing(file, ringHeaderSize+capacity)
+	if err != nil {
+		return nil, err
+	}
+
+	binary.LittleEndian.PutUint64(r.mem[ringCapacityOffset:], uint64(capacity))
+	r.capacity = uint64(capacity)
+	r.data = r.mem[ringHeaderSize:]
+
+	// the magic is written last, so a consumer never opens a partial header
+	atomic.StoreUint64(r.header(ringMagicOffset), ringMagic)
+
+	return r, nil
+}
+
+// OpenRing maps the Ring created by CreateRing at path.
+func OpenRing(path string) (*Ring, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if info.Size() <= ringHeaderSize {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a ring buffer", path)
+	}
+
+	r, err := mapRing(file, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+
+	r.capacity = binary.LittleEndian.Uint64(r.mem[ringCapacityOffset:])
+	if atomic.LoadUint64(r.header(ringMagicOffset)) != ringMagic || ringHeaderSize+r.capacity != uint64(info.Size()) {
+		r.Close()
+		return nil, fmt.Errorf("%s is not a ring buffer", path)
+	}
+	r.data = r.mem[ringHeaderSize:]
+
+	return r, nil
+}
+
+// mapRing maps size bytes of the file into memory, closing it on error.
+func mapRing(file *os.File, size int) (*Ring, error) {
+	mem, err := syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &Ring{file: file, mem: mem}, nil
+}
+
+// header returns a pointer to the 8 byte aligned header field at the given
+// offset.
+func (r *Ring) header(offset int) *uint64 {
+	return (*uint64)(unsafe.Pointer(&r.mem[offset]))
+}
+
+// Push appends the message to the ring. It returns ErrRingFull if there is
+// not enough free space, in which case the message is not pushed.
+func (r *Ring) Push(msg []byte) error {
+	write := atomic.LoadUint64(r.header(ringWriteOffset))
+	read := atomic.LoadUint64(r.header(ringReadOffset))
+
+	size := uint64(ringLengthSize + len(msg))
+	if size > r.capacity-(write-read) {
+		return ErrRingFull
+	}
+
+	var length [ringLengthSize]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(msg)))
+
+	r.copyIn(write, length[:])
+	r.copyIn(write+ringLengthSize, msg)
+
+	// publish the message once it is fully copied
+	atomic.StoreUint64(r.header(ringWriteOffset), write+size)
+
+	return nil
+}
+
+// Pop removes the oldest message from the ring and returns a copy of it. It
+// returns ErrRingEmpty if there is none.
+func (r *Ring) Pop() ([]byte, error) {
+	read := atomic.LoadUint64(r.header(ringReadOffset))
+	write := atomic.LoadUint64(r.header(ringWriteOffset))
+
+	if read == write {
+		return nil, ErrRingEmpty
+	}
+
+	var length [ringLengthSize]byte
+	r.copyOut(read, length[:])
+
+	msg := make([]byte, binary.LittleEndian.Uint32(length[:]))
+	r.copyOut(read+ringLengthSize, msg)
+
+	// release the space once the message is fully copied
+	atomic.StoreUint64(r.header(ringReadOffset), read+uint64(ringLengthSize+len(msg)))
+
+	return msg, nil
+}
+
+// copyIn copies p into the ring at the given offset, wrapping around its end.
+func (r *Ring) copyIn(offset uint64, p []byte) {
+	n := copy(r.data[offset%r.capacity:], p)
+	copy(r.data, p[n:])
+}
+
+// copyOut copies the bytes of the ring at the given offset into p, wrapping
+// around its end.
+func (r *Ring) copyOut(offset uint64, p []byte) {
+	n := copy(p, r.data[offset%r.capacity:])
+	copy(p[n:], r.data)
+}
+
+// Close unmaps the ring and closes its file.
+func (r *Ring) Close() error {
+	err := syscall.Munmap(r.mem)
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+var _ types.WriteListener = (*RingDestination)(nil)
+
+// RingDestination is a WriteListener pushing every write to a Ring, as a
+// message holding the write encoded by its Codec, FrameCodec by default.
+// Writes pushed while the ring is full, i.e. while the consumer lags behind,
+// are dropped and counted.
+type RingDestination struct {
+	mtx      sync.Mutex
+	ring     *Ring
+	codec    Codec
+	envelope Envelope
+	dropped  uint64
+	closed   bool
+}
+
+// NewRingDestination returns a new RingDestination pushing to the ring.
+func NewRingDestination(ring *Ring) *RingDestination {
+	return &RingDestination{ring: ring, codec: FrameCodec{}}
+}
+
+// SetCodec sets the Codec the writes are encoded with.
+func (d *RingDestination) SetCodec(codec Codec) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.codec = codec
+}
+
+// SetEnvelope sets the Envelope every write is stamped with. It implements
+// the EnvelopeSetter interface.
+func (d *RingDestination) SetEnvelope(envelope Envelope) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.envelope = envelope.copy()
+}
+
+// OnWrite implements the WriteListener interface.
+func (d *RingDestination) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.closed {
+		return
+	}
+
+	event := writeEvent(storeKey, key, value)
+	d.envelope.stamp(&event)
+
+	bz, err := d.codec.Marshal(event)
+	if err != nil || d.ring.Push(bz) != nil {
+		d.dropped++
+	}
+}
+
+// Dropped returns the number of writes dropped because the ring was full, or
+// because they failed to encode.
+func (d *RingDestination) Dropped() uint64 {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	return d.dropped
+}
+
+// Close implements the io.Closer interface. It closes the ring and ignores
+// every subsequent write.
+func (d *RingDestination) Close() error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.closed {
+		return nil
+	}
+
+	d.closed = true
+	return d.ring.Close()
+}
+
+// RingReader decodes the writes pushed to a Ring by a RingDestination with
+// the same Codec.
+type RingReader struct {
+	ring  *Ring
+	codec Codec
+}
+
+// NewRingReader returns a RingReader popping the writes from the ring and
+// decoding them with the codec.
+func NewRingReader(ring *Ring, codec Codec) *RingReader {
+	return &RingReader{ring: ring, codec: codec}
+}
+
+// Next decodes the oldest write of the ring. It returns ErrRingEmpty if there
+// is none, in which case the consumer should retry later.
+func (r *RingReader) Next() (Event, error) {
+	msg, err := r.ring.Pop()
+	if err != nil {
+		return Event{}, err
+	}
+
+	return r.codec.Unmarshal(msg)
+}
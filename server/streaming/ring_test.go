@@ -0,0 +1,96 @@
+// +build linux darwin freebsd netbsd openbsd
+
+package streaming
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ring")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "stream.ring")
+	producer, err := CreateRing(path, 16)
+	require.NoError(t, err)
+	defer producer.Close()
+
+	consumer, err := OpenRing(path)
+	require.NoError(t, err)
+	defer consumer.Close()
+
+	_, err = consumer.Pop()
+	require.Equal(t, ErrRingEmpty, err)
+
+	require.NoError(t, producer.Push([]byte("abcdef")))
+	require.Equal(t, ErrRingFull, producer.Push([]byte("ghijkl")))
+
+	msg, err := consumer.Pop()
+	require.NoError(t, err)
+	require.Equal(t, []byte("abcdef"), msg)
+
+	// the messages wrap around the end of the ring
+	for i := 0; i < 10; i++ {
+		require.NoError(t, producer.Push([]byte("ghijkl")))
+		require.NoError(t, producer.Push([]byte{byte(i)}))
+
+		msg, err = consumer.Pop()
+		require.NoError(t, err)
+		require.Equal(t, []byte("ghijkl"), msg)
+
+		msg, err = consumer.Pop()
+		require.NoError(t, err)
+		require.Equal(t, []byte{byte(i)}, msg)
+	}
+
+	_, err = CreateRing(filepath.Join(dir, "invalid.ring"), 0)
+	require.Error(t, err)
+
+	invalid := filepath.Join(dir, "invalid")
+	require.NoError(t, ioutil.WriteFile(invalid, make([]byte, 1024), 0600))
+	_, err = OpenRing(invalid)
+	require.Error(t, err)
+}
+
+func TestRingDestination(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ring")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "stream.ring")
+	ring, err := CreateRing(path, 64)
+	require.NoError(t, err)
+
+	d := NewRingDestination(ring)
+	defer d.Close()
+
+	consumer, err := OpenRing(path)
+	require.NoError(t, err)
+	defer consumer.Close()
+	r := NewRingReader(consumer, FrameCodec{})
+
+	d.OnWrite(accKey, []byte("key0"), []byte("value0"))
+	d.OnWrite(bankKey, []byte("key1"), nil)
+	d.OnWrite(accKey, []byte("key2"), make([]byte, 64))
+	require.Equal(t, uint64(1), d.Dropped())
+
+	event, err := r.Next()
+	require.NoError(t, err)
+	require.Equal(t, Event{StoreKey: "acc", Key: []byte("key0"), Value: []byte("value0")}, event)
+
+	event, err = r.Next()
+	require.NoError(t, err)
+	require.Equal(t, Event{StoreKey: "bank", Key: []byte("key1"), Delete: true}, event)
+
+	_, err = r.Next()
+	require.Equal(t, ErrRingEmpty, err)
+
+	require.NoError(t, d.Close())
+	d.OnWrite(accKey, []byte("key3"), []byte("value3"))
+}
@@ -0,0 +1,99 @@
+package streaming
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+var (
+	_ types.WriteListener    = (*Router)(nil)
+	_ baseapp.CommitListener = (*Router)(nil)
+	_ io.Closer              = (*Router)(nil)
+)
+
+// Route sends the writes to the store named StoreKey, any if empty, with keys
+// starting with KeyPrefix to Listener. Key prefixes are how the modules lay
+// out their tables, e.g. the balances of x/bank, so routes can send each kind
+// of record to its own destination.
+type Route struct {
+	StoreKey  string
+	KeyPrefix []byte
+	Listener  types.WriteListener
+}
+
+// matches returns true if the route receives the write.
+func (r Route) matches(storeKey types.StoreKey, key []byte) bool {
+	return (r.StoreKey == "" || r.StoreKey == storeKey.Name()) && bytes.HasPrefix(key, r.KeyPrefix)
+}
+
+// Router is a WriteListener sending every write to the listeners of the
+// routes it matches, in the order of the routes. Writes matching no route are
+// dropped. The Router passes OnCommit and Close on to the listeners
+// implementing CommitListener and io.Closer, so it can be registered with the
+// BaseApp in their place.
+type Router struct {
+	routes []Route
+}
+
+// NewRouter returns a new Router sending the writes along the given routes.
+func NewRouter(routes ...Route) *Router {
+	return &Router{routes: routes}
+}
+
+// OnWrite implements the WriteListener interface.
+func (r *Router) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	for _, route := range r.routes {
+		if route.matches(storeKey, key) {
+			route.Listener.OnWrite(storeKey, key, value)
+		}
+	}
+}
+
+// OnCommit implements the baseapp CommitListener interface.
+func (r *Router) OnCommit(height int64) {
+	r.forEachListener(func(l types.WriteListener) {
+		if cl, ok := l.(baseapp.CommitListener); ok {
+			cl.OnCommit(height)
+		}
+	})
+}
+
+// Close implements the io.Closer interface.
+func (r *Router) Close() error {
+	var errs []string
+	r.forEachListener(func(l types.WriteListener) {
+		if c, ok := l.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	})
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to close routed listeners: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// forEachListener calls fn once for every listener of the routes, even if it
+// is the listener of several routes.
+func (r *Router) forEachListener(fn func(l types.WriteListener)) {
+	seen := make(map[types.WriteListener]bool, len(r.routes))
+	for _, route := range r.routes {
+		if reflect.TypeOf(route.Listener).Comparable() {
+			if seen[route.Listener] {
+				continue
+			}
+			seen[route.Listener] = true
+		}
+
+		fn(route.Listener)
+	}
+}
@@ -0,0 +1,52 @@
+package streaming
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+type routedListener struct {
+	writes  []string
+	commits int
+	closed  bool
+}
+
+func (l *routedListener) OnWrite(storeKey types.StoreKey, key []byte, _ []byte) {
+	l.writes = append(l.writes, storeKey.Name()+"/"+string(key))
+}
+
+func (l *routedListener) OnCommit(int64) { l.commits++ }
+
+func (l *routedListener) Close() error {
+	l.closed = true
+	return nil
+}
+
+func TestRouter(t *testing.T) {
+	balances, supply, acc := &routedListener{}, &routedListener{}, &routedListener{}
+	r := NewRouter(
+		Route{StoreKey: "bank", KeyPrefix: []byte("balances/"), Listener: balances},
+		Route{StoreKey: "bank", KeyPrefix: []byte("supply/"), Listener: supply},
+		Route{StoreKey: "acc", Listener: acc},
+		Route{KeyPrefix: []byte("supply/"), Listener: acc},
+	)
+
+	r.OnWrite(bankKey, []byte("balances/addr1"), []byte("1"))
+	r.OnWrite(bankKey, []byte("supply/stake"), []byte("1"))
+	r.OnWrite(bankKey, []byte("params"), []byte("1"))
+	r.OnWrite(accKey, []byte("addr1"), nil)
+	r.OnCommit(1)
+	require.NoError(t, r.Close())
+
+	require.Equal(t, []string{"bank/balances/addr1"}, balances.writes)
+	require.Equal(t, []string{"bank/supply/stake"}, supply.writes)
+	require.Equal(t, []string{"bank/supply/stake", "acc/addr1"}, acc.writes)
+
+	for _, l := range []*routedListener{balances, supply, acc} {
+		require.Equal(t, 1, l.commits, "listeners should be committed once")
+		require.True(t, l.closed)
+	}
+}
@@ -0,0 +1,396 @@
+// Package streaming is the home of the sinks the state changes committed by
+// the application are streamed to, along with their codecs and tooling. The
+// sinks include a Server streaming the changes to HTTP clients as server-sent
+// events (SSE), so browser based explorers can consume them without a gRPC-Web
+// proxy, a Destination writing them as frames to a Unix domain socket or named
+// pipe, a Firehose writing them as text lines, and Webhook endpoints.
+package streaming
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// DefaultSubscriberBuffer is the default number of events buffered for each
+// subscriber before it is considered too slow and disconnected.
+const DefaultSubscriberBuffer = 1024
+
+var (
+	_ types.WriteListener         = (*Server)(nil)
+	_ baseapp.SpeculativeListener = (*Server)(nil)
+)
+
+var (
+	// ErrClosed is returned when subscribing to a closed Server.
+	ErrClosed = errors.New("streaming: server closed")
+	// ErrTooManySubscriptions is returned when a client exceeds its maximum
+	// number of concurrent subscriptions.
+	ErrTooManySubscriptions = errors.New("streaming: too many subscriptions")
+)
+
+// Event is a single state change streamed to the subscribers. Key and Value
+// are base64 encoded in JSON. ChainID and AppVersion identify the network and
+// application the state change was made on, so consumers can ingest several
+// networks without collisions. Metadata holds the labels of the node the
+// event was streamed from, like the Metadata of a tracekv TraceOperation.
+// Genesis is set for the writes of the genesis state of a new chain,
+// Ephemeral for the writes to transient and memory stores, and Speculative
+// for the writes of checked or simulated transactions, which are never
+// committed.
+type Event struct {
+	ChainID     string                 `json:"chain_id,omitempty"`
+	AppVersion  string                 `json:"app_version,omitempty"`
+	StoreKey    string                 `json:"store_key"`
+	Key         []byte                 `json:"key"`
+	Value       []byte                 `json:"value,omitempty"`
+	Delete      bool                   `json:"delete"`
+	Genesis     bool                   `json:"genesis,omitempty"`
+	Ephemeral   bool                   `json:"ephemeral,omitempty"`
+	Speculative bool                   `json:"speculative,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// writeEvent returns the Event of a write, marked as ephemeral if the store
+// is a transient or memory store.
+func writeEvent(storeKey types.StoreKey, key []byte, value []byte) Event {
+	return Event{
+		StoreKey:  storeKey.Name(),
+		Key:       key,
+		Value:     value,
+		Delete:    value == nil,
+		Ephemeral: types.IsEphemeralStoreKey(storeKey),
+	}
+}
+
+// subscriber is a single HTTP client following the stream.
+type subscriber struct {
+	// client identifies the client the subscription counts towards
+	client string
+	// stores are the names of the stores the subscriber follows, all if empty
+	stores map[string]bool
+	events chan []byte
+}
+
+// follows returns true if the subscriber follows the given store.
+func (s *subscriber) follows(storeKey string) bool {
+	return len(s.stores) == 0 || s.stores[storeKey]
+}
+
+// Server is a WriteListener which streams every write it is passed to its
+// HTTP subscribers as server-sent events. It is registered with the BaseApp
+// through AddStreamingListeners and mounted on the API server router.
+//
+// Writes are never blocked on subscribers: a subscriber which falls more than
+// the buffer size behind, including because of its rate limits, is
+// disconnected and expected to reconnect.
+type Server struct {
+	mtx         sync.RWMutex
+	subscribers map[*subscriber]struct{}
+	clients     map[string]int
+	bufferSize  int
+	closed      bool
+	authorizer  Authorizer
+	limits      Limits
+	envelope    Envelope
+	jsonOptions JSONOptions
+}
+
+// NewServer returns a new Server buffering up to bufferSize events for each
+// subscriber.
+func NewServer(bufferSize int) *Server {
+	if bufferSize <= 0 {
+		bufferSize = DefaultSubscriberBuffer
+	}
+
+	return &Server{
+		subscribers: make(map[*subscriber]struct{}),
+		clients:     make(map[string]int),
+		bufferSize:  bufferSize,
+	}
+}
+
+// SetAuthorizer sets the Authorizer deciding which stores each request may
+// stream. Without one every store is streamed to any client.
+func (s *Server) SetAuthorizer(authorizer Authorizer) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.authorizer = authorizer
+}
+
+// SetEnvelope sets the Envelope every Event is stamped with. It implements
+// the EnvelopeSetter interface.
+func (s *Server) SetEnvelope(envelope Envelope) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.envelope = envelope.copy()
+}
+
+// SetChainInfo sets the chain ID and application version every Event is
+// stamped with.
+func (s *Server) SetChainInfo(chainID, appVersion string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.envelope.ChainID = chainID
+	s.envelope.AppVersion = appVersion
+}
+
+// SetMetadata sets the metadata every Event is stamped with, such as the node
+// moniker, region or custom labels, so events streamed by a fleet of nodes can
+// be attributed and deduplicated downstream. The map is copied.
+func (s *Server) SetMetadata(metadata map[string]interface{}) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.envelope.Metadata = Envelope{Metadata: metadata}.copy().Metadata
+}
+
+// SetJSONOptions sets the JSONOptions the Events are encoded with.
+func (s *Server) SetJSONOptions(options JSONOptions) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.jsonOptions = options
+}
+
+// SetLimits sets the Limits enforced on every client.
+func (s *Server) SetLimits(limits Limits) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.limits = limits
+}
+
+// OnWrite implements the WriteListener interface.
+func (s *Server) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	s.onWrite(writeEvent(storeKey, key, value))
+}
+
+// OnGenesisWrite streams a write of the genesis state, marking its Event.
+// It implements the baseapp GenesisWriteListener interface.
+func (s *Server) OnGenesisWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	event := writeEvent(storeKey, key, value)
+	event.Genesis = true
+
+	s.onWrite(event)
+}
+
+// OnSpeculativeTxStateChanges streams the writes of a checked or simulated
+// transaction, marking their Events as speculative. It implements the baseapp
+// SpeculativeListener interface.
+func (s *Server) OnSpeculativeTxStateChanges(_ sdk.Context, changes baseapp.SpeculativeTxStateChanges) {
+	for _, kv := range changes.Writes {
+		event := writeEvent(kv.StoreKey, kv.Key, kv.Value)
+		event.Speculative = true
+
+		s.onWrite(event)
+	}
+}
+
+// onWrite encodes the event of a write once and queues it for every
+// subscriber following its store.
+func (s *Server) onWrite(event Event) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if len(s.subscribers) == 0 {
+		return
+	}
+
+	s.envelope.stamp(&event)
+
+	bz, err := s.jsonOptions.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	for sub := range s.subscribers {
+		if !sub.follows(event.StoreKey) {
+			continue
+		}
+
+		select {
+		case sub.events <- bz:
+		default:
+			s.unsubscribe(sub)
+		}
+	}
+}
+
+// ServeHTTP implements the http.Handler interface. It streams the writes of
+// the stores named by the repeated "store" query parameter, or of every
+// listened store if none is given, until the client disconnects.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	s.mtx.RLock()
+	authorizer := s.authorizer
+	s.mtx.RUnlock()
+
+	stores, status, err := authorizedStores(r, authorizer)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	sub := &subscriber{
+		client: clientID(r, authorizer),
+		stores: make(map[string]bool),
+		events: make(chan []byte, s.bufferSize),
+	}
+	for _, name := range stores {
+		sub.stores[name] = true
+	}
+
+	limits, status, err := s.subscribe(sub)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer func() {
+		s.mtx.Lock()
+		s.unsubscribe(sub)
+		s.mtx.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events := newTokenBucket(limits.EventsPerSecond)
+	bytes := newTokenBucket(limits.BytesPerSecond)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case bz, ok := <-sub.events:
+			if !ok {
+				return
+			}
+
+			if events.wait(r.Context(), 1) != nil || bytes.wait(r.Context(), float64(len(bz))) != nil {
+				return
+			}
+
+			if _, err := fmt.Fprintf(w, "event: write\ndata: %s\n\n", bz); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// authorizedStores returns the names of the stores the request is allowed to
+// stream by the authorizer, all if empty, along with the HTTP status to
+// respond with on error.
+func authorizedStores(r *http.Request, authorizer Authorizer) ([]string, int, error) {
+	requested := r.URL.Query()["store"]
+
+	if authorizer == nil {
+		return requested, http.StatusOK, nil
+	}
+
+	allowed, err := authorizer.Authorize(r)
+	if err != nil {
+		return nil, http.StatusUnauthorized, err
+	}
+
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		if name == AllStores {
+			return requested, http.StatusOK, nil
+		}
+
+		allowedSet[name] = true
+	}
+
+	if len(allowedSet) == 0 {
+		return nil, http.StatusForbidden, ErrForbidden
+	}
+
+	if len(requested) == 0 {
+		return allowed, http.StatusOK, nil
+	}
+
+	for _, name := range requested {
+		if !allowedSet[name] {
+			return nil, http.StatusForbidden, fmt.Errorf("%w: store %s", ErrForbidden, name)
+		}
+	}
+
+	return requested, http.StatusOK, nil
+}
+
+// Close implements the io.Closer interface. It disconnects every subscriber
+// and rejects new ones.
+func (s *Server) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.closed = true
+	for sub := range s.subscribers {
+		s.unsubscribe(sub)
+	}
+
+	return nil
+}
+
+// subscribe registers the subscriber and returns the Limits it is subject
+// to. It fails if the server is closed or the subscriber's client has reached
+// its maximum number of subscriptions, returning the HTTP status to respond
+// with.
+func (s *Server) subscribe(sub *subscriber) (Limits, int, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.closed {
+		return Limits{}, http.StatusServiceUnavailable, ErrClosed
+	}
+
+	if s.limits.MaxSubscriptions > 0 && s.clients[sub.client] >= s.limits.MaxSubscriptions {
+		return Limits{}, http.StatusTooManyRequests, ErrTooManySubscriptions
+	}
+
+	s.subscribers[sub] = struct{}{}
+	s.clients[sub.client]++
+
+	return s.limits, http.StatusOK, nil
+}
+
+// unsubscribe removes the subscriber and closes its event channel. It must be
+// called with the lock held.
+func (s *Server) unsubscribe(sub *subscriber) {
+	if _, ok := s.subscribers[sub]; ok {
+		delete(s.subscribers, sub)
+		close(sub.events)
+
+		s.clients[sub.client]--
+		if s.clients[sub.client] == 0 {
+			delete(s.clients, sub.client)
+		}
+	}
+}
+
+// numSubscribers returns the number of connected subscribers.
+func (s *Server) numSubscribers() int {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	return len(s.subscribers)
+}
@@ -0,0 +1,175 @@
+package streaming
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+var (
+	accKey  = types.NewKVStoreKey("acc")
+	bankKey = types.NewKVStoreKey("bank")
+)
+
+// readEvent reads the next server-sent event and decodes its data.
+func readEvent(t *testing.T, r *bufio.Reader) Event {
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "event: write\n", line)
+
+	line, err = r.ReadString('\n')
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(line, "data: "))
+
+	var event Event
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+
+	line, err = r.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "\n", line)
+
+	return event
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer(0)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	// writes are dropped while there are no subscribers
+	s.OnWrite(accKey, []byte("key0"), []byte("value0"))
+
+	res, err := http.Get(srv.URL + "?store=acc")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	require.Eventually(t, func() bool { return s.numSubscribers() == 1 }, time.Second, 10*time.Millisecond)
+
+	s.OnWrite(bankKey, []byte("key1"), []byte("value1"))
+	s.OnWrite(accKey, []byte("key2"), []byte("value2"))
+	s.OnWrite(accKey, []byte("key3"), nil)
+
+	r := bufio.NewReader(res.Body)
+	require.Equal(t, Event{StoreKey: "acc", Key: []byte("key2"), Value: []byte("value2")}, readEvent(t, r))
+	require.Equal(t, Event{StoreKey: "acc", Key: []byte("key3"), Delete: true}, readEvent(t, r))
+
+	require.NoError(t, s.Close())
+	_, err = r.ReadString('\n')
+	require.Error(t, err, "the stream should end once the server is closed")
+
+	res, err = http.Get(srv.URL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+}
+
+func TestServerSlowSubscriber(t *testing.T) {
+	s := NewServer(1)
+	sub := &subscriber{events: make(chan []byte, 1)}
+	_, _, err := s.subscribe(sub)
+	require.NoError(t, err)
+
+	s.OnWrite(accKey, []byte("key0"), []byte("value0"))
+	require.Equal(t, 1, s.numSubscribers())
+
+	// the subscriber's buffer is full so it is disconnected
+	s.OnWrite(accKey, []byte("key1"), []byte("value1"))
+	require.Equal(t, 0, s.numSubscribers())
+
+	_, ok := <-sub.events
+	require.True(t, ok)
+	_, ok = <-sub.events
+	require.False(t, ok)
+}
+
+func TestServerEventStamps(t *testing.T) {
+	s := NewServer(0)
+	s.SetChainInfo("testnet-1", "v1.0.0")
+
+	metadata := map[string]interface{}{"moniker": "node0", "region": "eu"}
+	s.SetMetadata(metadata)
+	metadata["region"] = "us"
+
+	sub := &subscriber{events: make(chan []byte, 1)}
+	_, _, err := s.subscribe(sub)
+	require.NoError(t, err)
+
+	s.OnWrite(accKey, []byte("key0"), []byte("value0"))
+
+	var event Event
+	require.NoError(t, json.Unmarshal(<-sub.events, &event))
+	require.Equal(t, Event{
+		ChainID:    "testnet-1",
+		AppVersion: "v1.0.0",
+		StoreKey:   "acc",
+		Key:        []byte("key0"),
+		Value:      []byte("value0"),
+		Metadata:   map[string]interface{}{"moniker": "node0", "region": "eu"},
+	}, event)
+}
+
+func TestServerGenesisWrite(t *testing.T) {
+	s := NewServer(0)
+	sub := &subscriber{events: make(chan []byte, 2)}
+	_, _, err := s.subscribe(sub)
+	require.NoError(t, err)
+
+	s.OnGenesisWrite(accKey, []byte("key0"), []byte("value0"))
+	s.OnWrite(accKey, []byte("key1"), []byte("value1"))
+
+	var event Event
+	require.NoError(t, json.Unmarshal(<-sub.events, &event))
+	require.Equal(t, Event{StoreKey: "acc", Key: []byte("key0"), Value: []byte("value0"), Genesis: true}, event)
+
+	event = Event{}
+	require.NoError(t, json.Unmarshal(<-sub.events, &event))
+	require.Equal(t, Event{StoreKey: "acc", Key: []byte("key1"), Value: []byte("value1")}, event)
+}
+
+func TestServerEphemeralWrite(t *testing.T) {
+	s := NewServer(0)
+	sub := &subscriber{events: make(chan []byte, 1)}
+	_, _, err := s.subscribe(sub)
+	require.NoError(t, err)
+
+	s.OnWrite(types.NewTransientStoreKey("params_transient"), []byte("key0"), []byte("value0"))
+
+	var event Event
+	require.NoError(t, json.Unmarshal(<-sub.events, &event))
+	require.Equal(t, Event{StoreKey: "params_transient", Key: []byte("key0"), Value: []byte("value0"), Ephemeral: true}, event)
+}
+
+func TestServerSpeculativeWrite(t *testing.T) {
+	s := NewServer(0)
+	sub := &subscriber{events: make(chan []byte, 2)}
+	_, _, err := s.subscribe(sub)
+	require.NoError(t, err)
+
+	s.OnSpeculativeTxStateChanges(sdk.Context{}, baseapp.SpeculativeTxStateChanges{
+		Mode: baseapp.SpeculativeModeCheck,
+		Writes: []baseapp.StoreKVPair{
+			{StoreKey: accKey, Key: []byte("key0"), Value: []byte("value0")},
+			{StoreKey: bankKey, Key: []byte("key1")},
+		},
+	})
+
+	var event Event
+	require.NoError(t, json.Unmarshal(<-sub.events, &event))
+	require.Equal(t, Event{StoreKey: "acc", Key: []byte("key0"), Value: []byte("value0"), Speculative: true}, event)
+
+	event = Event{}
+	require.NoError(t, json.Unmarshal(<-sub.events, &event))
+	require.Equal(t, Event{StoreKey: "bank", Key: []byte("key1"), Delete: true, Speculative: true}, event)
+}
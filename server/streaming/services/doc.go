@@ -0,0 +1,6 @@
+// Package services builds the streaming services enabled in the streaming
+// section of app.toml, or by the matching flags of the start command, and
+// registers them with an application. Unlike the server package, it does not
+// carry the node commands, so applications can register their streaming
+// services from their constructor without importing them.
+package services
@@ -0,0 +1,29 @@
+package services
+
+// State streaming-related flags. They are declared here, rather than by the
+// server package which registers them with the start command, so that
+// applications can parse them without importing the node commands.
+const (
+	FlagStreamingShutdownTimeout = "streaming.shutdown-timeout"
+	FlagStreamingRetention       = "streaming.retention"
+	FlagStreamingServices        = "streaming.services"
+	FlagStreamingKeys            = "streaming.keys"
+	FlagStreamingNamespace       = "streaming.namespace"
+	FlagStreamingMetadata        = "streaming.metadata"
+
+	FlagStreamingPipelineWriters         = "streaming.pipeline.writers"
+	FlagStreamingPipelineInFlightBatches = "streaming.pipeline.in-flight-batches"
+	FlagStreamingPipelineMaxBatchBytes   = "streaming.pipeline.max-batch-bytes"
+	FlagStreamingPipelineLinger          = "streaming.pipeline.linger"
+
+	FlagStreamingDestinationPath = "streaming.destination.path"
+	FlagStreamingDestinationKind = "streaming.destination.kind"
+	FlagStreamingWebhookURLs     = "streaming.webhook.urls"
+	FlagStreamingWebhookSecret   = "streaming.webhook.secret"
+	FlagStreamingFirehosePath    = "streaming.firehose.path"
+	FlagStreamingAdminAddress    = "streaming.admin.address"
+	FlagStreamingAdminToken      = "streaming.admin.token"
+
+	// flagHome mirrors flags.FlagHome.
+	flagHome = "home"
+)
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"github.com/spf13/cast"
+
+	"github.com/cosmos/cosmos-sdk/server/config"
+	"github.com/cosmos/cosmos-sdk/server/streaming"
+	"github.com/cosmos/cosmos-sdk/server/types"
+)
+
+// GetStreamingConfig parses the streaming flags, or their app.toml
+// counterparts, and returns the validated StreamingConfig. The options which
+// are not set keep their default value.
+func GetStreamingConfig(appOpts types.AppOptions) (config.StreamingConfig, error) {
+	cfg := config.DefaultConfig().Streaming
+
+	if v := appOpts.Get(FlagStreamingShutdownTimeout); v != nil {
+		cfg.ShutdownTimeout = cast.ToDuration(v)
+	}
+	if v := appOpts.Get(FlagStreamingRetention); v != nil {
+		cfg.Retention = cast.ToBool(v)
+	}
+	if v := appOpts.Get(FlagStreamingServices); v != nil {
+		cfg.Services = cast.ToStringSlice(v)
+	}
+	if v := appOpts.Get(FlagStreamingKeys); v != nil {
+		cfg.Keys = cast.ToStringSlice(v)
+	}
+	if v := appOpts.Get(FlagStreamingNamespace); v != nil {
+		cfg.Namespace = cast.ToBool(v)
+	}
+	if v := appOpts.Get(FlagStreamingMetadata); v != nil {
+		cfg.Metadata = cast.ToStringMapString(v)
+	}
+	if v := appOpts.Get(FlagStreamingPipelineWriters); v != nil {
+		cfg.Pipeline.Writers = cast.ToInt(v)
+	}
+	if v := appOpts.Get(FlagStreamingPipelineInFlightBatches); v != nil {
+		cfg.Pipeline.InFlightBatches = cast.ToInt(v)
+	}
+	if v := appOpts.Get(FlagStreamingPipelineMaxBatchBytes); v != nil {
+		cfg.Pipeline.MaxBatchBytes = cast.ToInt(v)
+	}
+	if v := appOpts.Get(FlagStreamingPipelineLinger); v != nil {
+		cfg.Pipeline.Linger = cast.ToDuration(v)
+	}
+	if v := appOpts.Get(FlagStreamingDestinationPath); v != nil {
+		cfg.Destination.Path = cast.ToString(v)
+	}
+	if v := appOpts.Get(FlagStreamingDestinationKind); v != nil {
+		cfg.Destination.Kind = cast.ToString(v)
+	}
+	if v := appOpts.Get(FlagStreamingWebhookURLs); v != nil {
+		cfg.Webhook.URLs = cast.ToStringSlice(v)
+	}
+	if v := appOpts.Get(FlagStreamingWebhookSecret); v != nil {
+		cfg.Webhook.Secret = config.Secret(cast.ToString(v))
+	}
+	if v := appOpts.Get(FlagStreamingFirehosePath); v != nil {
+		cfg.Firehose.Path = cast.ToString(v)
+	}
+	if v := appOpts.Get(FlagStreamingAdminAddress); v != nil {
+		cfg.Admin.Address = cast.ToString(v)
+	}
+	if v := appOpts.Get(FlagStreamingAdminToken); v != nil {
+		cfg.Admin.Token = config.Secret(cast.ToString(v))
+	}
+
+	if err := cfg.ValidateBasic(); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// GetStreamingPipelineOptions parses the streaming pipeline flags and returns
+// the PipelineOptions the app should create its pipelined destinations with.
+// The options which are not set keep their default value.
+func GetStreamingPipelineOptions(appOpts types.AppOptions) (streaming.PipelineOptions, error) {
+	cfg, err := GetStreamingConfig(appOpts)
+	if err != nil {
+		return streaming.PipelineOptions{}, err
+	}
+
+	return cfg.PipelineOptions(), nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/server/config"
+	"github.com/cosmos/cosmos-sdk/server/streaming"
+)
+
+func TestGetStreamingPipelineOptions(t *testing.T) {
+	// unset options keep their default value
+	opts, err := GetStreamingPipelineOptions(viper.New())
+	require.NoError(t, err)
+	require.Equal(t, streaming.DefaultPipelineOptions(), opts)
+
+	v := viper.New()
+	v.Set(FlagStreamingPipelineWriters, 4)
+	v.Set(FlagStreamingPipelineInFlightBatches, "32")
+	v.Set(FlagStreamingPipelineLinger, "50ms")
+
+	opts, err = GetStreamingPipelineOptions(v)
+	require.NoError(t, err)
+	require.Equal(t, streaming.PipelineOptions{
+		Writers:         4,
+		InFlightBatches: 32,
+		MaxBatchBytes:   streaming.DefaultPipelineMaxBatchBytes,
+		Linger:          50 * time.Millisecond,
+	}, opts)
+
+	v.Set(FlagStreamingPipelineWriters, 0)
+	_, err = GetStreamingPipelineOptions(v)
+	require.Error(t, err)
+}
+
+func TestGetStreamingConfig(t *testing.T) {
+	cfg, err := GetStreamingConfig(viper.New())
+	require.NoError(t, err)
+	require.Equal(t, config.DefaultConfig().Streaming, cfg)
+
+	v := viper.New()
+	v.Set(FlagStreamingShutdownTimeout, "1m")
+	v.Set(FlagStreamingRetention, "true")
+	v.Set(FlagStreamingPipelineMaxBatchBytes, 1024)
+	v.Set(FlagStreamingServices, []string{config.StreamingServiceFirehose})
+	v.Set(FlagStreamingMetadata, map[string]interface{}{"moniker": "node0"})
+
+	cfg, err = GetStreamingConfig(v)
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, cfg.ShutdownTimeout)
+	require.True(t, cfg.Retention)
+	require.Equal(t, 1024, cfg.Pipeline.MaxBatchBytes)
+	require.True(t, cfg.IsEnabled(config.StreamingServiceFirehose))
+	require.Equal(t, map[string]string{"moniker": "node0"}, cfg.Metadata)
+
+	// misconfigurations fail at startup
+	v.Set(FlagStreamingShutdownTimeout, "-1s")
+	_, err = GetStreamingConfig(v)
+	require.Error(t, err)
+}
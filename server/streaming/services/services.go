@@ -0,0 +1,187 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cast"
+	tmtypes "github.com/tendermint/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/server/config"
+	"github.com/cosmos/cosmos-sdk/server/streaming"
+	"github.com/cosmos/cosmos-sdk/server/types"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/version"
+)
+
+// StreamingListenerRegistrar is implemented by applications streaming the
+// writes of their stores to WriteListeners (e.g. BaseApp).
+type StreamingListenerRegistrar interface {
+	AddStreamingListeners(key sdk.StoreKey, listeners ...storetypes.WriteListener)
+}
+
+// firehoseFile is a Firehose writing to a file, closed with it.
+type firehoseFile struct {
+	*streaming.Firehose
+	file *os.File
+}
+
+var _ io.Closer = firehoseFile{}
+
+// Close implements the io.Closer interface.
+func (f firehoseFile) Close() error {
+	return f.file.Close()
+}
+
+// NewStreamingServices returns the WriteListeners of the streaming services
+// enabled in the configuration, in the order of StreamingConfig.Services,
+// stamped with the given Envelope. The destination is written through a
+// Pipeline with the configured options. With StreamingConfig.Namespace set,
+// the files of the services are namespaced by the chain ID of the Envelope.
+func NewStreamingServices(cfg config.StreamingConfig, envelope streaming.Envelope) ([]storetypes.WriteListener, error) {
+	if cfg.Namespace {
+		cfg.Destination.Path = envelope.Namespace(cfg.Destination.Path)
+		cfg.Firehose.Path = envelope.Namespace(cfg.Firehose.Path)
+	}
+
+	listeners := make([]storetypes.WriteListener, 0, len(cfg.Services))
+
+	for _, service := range cfg.Services {
+		switch service {
+		case config.StreamingServiceDestination:
+			kind, err := cfg.Destination.DestinationKind()
+			if err != nil {
+				return nil, err
+			}
+
+			// each writer of the pipeline connects to the destination on its own
+			pipeline, err := streaming.NewPipeline(cfg.PipelineOptions(), func(int) storetypes.WriteListener {
+				return streaming.NewDestination(cfg.Destination.Path, kind)
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			listeners = append(listeners, pipeline)
+
+		case config.StreamingServiceWebhook:
+			secret, err := cfg.Webhook.Secret.Resolve()
+			if err != nil {
+				return nil, fmt.Errorf("invalid streaming webhook secret %s: %w", cfg.Webhook.Secret, err)
+			}
+
+			endpoints := make([]streaming.WebhookEndpoint, len(cfg.Webhook.URLs))
+			for i, url := range cfg.Webhook.URLs {
+				endpoints[i] = streaming.WebhookEndpoint{URL: url}
+				if secret != "" {
+					endpoints[i].Secret = []byte(secret)
+				}
+			}
+
+			listeners = append(listeners, streaming.NewWebhook(endpoints...))
+
+		case config.StreamingServiceFirehose:
+			if cfg.Firehose.Path == "" {
+				listeners = append(listeners, streaming.NewFirehose(os.Stdout))
+				continue
+			}
+
+			if err := os.MkdirAll(filepath.Dir(cfg.Firehose.Path), 0700); err != nil {
+				return nil, fmt.Errorf("failed to create the streaming firehose directory: %w", err)
+			}
+
+			file, err := os.OpenFile(cfg.Firehose.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open the streaming firehose file: %w", err)
+			}
+
+			listeners = append(listeners, firehoseFile{Firehose: streaming.NewFirehose(file), file: file})
+
+		default:
+			return nil, fmt.Errorf("unknown streaming service %q", service)
+		}
+	}
+
+	for _, listener := range listeners {
+		if es, ok := listener.(streaming.EnvelopeSetter); ok {
+			es.SetEnvelope(envelope)
+		}
+	}
+
+	return listeners, nil
+}
+
+// streamingEnvelope returns the Envelope the streaming services of the node
+// are stamped with: the chain ID of its genesis file, if it can be read, the
+// version of the application and the configured metadata.
+func streamingEnvelope(appOpts types.AppOptions, cfg config.StreamingConfig) streaming.Envelope {
+	envelope := streaming.Envelope{AppVersion: version.Version}
+	if len(cfg.Metadata) > 0 {
+		envelope.Metadata = make(map[string]interface{}, len(cfg.Metadata))
+		for k, v := range cfg.Metadata {
+			envelope.Metadata[k] = v
+		}
+	}
+
+	home := cast.ToString(appOpts.Get(flagHome))
+	genFile := cast.ToString(appOpts.Get("genesis_file"))
+	if genFile == "" {
+		genFile = filepath.Join("config", "genesis.json")
+	}
+	if !filepath.IsAbs(genFile) {
+		if home == "" {
+			return envelope
+		}
+		genFile = filepath.Join(home, genFile)
+	}
+
+	if doc, err := tmtypes.GenesisDocFromFile(genFile); err == nil {
+		envelope.ChainID = doc.ChainID
+	}
+
+	return envelope
+}
+
+// RegisterStreamingServices builds the streaming services enabled by the
+// streaming flags, or their app.toml counterparts, and registers them with the
+// application for the streamed stores among keys. The services are stamped
+// with the chain ID of the genesis file, the application version and the
+// configured metadata. It returns an error if the configuration is invalid or
+// names a store not among keys. Like AddStreamingListeners, it must be called
+// before the application is sealed, i.e. before its latest version is loaded.
+func RegisterStreamingServices(app StreamingListenerRegistrar, appOpts types.AppOptions, keys map[string]*sdk.KVStoreKey) error {
+	cfg, err := GetStreamingConfig(appOpts)
+	if err != nil {
+		return err
+	}
+
+	// a misspelled store would silently not be streamed
+	for _, name := range cfg.Keys {
+		if _, ok := keys[name]; !ok {
+			return fmt.Errorf("unknown streaming store %q", name)
+		}
+	}
+
+	listeners, err := NewStreamingServices(cfg, streamingEnvelope(appOpts, cfg))
+	if err != nil || len(listeners) == 0 {
+		return err
+	}
+
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		if cfg.StreamsStore(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		app.AddStreamingListeners(keys[name], listeners...)
+	}
+
+	return nil
+}
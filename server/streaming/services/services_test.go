@@ -0,0 +1,126 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	tmtypes "github.com/tendermint/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/server/config"
+	"github.com/cosmos/cosmos-sdk/server/streaming"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// streamingRegistrar records the listeners registered for each store.
+type streamingRegistrar map[string][]storetypes.WriteListener
+
+func (r streamingRegistrar) AddStreamingListeners(key sdk.StoreKey, listeners ...storetypes.WriteListener) {
+	r[key.Name()] = append(r[key.Name()], listeners...)
+}
+
+func TestRegisterStreamingServices(t *testing.T) {
+	keys := sdk.NewKVStoreKeys("acc", "bank")
+
+	// no service is enabled by default
+	registrar := streamingRegistrar{}
+	require.NoError(t, RegisterStreamingServices(registrar, viper.New(), keys))
+	require.Empty(t, registrar)
+
+	path := filepath.Join(t.TempDir(), "firehose")
+
+	v := viper.New()
+	v.Set(FlagStreamingServices, []string{config.StreamingServiceFirehose, config.StreamingServiceWebhook})
+	v.Set(FlagStreamingKeys, []string{"bank"})
+	v.Set(FlagStreamingFirehosePath, path)
+	v.Set(FlagStreamingWebhookURLs, []string{"http://127.0.0.1:1/hook"})
+
+	require.NoError(t, RegisterStreamingServices(registrar, v, keys))
+	require.Len(t, registrar, 1)
+	require.Len(t, registrar["bank"], 2)
+
+	// the firehose streams to the configured file
+	firehose, ok := registrar["bank"][0].(firehoseFile)
+	require.True(t, ok)
+	firehose.OnWrite(keys["bank"], []byte("key"), []byte("value"))
+	firehose.OnCommit(1)
+	require.NoError(t, firehose.Err())
+	require.NoError(t, firehose.Close())
+
+	bz, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(bz), "FIRE BLOCK")
+
+	// the destination is pipelined with the configured options
+	v.Set(FlagStreamingServices, []string{config.StreamingServiceDestination})
+	v.Set(FlagStreamingDestinationPath, filepath.Join(t.TempDir(), "destination"))
+	v.Set(FlagStreamingPipelineWriters, 2)
+
+	registrar = streamingRegistrar{}
+	require.NoError(t, RegisterStreamingServices(registrar, v, keys))
+	require.Len(t, registrar["bank"], 1)
+
+	pipeline, ok := registrar["bank"][0].(*streaming.Pipeline)
+	require.True(t, ok)
+	require.NoError(t, pipeline.Close())
+
+	// misconfigurations fail at startup
+	v.Set(FlagStreamingKeys, []string{"bnak"})
+	require.Error(t, RegisterStreamingServices(streamingRegistrar{}, v, keys))
+
+	v.Set(FlagStreamingKeys, []string{"bank"})
+	v.Set(FlagStreamingDestinationKind, "pipe")
+	require.Error(t, RegisterStreamingServices(streamingRegistrar{}, v, keys))
+}
+
+func TestRegisterStreamingServicesNamespace(t *testing.T) {
+	keys := sdk.NewKVStoreKeys("bank")
+	home := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(home, "config"), 0700))
+	require.NoError(t, (&tmtypes.GenesisDoc{ChainID: "testnet-1"}).SaveAs(filepath.Join(home, "config", "genesis.json")))
+
+	v := viper.New()
+	v.Set(flags.FlagHome, home)
+	v.Set(FlagStreamingServices, []string{config.StreamingServiceFirehose})
+	v.Set(FlagStreamingNamespace, true)
+	v.Set(FlagStreamingMetadata, map[string]string{"moniker": "node0"})
+	v.Set(FlagStreamingFirehosePath, filepath.Join(home, "firehose"))
+
+	registrar := streamingRegistrar{}
+	require.NoError(t, RegisterStreamingServices(registrar, v, keys))
+
+	firehose := registrar["bank"][0].(firehoseFile)
+	firehose.OnWrite(keys["bank"], []byte("key"), []byte("value"))
+	firehose.OnCommit(1)
+	require.NoError(t, firehose.Close())
+
+	// the firehose file is moved into a directory named after the chain ID
+	bz, err := ioutil.ReadFile(filepath.Join(home, "testnet-1", "firehose"))
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(string(bz), "\n"), "\n")
+	require.Len(t, lines, 2)
+
+	payload, err := base64.StdEncoding.DecodeString(strings.Fields(lines[1])[3])
+	require.NoError(t, err)
+
+	r := storetypes.NewFrameReader(bytes.NewReader(payload))
+	_, err = r.Next()
+	require.NoError(t, err)
+
+	// and its blocks are stamped with the chain ID, application version and
+	// configured metadata
+	envelope, err := streaming.ReadEnvelope(r)
+	require.NoError(t, err)
+	require.Equal(t, "testnet-1", envelope.ChainID)
+	require.Equal(t, map[string]interface{}{"moniker": "node0"}, envelope.Metadata)
+}
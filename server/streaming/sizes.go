@@ -0,0 +1,167 @@
+package streaming
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+var _ types.WriteListener = (*StateSizes)(nil)
+
+// SizeStats holds the number of keys of a store, or of the keys of a store
+// with a given prefix, and their size in bytes counting both keys and values.
+// Prefix is base64 encoded in JSON and omitted for the totals of a store.
+type SizeStats struct {
+	StoreKey string `json:"store_key"`
+	Prefix   []byte `json:"prefix,omitempty"`
+	Keys     uint64 `json:"keys"`
+	Bytes    uint64 `json:"bytes"`
+}
+
+// StateSizesResponse is the JSON response of the StateSizes query endpoint.
+type StateSizesResponse struct {
+	Stores   []SizeStats `json:"stores"`
+	Prefixes []SizeStats `json:"prefixes"`
+}
+
+// StateSizes is a WriteListener which maintains running totals of the number
+// of keys and bytes of the listened stores, by store and key prefix, so the
+// stores growing the state can be found without analyzing the IAVL trees
+// offline.
+//
+// As a write doesn't carry the size of the value it overwrites, StateSizes
+// keeps the size of every key it has seen, which takes memory proportional to
+// the number of keys. The keys already in the state when listening starts
+// must be loaded with Load, otherwise their overwrites and deletes are
+// accounted as creations.
+type StateSizes struct {
+	mtx       sync.Mutex
+	prefixLen int
+	sizes     map[string]uint64
+	prefixes  map[string]*SizeStats
+}
+
+// NewStateSizes returns a new StateSizes accounting by key prefixes of
+// prefixLen bytes.
+func NewStateSizes(prefixLen int) *StateSizes {
+	if prefixLen <= 0 {
+		prefixLen = DefaultHeatmapPrefixLength
+	}
+
+	return &StateSizes{
+		prefixLen: prefixLen,
+		sizes:     make(map[string]uint64),
+		prefixes:  make(map[string]*SizeStats),
+	}
+}
+
+// Load accounts every key of the given store, which must be the committed
+// state of the store mounted under storeKey. It is meant to be called once
+// per store before the first write is streamed.
+func (s *StateSizes) Load(storeKey types.StoreKey, store types.KVStore) {
+	it := store.Iterator(nil, nil)
+	defer it.Close()
+
+	for ; it.Valid(); it.Next() {
+		s.OnWrite(storeKey, it.Key(), it.Value())
+	}
+}
+
+// OnWrite implements the WriteListener interface.
+func (s *StateSizes) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	prefix := key
+	if len(prefix) > s.prefixLen {
+		prefix = prefix[:s.prefixLen]
+	}
+
+	stats, ok := s.prefixes[accessID(storeKey.Name(), prefix)]
+	if !ok {
+		stats = &SizeStats{StoreKey: storeKey.Name(), Prefix: append([]byte(nil), prefix...)}
+		s.prefixes[accessID(storeKey.Name(), prefix)] = stats
+	}
+
+	id := accessID(storeKey.Name(), key)
+	if size, ok := s.sizes[id]; ok {
+		stats.Keys--
+		stats.Bytes -= size
+		delete(s.sizes, id)
+	}
+
+	if value != nil {
+		size := uint64(len(key) + len(value))
+		stats.Keys++
+		stats.Bytes += size
+		s.sizes[id] = size
+	}
+
+	if stats.Keys == 0 {
+		delete(s.prefixes, accessID(storeKey.Name(), prefix))
+	}
+}
+
+// Stores returns the totals of every store holding keys, largest first.
+func (s *StateSizes) Stores() []SizeStats {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	stores := make(map[string]*SizeStats)
+	for _, stats := range s.prefixes {
+		total, ok := stores[stats.StoreKey]
+		if !ok {
+			total = &SizeStats{StoreKey: stats.StoreKey}
+			stores[stats.StoreKey] = total
+		}
+
+		total.Keys += stats.Keys
+		total.Bytes += stats.Bytes
+	}
+
+	return sortedSizes(stores)
+}
+
+// Prefixes returns the totals of every key prefix holding keys, largest
+// first.
+func (s *StateSizes) Prefixes() []SizeStats {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return sortedSizes(s.prefixes)
+}
+
+// ServeHTTP implements the http.Handler interface. It responds with a
+// StateSizesResponse holding the totals of every store and prefix.
+func (s *StateSizes) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(StateSizesResponse{
+		Stores:   s.Stores(),
+		Prefixes: s.Prefixes(),
+	})
+}
+
+// sortedSizes returns copies of the stats, largest first and then by store
+// and prefix.
+func sortedSizes(stats map[string]*SizeStats) []SizeStats {
+	sorted := make([]SizeStats, 0, len(stats))
+	for _, s := range stats {
+		sorted = append(sorted, *s)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Bytes != sorted[j].Bytes {
+			return sorted[i].Bytes > sorted[j].Bytes
+		}
+		if sorted[i].StoreKey != sorted[j].StoreKey {
+			return sorted[i].StoreKey < sorted[j].StoreKey
+		}
+		return bytes.Compare(sorted[i].Prefix, sorted[j].Prefix) < 0
+	})
+
+	return sorted
+}
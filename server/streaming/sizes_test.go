@@ -0,0 +1,48 @@
+package streaming
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+)
+
+func TestStateSizes(t *testing.T) {
+	store := dbadapter.Store{DB: dbm.NewMemDB()}
+	store.Set([]byte("a1"), []byte("value"))
+	store.Set([]byte("b1"), []byte("v"))
+
+	s := NewStateSizes(1)
+	s.Load(accKey, store)
+
+	s.OnWrite(accKey, []byte("a1"), []byte("longer value"))
+	s.OnWrite(accKey, []byte("a2"), []byte("value"))
+	s.OnWrite(accKey, []byte("b1"), nil)
+	s.OnWrite(accKey, []byte("c1"), nil)
+	s.OnWrite(bankKey, []byte("a1"), []byte("v"))
+
+	require.Equal(t, []SizeStats{
+		{StoreKey: "acc", Prefix: []byte("a"), Keys: 2, Bytes: 21},
+		{StoreKey: "bank", Prefix: []byte("a"), Keys: 1, Bytes: 3},
+	}, s.Prefixes())
+	require.Equal(t, []SizeStats{
+		{StoreKey: "acc", Keys: 2, Bytes: 21},
+		{StoreKey: "bank", Keys: 1, Bytes: 3},
+	}, s.Stores())
+
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	var sizes StateSizesResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&sizes))
+	require.Equal(t, StateSizesResponse{Stores: s.Stores(), Prefixes: s.Prefixes()}, sizes)
+}
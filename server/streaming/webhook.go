@@ -0,0 +1,270 @@
+package streaming
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+const (
+	// WebhookSignatureHeader is the header holding the hex encoded
+	// HMAC-SHA256 signature of the body of a webhook request, prefixed with
+	// "sha256=".
+	WebhookSignatureHeader = "X-Cosmos-Signature-256"
+
+	// DefaultWebhookRetries is the default number of times a failed webhook
+	// request is retried.
+	DefaultWebhookRetries = 3
+	// DefaultWebhookBackoff is the default delay before the first retry of a
+	// failed webhook request, doubled on every retry.
+	DefaultWebhookBackoff = time.Second
+	// DefaultWebhookTimeout is the default timeout of a webhook request.
+	DefaultWebhookTimeout = 10 * time.Second
+)
+
+var (
+	_ types.WriteListener    = (*Webhook)(nil)
+	_ baseapp.CommitListener = (*Webhook)(nil)
+)
+
+// WebhookEndpoint is an HTTP endpoint the writes of every block are POSTed
+// to. If Secret is set, requests are signed with it in the
+// WebhookSignatureHeader. Only the writes to the stores named in Stores, all
+// if empty, and to the keys with one of KeyPrefixes, all if empty, are sent.
+type WebhookEndpoint struct {
+	URL         string
+	Secret      []byte
+	Stores      []string
+	KeyPrefixes [][]byte
+}
+
+// matches returns true if the write of the event is sent to the endpoint.
+func (e WebhookEndpoint) matches(event Event) bool {
+	if len(e.Stores) > 0 {
+		var found bool
+		for _, name := range e.Stores {
+			found = found || name == event.StoreKey
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(e.KeyPrefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range e.KeyPrefixes {
+		if bytes.HasPrefix(event.Key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WebhookPayload is the JSON body of a webhook request, holding the writes of
+// a committed block.
+type WebhookPayload struct {
+	Height int64   `json:"height"`
+	Events []Event `json:"events"`
+}
+
+// Webhook is a WriteListener and CommitListener which POSTs the writes of
+// every committed block, as a WebhookPayload, to each of its endpoints. Blocks
+// without writes matching an endpoint are not sent to it.
+//
+// Failed requests are retried with exponential backoff, blocking the
+// dispatch of the writes of the following blocks, so the BaseApp should
+// stream in async mode. Once the retries of a request are exhausted its block
+// is dropped for the endpoint and the error is returned from Err.
+type Webhook struct {
+	mtx         sync.Mutex
+	endpoints   []WebhookEndpoint
+	client      *http.Client
+	retries     int
+	backoff     time.Duration
+	pending     []Event
+	envelope    Envelope
+	jsonOptions JSONOptions
+	err         error
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewWebhook returns a new Webhook sending the writes to the given endpoints.
+func NewWebhook(endpoints ...WebhookEndpoint) *Webhook {
+	return &Webhook{
+		endpoints: endpoints,
+		client:    &http.Client{Timeout: DefaultWebhookTimeout},
+		retries:   DefaultWebhookRetries,
+		backoff:   DefaultWebhookBackoff,
+		done:      make(chan struct{}),
+	}
+}
+
+// SetRetries sets the number of times a failed request is retried and the
+// delay before the first retry.
+func (w *Webhook) SetRetries(retries int, backoff time.Duration) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.retries = retries
+	w.backoff = backoff
+}
+
+// SetHTTPClient sets the client the requests are made with.
+func (w *Webhook) SetHTTPClient(client *http.Client) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.client = client
+}
+
+// SetEnvelope sets the Envelope every Event sent is stamped with. It
+// implements the EnvelopeSetter interface.
+func (w *Webhook) SetEnvelope(envelope Envelope) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.envelope = envelope.copy()
+}
+
+// SetJSONOptions sets the JSONOptions the payloads are encoded with.
+func (w *Webhook) SetJSONOptions(options JSONOptions) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.jsonOptions = options
+}
+
+// OnWrite implements the WriteListener interface. The write is sent once its
+// block is committed.
+func (w *Webhook) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	event := writeEvent(storeKey, key, value)
+	w.envelope.stamp(&event)
+
+	w.pending = append(w.pending, event)
+}
+
+// OnCommit implements the baseapp CommitListener interface. It sends the
+// writes of the block to every endpoint.
+func (w *Webhook) OnCommit(height int64) {
+	w.mtx.Lock()
+	events := w.pending
+	w.pending = nil
+	w.mtx.Unlock()
+
+	for _, endpoint := range w.endpoints {
+		payload := WebhookPayload{Height: height}
+		for _, event := range events {
+			if endpoint.matches(event) {
+				payload.Events = append(payload.Events, event)
+			}
+		}
+
+		if len(payload.Events) == 0 {
+			continue
+		}
+
+		if err := w.send(endpoint, payload); err != nil {
+			w.mtx.Lock()
+			w.err = fmt.Errorf("failed to send block %d to %s: %w", height, RedactURL(endpoint.URL), err)
+			w.mtx.Unlock()
+		}
+	}
+}
+
+// send POSTs the payload to the endpoint, retrying failed requests.
+func (w *Webhook) send(endpoint WebhookEndpoint, payload WebhookPayload) error {
+	w.mtx.Lock()
+	client, retries, backoff, options := w.client, w.retries, w.backoff, w.jsonOptions
+	w.mtx.Unlock()
+
+	body, err := options.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(client, endpoint, body)
+		if err == nil {
+			return nil
+		}
+
+		if !retry || attempt >= retries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff << uint(attempt)):
+		case <-w.done:
+			return err
+		}
+	}
+}
+
+// post makes a single request, returning whether it may be retried if it
+// failed.
+func (w *Webhook) post(client *http.Client, endpoint WebhookEndpoint, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, redactURLError(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if len(endpoint.Secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(endpoint.Secret, body))
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return true, redactURLError(err)
+	}
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return false, nil
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return true, errors.New(res.Status)
+	default:
+		return false, errors.New(res.Status)
+	}
+}
+
+// Err returns the last error encountered sending a block.
+func (w *Webhook) Err() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	return w.err
+}
+
+// Close implements the io.Closer interface. It aborts the pending retries.
+func (w *Webhook) Close() error {
+	w.closeOnce.Do(func() { close(w.done) })
+	return nil
+}
+
+// SignWebhook returns the value of the WebhookSignatureHeader of a request
+// with the given body, so receivers can verify it with hmac.Equal.
+func SignWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
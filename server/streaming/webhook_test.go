@@ -0,0 +1,104 @@
+package streaming
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		payloads []WebhookPayload
+		failures = 1
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, SignWebhook([]byte("secret"), body), r.Header.Get(WebhookSignatureHeader))
+
+		var payload WebhookPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		payloads = append(payloads, payload)
+	}))
+	defer srv.Close()
+
+	w := NewWebhook(WebhookEndpoint{
+		URL:         srv.URL,
+		Secret:      []byte("secret"),
+		Stores:      []string{"acc"},
+		KeyPrefixes: [][]byte{[]byte("a")},
+	})
+	w.SetRetries(1, 0)
+
+	w.OnWrite(accKey, []byte("a1"), []byte("value"))
+	w.OnWrite(accKey, []byte("b1"), []byte("value"))
+	w.OnWrite(bankKey, []byte("a1"), []byte("value"))
+	w.OnWrite(accKey, []byte("a2"), nil)
+	w.OnCommit(1)
+
+	// no writes match the endpoint
+	w.OnWrite(bankKey, []byte("a1"), []byte("value"))
+	w.OnCommit(2)
+
+	require.NoError(t, w.Err())
+	require.Equal(t, []WebhookPayload{{
+		Height: 1,
+		Events: []Event{
+			{StoreKey: "acc", Key: []byte("a1"), Value: []byte("value")},
+			{StoreKey: "acc", Key: []byte("a2"), Delete: true},
+		},
+	}}, payloads)
+}
+
+func TestWebhookErrors(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		requests int
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		requests++
+		if r.URL.Path == "/invalid" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	w := NewWebhook(WebhookEndpoint{URL: srv.URL + "/invalid"})
+	w.SetRetries(2, 0)
+	w.OnWrite(accKey, []byte("key"), []byte("value"))
+	w.OnCommit(1)
+
+	require.Error(t, w.Err())
+	require.Equal(t, 1, requests, "client errors should not be retried")
+
+	w = NewWebhook(WebhookEndpoint{URL: srv.URL})
+	w.SetRetries(2, 0)
+	w.OnWrite(accKey, []byte("key"), []byte("value"))
+	w.OnCommit(1)
+
+	require.Error(t, w.Err())
+	require.Equal(t, 4, requests, "server errors should be retried")
+	require.NoError(t, w.Close())
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cosmos/cosmos-sdk/server/config"
+	"github.com/cosmos/cosmos-sdk/server/streaming"
+	"github.com/cosmos/cosmos-sdk/server/types"
+	"github.com/cosmos/cosmos-sdk/types/rest"
+)
+
+// streamingAdminHandler returns the handler of the streaming admin endpoints
+// the application supports, requiring the given bearer token if it isn't
+// empty. It returns false if the application supports none of them.
+func streamingAdminHandler(app types.Application, token string) (http.Handler, bool) {
+	router := mux.NewRouter()
+	served := false
+
+	if sa, ok := app.(streamingAcknowledger); ok {
+		router.HandleFunc("/streaming/ack", streamingAckHandler(sa)).Methods("POST")
+		served = true
+	}
+
+	if sp, ok := app.(streamingPauser); ok {
+		router.HandleFunc("/streaming/pause", streamingPauseHandler(sp.PauseStreaming)).Methods("POST")
+		router.HandleFunc("/streaming/resume", streamingPauseHandler(sp.ResumeStreaming)).Methods("POST")
+		served = true
+	}
+
+	if !served || token == "" {
+		return router, served
+	}
+
+	authorizer := streaming.TokenAuthorizer{token: {streaming.AllStores}}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := authorizer.Authorize(r); err != nil {
+			rest.WriteErrorResponse(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		router.ServeHTTP(w, r)
+	}), true
+}
+
+// startStreamingAdmin serves the streaming admin endpoints of the application
+// on the configured admin address, apart from the public API server. It
+// returns a nil server if the admin endpoints are disabled or the application
+// supports none of them.
+func startStreamingAdmin(ctx *Context, app types.Application, cfg config.StreamingAdminConfig) (*http.Server, error) {
+	if cfg.Address == "" {
+		return nil, nil
+	}
+
+	token, err := cfg.Token.Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	handler, ok := streamingAdminHandler(app, token)
+	if !ok {
+		return nil, nil
+	}
+
+	listener, err := net.Listen("tcp", cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &http.Server{Handler: handler}
+
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			ctx.Logger.Error("streaming admin server failed", "err", err)
+		}
+	}()
+
+	ctx.Logger.Info("serving streaming admin endpoints", "address", cfg.Address)
+
+	return srv, nil
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/server/streaming"
+	"github.com/cosmos/cosmos-sdk/server/types"
+)
+
+type pausableApp struct {
+	types.Application
+	*streaming.PauseControl
+}
+
+func TestStreamingAdminHandler(t *testing.T) {
+	_, ok := streamingAdminHandler(struct{ types.Application }{}, "")
+	require.False(t, ok, "applications without admin endpoints are not served")
+
+	p, err := streaming.NewPausable(streaming.NewFirehose(ioutil.Discard), filepath.Join(t.TempDir(), "spill"))
+	require.NoError(t, err)
+
+	app := pausableApp{PauseControl: streaming.NewPauseControl()}
+	app.Register("db", p)
+
+	handler, ok := streamingAdminHandler(app, "admin")
+	require.True(t, ok)
+
+	pause := func(token string) int {
+		req := httptest.NewRequest("POST", "/streaming/pause?destination=db", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, pause(""))
+	require.Equal(t, http.StatusUnauthorized, pause("guess"))
+	require.False(t, p.Paused())
+
+	require.Equal(t, http.StatusNoContent, pause("admin"))
+	require.True(t, p.Paused())
+}
+
+type acknowledgingApp struct {
+	types.Application
+	acked int64
+}
+
+func (app *acknowledgingApp) AcknowledgeStreamedHeight(height int64) {
+	app.acked = height
+}
+
+func TestStreamingAdminAck(t *testing.T) {
+	app := &acknowledgingApp{}
+	handler, ok := streamingAdminHandler(app, "admin")
+	require.True(t, ok)
+
+	ack := func(query string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/streaming/ack?"+query, nil))
+
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, ack("height=1000000"))
+	require.Zero(t, app.acked)
+
+	require.Equal(t, http.StatusBadRequest, ack("token=admin&height=abc"))
+	require.Equal(t, http.StatusNoContent, ack("token=admin&height=5"))
+	require.Equal(t, int64(5), app.acked)
+}
@@ -0,0 +1,772 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/cli"
+
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/server/streaming"
+	"github.com/cosmos/cosmos-sdk/server/types"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+)
+
+const (
+	flagFollow       = "follow"
+	flagPollInterval = "poll-interval"
+	flagFromHeight   = "from"
+	flagToHeight     = "to"
+	flagPrefixLength = "prefix-length"
+	flagIndex        = "index"
+	flagStallTimeout = "stall-timeout"
+	flagAppHash      = "app-hash"
+	flagFormat       = "format"
+	flagOutput       = "output"
+	flagDestination  = "destination"
+)
+
+// stateStreamer is implemented by applications which can stream their full
+// state through their streaming listeners (e.g. BaseApp).
+type stateStreamer interface {
+	StreamState(height int64) error
+}
+
+// stateBackfiller is implemented by applications which can stream the state
+// changes of past blocks through their streaming listeners (e.g. BaseApp).
+type stateBackfiller interface {
+	Backfill(fromHeight, toHeight int64) error
+}
+
+// sinkBackfiller is implemented by applications which can pass the state
+// changes of past blocks to a WriteListener of the caller (e.g. BaseApp).
+type sinkBackfiller interface {
+	BackfillTo(fromHeight, toHeight int64, listener storetypes.WriteListener) error
+}
+
+// stateReplayer is implemented by applications which can commit the writes
+// of a block without executing it (e.g. BaseApp).
+type stateReplayer interface {
+	ReplayBlock(height int64, writes []storetypes.FrameRecord) ([]byte, error)
+}
+
+// stateExporter is implemented by applications which can pass their full
+// state at a height to a WriteListener (e.g. BaseApp).
+type stateExporter interface {
+	ExportState(height int64, listener storetypes.WriteListener) error
+}
+
+// StreamingCmd returns the command grouping the state streaming tools.
+func StreamingCmd(appCreator types.AppCreator, defaultNodeHome string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "streaming",
+		Short: "State streaming subcommands",
+	}
+
+	cmd.AddCommand(
+		TailCmd(),
+		CompareCmd(),
+		RelayCmd(),
+		IndexArchiveCmd(),
+		QueryArchiveCmd(),
+		ExportGenesisStateCmd(appCreator, defaultNodeHome),
+		BackfillCmd(appCreator, defaultNodeHome),
+		ReplayCmd(appCreator, defaultNodeHome),
+		ExportTablesCmd(appCreator, defaultNodeHome),
+	)
+
+	return cmd
+}
+
+// ExportGenesisStateCmd returns a command streaming the full state at a given
+// height through the streaming listeners configured by the app.
+func ExportGenesisStateCmd(appCreator types.AppCreator, defaultNodeHome string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export-genesis-state",
+		Short: "Stream the full state through the app's streaming listeners",
+		Long: `Iterate the state of the streamed stores at the given height and pass every key-value
+pair through the streaming listeners configured by the app, producing a full-state baseline
+in the same format as the live stream.
+
+With --output or --destination, the state of every persistent store is streamed to the given
+file, as frames readable by the tail command, or to the given unix domain socket or named pipe
+instead.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			serverCtx := GetServerContextFromCmd(cmd)
+			config := serverCtx.Config
+
+			homeDir, _ := cmd.Flags().GetString(flags.FlagHome)
+			config.SetRoot(homeDir)
+
+			db, err := openDB(config.RootDir)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			app := appCreator(serverCtx.Logger, db, nil, serverCtx.Viper)
+
+			streamer, ok := app.(stateStreamer)
+			if !ok {
+				return fmt.Errorf("app does not support state streaming")
+			}
+
+			height, _ := cmd.Flags().GetInt64(FlagHeight)
+
+			sink, closeSink, err := sinkFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			if sink != nil {
+				exporter, ok := app.(stateExporter)
+				if !ok {
+					_ = closeSink()
+					return fmt.Errorf("app does not support state exports")
+				}
+
+				err := exporter.ExportState(height, sink)
+				if closeErr := closeSink(); err == nil {
+					err = closeErr
+				}
+
+				return err
+			}
+
+			if err := streamer.StreamState(height); err != nil {
+				return err
+			}
+
+			if sc, ok := app.(streamingCloser); ok {
+				timeout, _ := cmd.Flags().GetDuration(FlagStreamingShutdownTimeout)
+				return sc.CloseStreamingListeners(timeout)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String(flags.FlagHome, defaultNodeHome, "The application home directory")
+	cmd.Flags().Int64(FlagHeight, -1, "Stream state from a particular height (-1 means latest height)")
+	cmd.Flags().Duration(FlagStreamingShutdownTimeout, 10*time.Second, "Maximum time to wait for state streaming listeners to flush (0 waits indefinitely)")
+	addSinkFlags(cmd)
+
+	return cmd
+}
+
+// BackfillCmd returns a command streaming the state changes of a past height
+// range through the streaming listeners configured by the app.
+func BackfillCmd(appCreator types.AppCreator, defaultNodeHome string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backfill",
+		Short: "Stream the state changes of a past height range through the app's streaming listeners",
+		Long: `Re-derive the state changes committed in every block of the given height range by diffing
+the state at each height against the previous one, and pass them through the streaming listeners
+configured by the app. Every version in the range, and the one before it, must not have been pruned.
+
+With --output or --destination, the state changes of every persistent store are streamed to the
+given file, as frames readable by the tail command, or to the given unix domain socket or named
+pipe instead.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			serverCtx := GetServerContextFromCmd(cmd)
+			config := serverCtx.Config
+
+			homeDir, _ := cmd.Flags().GetString(flags.FlagHome)
+			config.SetRoot(homeDir)
+
+			db, err := openDB(config.RootDir)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			app := appCreator(serverCtx.Logger, db, nil, serverCtx.Viper)
+
+			backfiller, ok := app.(stateBackfiller)
+			if !ok {
+				return fmt.Errorf("app does not support state streaming backfill")
+			}
+
+			fromHeight, _ := cmd.Flags().GetInt64(flagFromHeight)
+			toHeight, _ := cmd.Flags().GetInt64(flagToHeight)
+
+			sink, closeSink, err := sinkFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			if sink != nil {
+				sb, ok := app.(sinkBackfiller)
+				if !ok {
+					_ = closeSink()
+					return fmt.Errorf("app does not support state streaming backfill to a sink")
+				}
+
+				err := sb.BackfillTo(fromHeight, toHeight, sink)
+				if closeErr := closeSink(); err == nil {
+					err = closeErr
+				}
+
+				return err
+			}
+
+			if err := backfiller.Backfill(fromHeight, toHeight); err != nil {
+				return err
+			}
+
+			if sc, ok := app.(streamingCloser); ok {
+				timeout, _ := cmd.Flags().GetDuration(FlagStreamingShutdownTimeout)
+				return sc.CloseStreamingListeners(timeout)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String(flags.FlagHome, defaultNodeHome, "The application home directory")
+	cmd.Flags().Int64(flagFromHeight, 1, "First height of the range to stream")
+	cmd.Flags().Int64(flagToHeight, 0, "Last height of the range to stream")
+	cmd.Flags().Duration(FlagStreamingShutdownTimeout, 10*time.Second, "Maximum time to wait for state streaming listeners to flush (0 waits indefinitely)")
+	addSinkFlags(cmd)
+	cmd.MarkFlagRequired(flagToHeight)
+
+	return cmd
+}
+
+// addSinkFlags adds the flags of the sink built by sinkFromFlags.
+func addSinkFlags(cmd *cobra.Command) {
+	cmd.Flags().String(flagOutput, "", "File the state is streamed to as frames instead of through the app's streaming listeners (- for the standard output)")
+	cmd.Flags().String(flagDestination, "", "Unix domain socket or named pipe the state is streamed to instead of through the app's streaming listeners")
+}
+
+// sinkFromFlags returns the sink built from the --output and --destination
+// flags, which commands stream to instead of the streaming listeners of the
+// app, and the function flushing and closing it. It returns a nil sink if
+// neither flag is set.
+func sinkFromFlags(cmd *cobra.Command) (storetypes.WriteListener, func() error, error) {
+	output, _ := cmd.Flags().GetString(flagOutput)
+	destination, _ := cmd.Flags().GetString(flagDestination)
+
+	switch {
+	case output != "" && destination != "":
+		return nil, nil, fmt.Errorf("--%s and --%s are mutually exclusive", flagOutput, flagDestination)
+
+	case destination != "":
+		d := streaming.NewDestination(destination, streaming.DestinationAuto)
+		return d, d.Close, nil
+
+	case output == "-":
+		l := storetypes.NewFrameWriteListener(cmd.OutOrStdout())
+		return l, l.Err, nil
+
+	case output != "":
+		f, err := os.Create(output)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		l := storetypes.NewFrameWriteListener(f)
+		return l, func() error {
+			err := l.Err()
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+
+			return err
+		}, nil
+
+	default:
+		return nil, nil, nil
+	}
+}
+
+// ReplayCmd returns a command rebuilding the state of the app from a Firehose
+// archive.
+func ReplayCmd(appCreator types.AppCreator, defaultNodeHome string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay [archive]",
+		Short: "Rebuild the application state from a Firehose archive",
+		Long: `Commit the writes of every block of a Firehose archive, the output of a Firehose written to a
+file, into the application database of the home directory, without executing the blocks. Replaying
+an archive streamed since genesis into a new home rebuilds the state and history of the node, like
+a state sync from the archive. Blocks at or below the latest height of the database are skipped, so
+an interrupted replay can be resumed.
+
+The resulting app hash only matches the one of the chain if every persistent store was streamed.
+With --app-hash, the app hash of the last replayed block is verified against the expected one.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serverCtx := GetServerContextFromCmd(cmd)
+			config := serverCtx.Config
+
+			homeDir, _ := cmd.Flags().GetString(flags.FlagHome)
+			config.SetRoot(homeDir)
+
+			var expectedHash []byte
+			if appHash, _ := cmd.Flags().GetString(flagAppHash); appHash != "" {
+				var err error
+				if expectedHash, err = hex.DecodeString(appHash); err != nil {
+					return fmt.Errorf("invalid app hash: %w", err)
+				}
+			}
+
+			archive, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer archive.Close()
+
+			db, err := openDB(config.RootDir)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			app := appCreator(serverCtx.Logger, db, nil, serverCtx.Viper)
+
+			replayer, ok := app.(stateReplayer)
+			if !ok {
+				return fmt.Errorf("app does not support state replays")
+			}
+
+			toHeight, _ := cmd.Flags().GetInt64(flagToHeight)
+			lastHeight := app.Info(abci.RequestInfo{}).LastBlockHeight
+
+			var (
+				replayed int
+				appHash  []byte
+				r        = streaming.NewFirehoseReader(archive)
+			)
+			for {
+				block, err := r.Next()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					return err
+				}
+
+				if toHeight > 0 && block.Height > toHeight {
+					break
+				} else if block.Height <= lastHeight {
+					continue
+				}
+
+				records, err := block.Records()
+				if err != nil {
+					return fmt.Errorf("invalid block %d: %w", block.Height, err)
+				}
+
+				if appHash, err = replayer.ReplayBlock(block.Height, records); err != nil {
+					return err
+				}
+				lastHeight = block.Height
+				replayed++
+			}
+
+			if expectedHash != nil && !bytes.Equal(appHash, expectedHash) {
+				return fmt.Errorf("app hash %X at height %d differs from the expected %X", appHash, lastHeight, expectedHash)
+			}
+
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "%d blocks replayed up to height %d, app hash %X\n", replayed, lastHeight, appHash)
+			return err
+		},
+	}
+
+	cmd.Flags().String(flags.FlagHome, defaultNodeHome, "The application home directory")
+	cmd.Flags().Int64(flagToHeight, 0, "Last height to replay (0 replays the whole archive)")
+	cmd.Flags().String(flagAppHash, "", "Expected app hash of the last replayed block, hex encoded")
+
+	return cmd
+}
+
+// ExportTablesCmd returns a command dumping the state at a given height to a
+// file per store.
+func ExportTablesCmd(appCreator types.AppCreator, defaultNodeHome string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Dump the state at a height to a table per store",
+		Long: `Iterate the state of every persistent store at the given height and write its key-value pairs
+to a table named after the store in the output directory, e.g. for an audit, an airdrop or an
+analysis. The tables hold the raw pairs, hex encoded in CSV files or base64 encoded in JSON lines.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			serverCtx := GetServerContextFromCmd(cmd)
+			config := serverCtx.Config
+
+			homeDir, _ := cmd.Flags().GetString(flags.FlagHome)
+			config.SetRoot(homeDir)
+
+			format, _ := cmd.Flags().GetString(flagFormat)
+			output, _ := cmd.Flags().GetString(flagOutput)
+			exporter, err := streaming.NewTableExporter(output, format)
+			if err != nil {
+				return err
+			}
+
+			db, err := openDB(config.RootDir)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			app := appCreator(serverCtx.Logger, db, nil, serverCtx.Viper)
+
+			stateExporter, ok := app.(stateExporter)
+			if !ok {
+				return fmt.Errorf("app does not support state exports")
+			}
+
+			height, _ := cmd.Flags().GetInt64(FlagHeight)
+			err = stateExporter.ExportState(height, exporter)
+			if closeErr := exporter.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+
+			rows := exporter.Rows()
+			names := make([]string, 0, len(rows))
+			for name := range rows {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				if _, err := fmt.Fprintf(cmd.OutOrStdout(), "%s\t%d rows\n", name, rows[name]); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String(flags.FlagHome, defaultNodeHome, "The application home directory")
+	cmd.Flags().Int64(FlagHeight, -1, "Export the state at a particular height (-1 means latest height)")
+	cmd.Flags().String(flagFormat, streaming.ExportFormatCSV, "Format of the tables (csv|json)")
+	cmd.Flags().String(flagOutput, "", "Directory the tables are written to")
+	cmd.MarkFlagRequired(flagOutput)
+
+	return cmd
+}
+
+// TailCmd returns a command printing the writes of a stream file written by a
+// FrameWriteListener, optionally following it as it grows.
+func TailCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tail [file]",
+		Short: "Print the state changes of a stream file",
+		Long: `Decode and print the state changes of a stream file written by a FrameWriteListener.
+With --follow, keep waiting for state changes appended to the file until interrupted.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			follow, _ := cmd.Flags().GetBool(flagFollow)
+			pollInterval, _ := cmd.Flags().GetDuration(flagPollInterval)
+			output, _ := cmd.Flags().GetString(cli.OutputFlag)
+
+			var r io.Reader = f
+			if follow {
+				r = &followReader{reader: f, pollInterval: pollInterval, done: ctx.Done()}
+			}
+
+			return printFrames(cmd.OutOrStdout(), storetypes.NewFrameReader(r), strings.ToLower(output) == "json")
+		},
+	}
+
+	cmd.Flags().BoolP(flagFollow, "f", false, "Wait for state changes appended to the file")
+	cmd.Flags().Duration(flagPollInterval, time.Second, "How often to check the file for appended state changes when following it")
+	cmd.Flags().StringP(cli.OutputFlag, "o", "text", "Output format (text|json)")
+
+	return cmd
+}
+
+// CompareCmd returns a command comparing the Firehose streams of two nodes
+// block by block.
+func CompareCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "compare [stream-a] [stream-b]",
+		Short: "Compare the Firehose streams of two nodes block by block",
+		Long: `Compare the writes of every block of two Firehose streams of the same chain, e.g. the outputs
+of two independent nodes, and report the first write that differs. Nodes streaming different writes
+for the same block surface nondeterminism in the modules. The comparison stops at the end of the
+shorter stream.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer a.Close()
+
+			b, err := os.Open(args[1])
+			if err != nil {
+				return err
+			}
+			defer b.Close()
+
+			compared, mismatch, err := streaming.CompareFirehose(a, b)
+			if err != nil {
+				return err
+			}
+
+			if mismatch != nil {
+				return fmt.Errorf("streams differ after %d identical blocks: %s", compared, mismatch)
+			}
+
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "%d blocks identical\n", compared)
+			return err
+		},
+	}
+}
+
+// RelayCmd returns a command merging the Firehose streams of redundant nodes
+// into a single gap-free stream.
+func RelayCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "relay [stream]...",
+		Short: "Merge the Firehose streams of redundant nodes into a single stream",
+		Long: `Merge the Firehose streams of several redundant nodes of the same chain, e.g. named pipes fed by
+their outputs, into a single stream printed to the standard output, relaying every block once. Blocks
+are relayed from the first stream until it stalls for --stall-timeout while another stream provides
+the next block, or until it ends, at which point the relay fails over to another stream. With
+--follow, the streams are files which keep being appended to until interrupted.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			follow, _ := cmd.Flags().GetBool(flagFollow)
+			pollInterval, _ := cmd.Flags().GetDuration(flagPollInterval)
+			stallTimeout, _ := cmd.Flags().GetDuration(flagStallTimeout)
+
+			sources := make([]io.Reader, len(args))
+			for i, path := range args {
+				f, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				sources[i] = f
+				if follow {
+					sources[i] = &followReader{reader: f, pollInterval: pollInterval, done: ctx.Done()}
+				}
+			}
+
+			stats, err := streaming.RelayFirehose(ctx, cmd.OutOrStdout(), stallTimeout, sources...)
+			for i, sourceErr := range stats.SourceErrors {
+				if sourceErr != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "stream %s failed: %s\n", args[i], sourceErr)
+				}
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "%d blocks relayed, %d failovers\n", stats.Blocks, stats.Failovers)
+
+			return err
+		},
+	}
+
+	cmd.Flags().BoolP(flagFollow, "f", false, "Wait for blocks appended to the streams")
+	cmd.Flags().Duration(flagPollInterval, time.Second, "How often to check the streams for appended blocks when following them")
+	cmd.Flags().Duration(flagStallTimeout, 5*time.Second, "How long to wait for the active stream once another one provides the next block")
+
+	return cmd
+}
+
+// IndexArchiveCmd returns a command writing the index of a Firehose archive.
+func IndexArchiveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "index [archive]",
+		Short: "Index the blocks of a Firehose archive",
+		Long: `Write the index of a Firehose archive, the output of a Firehose written to a file, next to it.
+The index records the offset of every block along with a bloom filter of the key prefixes it writes
+to, so the query command can read the writes to a prefix in a height range without scanning the
+whole archive. The index must be rebuilt once the archive grows.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			archive, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer archive.Close()
+
+			indexPath, _ := cmd.Flags().GetString(flagIndex)
+			if indexPath == "" {
+				indexPath = args[0] + ".index"
+			}
+
+			index, err := os.Create(indexPath)
+			if err != nil {
+				return err
+			}
+
+			prefixLength, _ := cmd.Flags().GetInt(flagPrefixLength)
+			if err := streaming.BuildArchiveIndex(archive, index, prefixLength); err != nil {
+				index.Close()
+				return err
+			}
+
+			return index.Close()
+		},
+	}
+
+	cmd.Flags().String(flagIndex, "", "Path of the index (defaults to the archive path with an .index suffix)")
+	cmd.Flags().Int(flagPrefixLength, streaming.DefaultArchivePrefixLength, "Maximum length in bytes of the key prefixes recorded in the index")
+
+	return cmd
+}
+
+// QueryArchiveCmd returns a command printing the writes to a key prefix of a
+// store in a height range of an indexed Firehose archive.
+func QueryArchiveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "query [archive] [store] [hex-prefix]",
+		Short: "Print the writes to a key prefix in a height range of an indexed Firehose archive",
+		Long: `Print the writes to the keys of the store starting with the hex encoded prefix, every key if
+omitted, committed in the given height range of a Firehose archive indexed by the index command.
+Only the blocks which may contain writes to the prefix according to the index are read.`,
+		Args: cobra.RangeArgs(2, 3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var prefix []byte
+			if len(args) == 3 {
+				var err error
+				if prefix, err = hex.DecodeString(args[2]); err != nil {
+					return fmt.Errorf("invalid prefix: %w", err)
+				}
+			}
+
+			indexPath, _ := cmd.Flags().GetString(flagIndex)
+			if indexPath == "" {
+				indexPath = args[0] + ".index"
+			}
+
+			archive, err := streaming.OpenArchive(args[0], indexPath)
+			if err != nil {
+				return err
+			}
+			defer archive.Close()
+
+			fromHeight, _ := cmd.Flags().GetInt64(flagFromHeight)
+			toHeight, _ := cmd.Flags().GetInt64(flagToHeight)
+			records, err := archive.Query(args[1], prefix, fromHeight, toHeight)
+			if err != nil {
+				return err
+			}
+
+			output, _ := cmd.Flags().GetString(cli.OutputFlag)
+			return printArchiveRecords(cmd.OutOrStdout(), records, strings.ToLower(output) == "json")
+		},
+	}
+
+	cmd.Flags().String(flagIndex, "", "Path of the index (defaults to the archive path with an .index suffix)")
+	cmd.Flags().Int64(flagFromHeight, 1, "First height of the range to query")
+	cmd.Flags().Int64(flagToHeight, math.MaxInt64, "Last height of the range to query")
+	cmd.Flags().StringP(cli.OutputFlag, "o", "text", "Output format (text|json)")
+
+	return cmd
+}
+
+// printArchiveRecords prints the records, as JSON lines or as text with the
+// keys and values hex encoded.
+func printArchiveRecords(w io.Writer, records []streaming.ArchiveRecord, asJSON bool) error {
+	enc := json.NewEncoder(w)
+
+	for _, record := range records {
+		var err error
+		switch {
+		case asJSON:
+			err = enc.Encode(record)
+		case record.Delete:
+			_, err = fmt.Fprintf(w, "%d %s delete %s\n", record.Height, record.StoreKey, hex.EncodeToString(record.Key))
+		default:
+			_, err = fmt.Fprintf(w, "%d %s set %s %s\n", record.Height, record.StoreKey, hex.EncodeToString(record.Key), hex.EncodeToString(record.Value))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printFrames prints every record read from r, as JSON lines or as text with
+// the keys and values hex encoded.
+func printFrames(w io.Writer, r *storetypes.FrameReader, asJSON bool) error {
+	enc := json.NewEncoder(w)
+
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if asJSON {
+			if err := enc.Encode(record); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if record.Delete {
+			_, err = fmt.Fprintf(w, "%s delete %s\n", record.StoreKey, hex.EncodeToString(record.Key))
+		} else {
+			_, err = fmt.Fprintf(w, "%s set %s %s\n", record.StoreKey, hex.EncodeToString(record.Key), hex.EncodeToString(record.Value))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// followReader reads from a file that is being appended to, polling it for
+// new data instead of returning io.EOF until done is closed.
+type followReader struct {
+	reader       io.Reader
+	pollInterval time.Duration
+	done         <-chan struct{}
+}
+
+// Read implements the io.Reader interface.
+func (r *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.reader.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+
+		select {
+		case <-r.done:
+			return 0, io.EOF
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
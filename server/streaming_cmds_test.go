@@ -0,0 +1,238 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/server/streaming"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+)
+
+func TestTailCmd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	key := storetypes.NewKVStoreKey("acc")
+	l := storetypes.NewFrameWriteListener(f)
+	l.OnWrite(key, []byte{0x01}, []byte{0xab})
+	l.OnWrite(key, []byte{0x02}, nil)
+	require.NoError(t, l.Err())
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"text", []string{path}, "acc set 01 ab\nacc delete 02\n"},
+		{"json", []string{path, "--output=json"}, `{"store_key":"acc","key":"AQ==","value":"qw==","delete":false}
+{"store_key":"acc","key":"Ag==","delete":true}
+`},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := TailCmd()
+			output := &bytes.Buffer{}
+			cmd.SetOut(output)
+			cmd.SetArgs(tc.args)
+
+			require.NoError(t, cmd.Execute())
+			require.Equal(t, tc.expected, output.String())
+		})
+	}
+}
+
+func TestCompareCmd(t *testing.T) {
+	dir := t.TempDir()
+	key := storetypes.NewKVStoreKey("acc")
+
+	writeStream := func(name string, value []byte) string {
+		f, err := os.Create(filepath.Join(dir, name))
+		require.NoError(t, err)
+		defer f.Close()
+
+		fh := streaming.NewFirehose(f)
+		fh.OnWrite(key, []byte{0x01}, []byte{0xab})
+		fh.OnCommit(1)
+		fh.OnWrite(key, []byte{0x02}, value)
+		fh.OnCommit(2)
+		require.NoError(t, fh.Err())
+
+		return f.Name()
+	}
+
+	a, b, c := writeStream("a", []byte{0xcd}), writeStream("b", []byte{0xcd}), writeStream("c", nil)
+
+	cmd := CompareCmd()
+	output := &bytes.Buffer{}
+	cmd.SetOut(output)
+	cmd.SetArgs([]string{a, b})
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, "2 blocks identical\n", output.String())
+
+	cmd = CompareCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{a, c})
+	require.EqualError(t, cmd.Execute(), "streams differ after 1 identical blocks: block 2 differs at write 0: acc set 02 CD != acc delete 02")
+}
+
+func TestRelayCmd(t *testing.T) {
+	dir := t.TempDir()
+	key := storetypes.NewKVStoreKey("acc")
+
+	writeStream := func(name string, blocks int) string {
+		f, err := os.Create(filepath.Join(dir, name))
+		require.NoError(t, err)
+		defer f.Close()
+
+		fh := streaming.NewFirehose(f)
+		for height := 1; height <= blocks; height++ {
+			fh.OnWrite(key, []byte{byte(height)}, []byte{0xab})
+			fh.OnCommit(int64(height))
+		}
+		require.NoError(t, fh.Err())
+
+		return f.Name()
+	}
+
+	a, b := writeStream("a", 2), writeStream("b", 3)
+	expected, err := ioutil.ReadFile(b)
+	require.NoError(t, err)
+
+	cmd := RelayCmd()
+	output, errOutput := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.SetOut(output)
+	cmd.SetErr(errOutput)
+	cmd.SetArgs([]string{a, b})
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, string(expected), output.String())
+	require.Equal(t, "3 blocks relayed, 1 failovers\n", errOutput.String())
+}
+
+func TestArchiveCmds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	key := storetypes.NewKVStoreKey("acc")
+	fh := streaming.NewFirehose(f)
+	fh.OnWrite(key, []byte{0x01, 0x01}, []byte{0xab})
+	fh.OnWrite(key, []byte{0x02, 0x01}, []byte{0xab})
+	fh.OnCommit(1)
+	fh.OnWrite(key, []byte{0x01, 0x02}, nil)
+	fh.OnCommit(2)
+	require.NoError(t, fh.Err())
+
+	cmd := IndexArchiveCmd()
+	cmd.SetArgs([]string{path})
+	require.NoError(t, cmd.Execute())
+	require.FileExists(t, path+".index")
+
+	cmd = QueryArchiveCmd()
+	output := &bytes.Buffer{}
+	cmd.SetOut(output)
+	cmd.SetArgs([]string{path, "acc", "01"})
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, "1 acc set 0101 ab\n2 acc delete 0102\n", output.String())
+}
+
+func TestTailCmdFollow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cmd := TailCmd()
+	output := &syncBuffer{}
+	cmd.SetOut(output)
+	cmd.SetArgs([]string{path, "--follow", "--poll-interval=10ms"})
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- cmd.ExecuteContext(ctx) }()
+
+	// the state changes appended to the file are printed as they are written
+	key := storetypes.NewKVStoreKey("acc")
+	l := storetypes.NewFrameWriteListener(f)
+	for i := byte(0); i < 3; i++ {
+		l.OnWrite(key, []byte{i}, []byte{i})
+
+		expected := fmt.Sprintf("acc set %02x %02x\n", i, i)
+		require.Eventually(t, func() bool {
+			return bytes.HasSuffix([]byte(output.String()), []byte(expected))
+		}, time.Second, 10*time.Millisecond)
+	}
+
+	cancel()
+	require.NoError(t, <-errCh)
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.buf.String()
+}
+
+func TestSinkFromFlags(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		addSinkFlags(cmd)
+		require.NoError(t, cmd.ParseFlags(args))
+
+		return cmd
+	}
+
+	sink, _, err := sinkFromFlags(newCmd())
+	require.NoError(t, err)
+	require.Nil(t, sink, "the app's streaming listeners are used without flags")
+
+	_, _, err = sinkFromFlags(newCmd("--output=state", "--destination=state.sock"))
+	require.Error(t, err)
+
+	// the state written to an output file can be tailed
+	path := filepath.Join(t.TempDir(), "state")
+	sink, closeSink, err := sinkFromFlags(newCmd("--output=" + path))
+	require.NoError(t, err)
+
+	sink.OnWrite(storetypes.NewKVStoreKey("acc"), []byte{0x01}, []byte{0xab})
+	require.NoError(t, closeSink())
+
+	cmd := TailCmd()
+	output := &bytes.Buffer{}
+	cmd.SetOut(output)
+	cmd.SetArgs([]string{path})
+
+	require.NoError(t, cmd.Execute())
+	require.Equal(t, "acc set 01 ab\n", output.String())
+}
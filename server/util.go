@@ -229,6 +229,7 @@ func AddCommands(rootCmd *cobra.Command, defaultNodeHome string, appCreator type
 		flags.LineBreak,
 		tendermintCmd,
 		ExportCmd(appExport, defaultNodeHome),
+		StreamingCmd(appCreator, defaultNodeHome),
 		flags.LineBreak,
 		version.NewVersionCommand(),
 	)
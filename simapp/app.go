@@ -24,6 +24,7 @@ import (
 	"github.com/cosmos/cosmos-sdk/codec/types"
 	"github.com/cosmos/cosmos-sdk/server/api"
 	"github.com/cosmos/cosmos-sdk/server/config"
+	streamingservices "github.com/cosmos/cosmos-sdk/server/streaming/services"
 	servertypes "github.com/cosmos/cosmos-sdk/server/types"
 	simappparams "github.com/cosmos/cosmos-sdk/simapp/params"
 	"github.com/cosmos/cosmos-sdk/testutil/testdata"
@@ -415,6 +416,13 @@ func NewSimApp(
 	)
 	app.SetEndBlocker(app.EndBlocker)
 
+	// stream the state changes to the services enabled in the streaming
+	// section of app.toml, which must be registered before the stores are
+	// loaded
+	if err := streamingservices.RegisterStreamingServices(app.BaseApp, appOpts, keys); err != nil {
+		tmos.Exit(err.Error())
+	}
+
 	if loadLatest {
 		if err := app.LoadLatestVersion(); err != nil {
 			tmos.Exit(err.Error())
@@ -27,6 +27,9 @@ var (
 	FlagVerboseValue     bool
 	FlagPeriodValue      uint
 	FlagGenesisTimeValue int64
+
+	FlagStreamGoldenPathValue   string
+	FlagUpdateStreamGoldenValue bool
 )
 
 // GetSimulatorFlags gets the values of all the available simulation flags
@@ -52,6 +55,10 @@ func GetSimulatorFlags() {
 	flag.BoolVar(&FlagVerboseValue, "Verbose", false, "verbose log output")
 	flag.UintVar(&FlagPeriodValue, "Period", 0, "run slow invariants only once every period assertions")
 	flag.Int64Var(&FlagGenesisTimeValue, "GenesisTime", 0, "override genesis UNIX time instead of using a random UNIX time")
+
+	// streaming flags
+	flag.StringVar(&FlagStreamGoldenPathValue, "StreamGoldenPath", "", "golden file of the state changes streamed by the seeded simulation")
+	flag.BoolVar(&FlagUpdateStreamGoldenValue, "UpdateStreamGolden", false, "record the state changes streamed by the seeded simulation into the golden file")
 }
 
 // NewConfigFromFlags creates a simulation from the retrieved values of the flags.
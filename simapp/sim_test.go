@@ -1,8 +1,12 @@
 package simapp
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"testing"
@@ -16,6 +20,8 @@ import (
 	"github.com/cosmos/cosmos-sdk/baseapp"
 	"github.com/cosmos/cosmos-sdk/simapp/helpers"
 	"github.com/cosmos/cosmos-sdk/store"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/testutil/streaming"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	simtypes "github.com/cosmos/cosmos-sdk/types/simulation"
 	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
@@ -335,3 +341,146 @@ func TestAppStateDeterminism(t *testing.T) {
 		}
 	}
 }
+
+// defaultStreamGoldenPath is the golden file of the state changes streamed by
+// the short seeded simulation TestAppStreamingGolden runs by default.
+const defaultStreamGoldenPath = "testdata/streaming_golden.json"
+
+// streamDigest summarizes the state changes streamed for a store: the number
+// of writes and the SHA-256 hash of their frames.
+type streamDigest struct {
+	Writes int    `json:"writes"`
+	SHA256 string `json:"sha256"`
+}
+
+// TestAppStreamingGolden runs a seeded simulation streaming the state changes
+// of every store, and checks the digest of the writes of each store matches
+// the golden file, or records it into the file with -UpdateStreamGolden. This
+// catches accidental changes to the streamed writes or their framing, and
+// names the stores they affect.
+//
+// By default, a two-block simulation with a fixed seed is checked against
+// testdata/streaming_golden.json. With -Enabled and -StreamGoldenPath, the
+// simulation configured by the flags is checked against the given file.
+func TestAppStreamingGolden(t *testing.T) {
+	config := simtypes.Config{
+		Seed:               2,
+		InitialBlockHeight: 1,
+		NumBlocks:          2,
+		BlockSize:          20,
+		Commit:             true,
+	}
+	goldenPath := defaultStreamGoldenPath
+
+	if FlagEnabledValue && FlagStreamGoldenPathValue != "" {
+		config = NewConfigFromFlags()
+		goldenPath = FlagStreamGoldenPathValue
+	}
+
+	config.ChainID = helpers.SimAppChainID
+	config.ExportParamsPath = ""
+	config.ExportStatePath = ""
+	config.ExportStatsPath = ""
+
+	db := dbm.NewMemDB()
+	app := NewSimApp(log.NewNopLogger(), db, nil, false, map[int64]bool{}, DefaultNodeHome, FlagPeriodValue, MakeTestEncodingConfig(), EmptyAppOptions{})
+
+	// the stores of the multi-store are written in a random order on commit,
+	// so each store is streamed to its own sink
+	sinks := make(map[string]*streaming.FrameSink, len(app.keys))
+	listeners := make(map[string][]storetypes.WriteListener, len(app.keys))
+	for name := range app.keys {
+		sinks[name] = streaming.NewFrameSink()
+		listeners[name] = []storetypes.WriteListener{sinks[name]}
+	}
+	loadLatestWithStreaming(app, listeners)
+
+	_, _, err := simulation.SimulateFromSeed(
+		t,
+		os.Stdout,
+		app.BaseApp,
+		AppStateFn(app.AppCodec(), app.SimulationManager()),
+		simtypes.RandomAccounts,
+		SimulationOperations(app, app.AppCodec(), config),
+		app.ModuleAccountAddrs(),
+		config,
+		app.AppCodec(),
+	)
+	require.NoError(t, err)
+
+	digests := make(map[string]streamDigest, len(sinks))
+	for name, sink := range sinks {
+		records, err := sink.Records()
+		require.NoError(t, err)
+
+		var stream bytes.Buffer
+		l := storetypes.NewFrameWriteListener(&stream)
+		for _, record := range records {
+			value := record.Value
+			if record.Delete {
+				value = nil
+			}
+
+			l.OnWrite(app.keys[name], record.Key, value)
+		}
+		require.NoError(t, l.Err())
+
+		hash := sha256.Sum256(stream.Bytes())
+		digests[name] = streamDigest{Writes: len(records), SHA256: hex.EncodeToString(hash[:])}
+	}
+
+	if FlagUpdateStreamGoldenValue {
+		bz, err := json.MarshalIndent(digests, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(goldenPath, append(bz, '\n'), 0600))
+		return
+	}
+
+	bz, err := ioutil.ReadFile(goldenPath)
+	require.NoError(t, err)
+
+	var golden map[string]streamDigest
+	require.NoError(t, json.Unmarshal(bz, &golden))
+	require.Equal(t, golden, digests, "streamed state changes differ from the golden file %s", goldenPath)
+}
+
+// TestAppStreamingState runs a simulation streaming the state changes of
+// every store to a StateSink, and checks on the commit of every block that
+// the state reconstructed from the streamed writes equals the state of the
+// stores, catching lost or duplicated writes.
+func TestAppStreamingState(t *testing.T) {
+	config, db, dir, logger, skip, err := SetupSimulation("leveldb-app-sim", "Simulation")
+	if skip {
+		t.Skip("skipping application streaming simulation")
+	}
+	require.NoError(t, err, "simulation setup failed")
+
+	defer func() {
+		db.Close()
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	app := NewSimApp(logger, db, nil, false, map[int64]bool{}, DefaultNodeHome, FlagPeriodValue, MakeTestEncodingConfig(), EmptyAppOptions{}, fauxMerkleModeOpt)
+
+	sink := streaming.NewStateSink()
+	listeners := make(map[string][]storetypes.WriteListener, len(app.keys))
+	for name := range app.keys {
+		listeners[name] = []storetypes.WriteListener{sink}
+	}
+	loadLatestWithStreaming(app, listeners)
+	sink.VerifyOnCommit(app.BaseApp.NewUncachedContext(true, tmproto.Header{}).MultiStore())
+
+	_, _, err = simulation.SimulateFromSeed(
+		t,
+		os.Stdout,
+		app.BaseApp,
+		AppStateFn(app.AppCodec(), app.SimulationManager()),
+		simtypes.RandomAccounts,
+		SimulationOperations(app, app.AppCodec(), config),
+		app.ModuleAccountAddrs(),
+		config,
+		app.AppCodec(),
+	)
+	require.NoError(t, err)
+	require.NoError(t, sink.Err())
+}
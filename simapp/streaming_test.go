@@ -0,0 +1,196 @@
+package simapp_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	"github.com/cosmos/cosmos-sdk/simapp"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/testutil/streaming"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+)
+
+// balanceKey returns the key of the balance of the given denom of addr in the
+// bank store.
+func balanceKey(addr sdk.AccAddress, denom string) []byte {
+	key := append([]byte{}, banktypes.BalancesPrefix...)
+	key = append(key, addr...)
+	return append(key, denom...)
+}
+
+func TestStreamingBankSend(t *testing.T) {
+	priv1 := secp256k1.GenPrivKey()
+	addr1 := sdk.AccAddress(priv1.PubKey().Address())
+	addr2 := sdk.AccAddress(secp256k1.GenPrivKey().PubKey().Address())
+
+	genAccs := []authtypes.GenesisAccount{&authtypes.BaseAccount{Address: addr1.String()}}
+	genBalances := []banktypes.Balance{{Address: addr1.String(), Coins: sdk.NewCoins(sdk.NewInt64Coin("foocoin", 100))}}
+
+	memory := streaming.NewMemoryListener()
+	frames := streaming.NewFrameSink()
+	listeners := map[string][]storetypes.WriteListener{banktypes.StoreKey: {memory, frames}}
+
+	app := simapp.SetupWithStreaming(listeners, genAccs, genBalances...)
+	bankKey := app.GetKey(banktypes.StoreKey)
+
+	requireBalance := func(addr sdk.AccAddress, amount int64) {
+		value, ok := memory.Get(bankKey, balanceKey(addr, "foocoin"))
+		require.True(t, ok, "balance of %s not streamed", addr)
+
+		var balance sdk.Coin
+		app.AppCodec().MustUnmarshalBinaryBare(value, &balance)
+		require.Equal(t, sdk.NewInt64Coin("foocoin", amount), balance)
+	}
+
+	// the genesis balances are streamed
+	requireBalance(addr1, 100)
+
+	memory.Reset()
+
+	ctx := app.BaseApp.NewContext(true, tmproto.Header{})
+	acc := app.AccountKeeper.GetAccount(ctx, addr1)
+
+	sendMsg := banktypes.NewMsgSend(addr1, addr2, sdk.NewCoins(sdk.NewInt64Coin("foocoin", 40)))
+	header := tmproto.Header{Height: app.LastBlockHeight() + 1}
+	txGen := simapp.MakeTestEncodingConfig().TxConfig
+	_, _, err := simapp.SignCheckDeliver(t, txGen, app.BaseApp, header, []sdk.Msg{sendMsg}, "", []uint64{acc.GetAccountNumber()}, []uint64{acc.GetSequence()}, true, true, priv1)
+	require.NoError(t, err)
+
+	// only the bank store is streamed, and the sent balances with it
+	for _, write := range memory.Writes() {
+		require.Equal(t, bankKey, write.StoreKey)
+	}
+	requireBalance(addr1, 60)
+	requireBalance(addr2, 40)
+
+	// the frames encode the same writes as received by the memory listener
+	records, err := frames.Records()
+	require.NoError(t, err)
+
+	writes := memory.Writes()
+	require.True(t, len(records) > len(writes))
+
+	records = records[len(records)-len(writes):]
+	for i, write := range writes {
+		require.Equal(t, banktypes.StoreKey, records[i].StoreKey)
+		require.Equal(t, write.Key, records[i].Key)
+		require.Equal(t, write.Value == nil, records[i].Delete)
+		if !records[i].Delete {
+			require.Equal(t, write.Value, records[i].Value)
+		}
+	}
+}
+
+func TestStreamingStakingDelegation(t *testing.T) {
+	priv1, priv2 := secp256k1.GenPrivKey(), secp256k1.GenPrivKey()
+	addr1 := sdk.AccAddress(priv1.PubKey().Address())
+	addr2 := sdk.AccAddress(priv2.PubKey().Address())
+	valAddr := sdk.ValAddress(addr1)
+
+	genCoins := sdk.NewCoins(sdk.NewCoin(sdk.DefaultBondDenom, sdk.TokensFromConsensusPower(42)))
+	bondCoin := sdk.NewCoin(sdk.DefaultBondDenom, sdk.TokensFromConsensusPower(10))
+
+	genAccs := []authtypes.GenesisAccount{
+		&authtypes.BaseAccount{Address: addr1.String()},
+		&authtypes.BaseAccount{Address: addr2.String()},
+	}
+	genBalances := []banktypes.Balance{
+		{Address: addr1.String(), Coins: genCoins},
+		{Address: addr2.String(), Coins: genCoins},
+	}
+
+	memory := streaming.NewMemoryListener()
+	listeners := map[string][]storetypes.WriteListener{stakingtypes.StoreKey: {memory}}
+
+	app := simapp.SetupWithStreaming(listeners, genAccs, genBalances...)
+	stakingKey := app.GetKey(stakingtypes.StoreKey)
+	txGen := simapp.MakeTestEncodingConfig().TxConfig
+
+	deliver := func(priv *secp256k1.PrivKey, accNum, seq uint64, msg sdk.Msg) {
+		header := tmproto.Header{Height: app.LastBlockHeight() + 1}
+		_, _, err := simapp.SignCheckDeliver(t, txGen, app.BaseApp, header, []sdk.Msg{msg}, "", []uint64{accNum}, []uint64{seq}, true, true, priv)
+		require.NoError(t, err)
+	}
+
+	commission := stakingtypes.NewCommissionRates(sdk.NewDecWithPrec(1, 1), sdk.NewDecWithPrec(2, 1), sdk.NewDecWithPrec(1, 2))
+	createValidatorMsg, err := stakingtypes.NewMsgCreateValidator(
+		valAddr, ed25519.GenPrivKey().PubKey(), bondCoin, stakingtypes.NewDescription("moniker", "", "", "", ""), commission, sdk.OneInt(),
+	)
+	require.NoError(t, err)
+	deliver(priv1, 0, 0, createValidatorMsg)
+
+	// the delegation is streamed as a row of the staking store
+	memory.Reset()
+	deliver(priv2, 1, 0, stakingtypes.NewMsgDelegate(addr2, valAddr, bondCoin))
+
+	value, ok := memory.Get(stakingKey, stakingtypes.GetDelegationKey(addr2, valAddr))
+	require.True(t, ok, "delegation not streamed")
+
+	var delegation stakingtypes.Delegation
+	app.AppCodec().MustUnmarshalBinaryBare(value, &delegation)
+	require.Equal(t, addr2.String(), delegation.DelegatorAddress)
+	require.Equal(t, valAddr.String(), delegation.ValidatorAddress)
+	require.True(t, delegation.Shares.Equal(bondCoin.Amount.ToDec()))
+
+	// undelegating streams the delete of the delegation and the unbonding
+	// delegation
+	memory.Reset()
+	deliver(priv2, 1, 1, stakingtypes.NewMsgUndelegate(addr2, valAddr, bondCoin))
+
+	value, ok = memory.Get(stakingKey, stakingtypes.GetDelegationKey(addr2, valAddr))
+	require.True(t, ok, "delegation delete not streamed")
+	require.Nil(t, value)
+
+	value, ok = memory.Get(stakingKey, stakingtypes.GetUBDKey(addr2, valAddr))
+	require.True(t, ok, "unbonding delegation not streamed")
+
+	var ubd stakingtypes.UnbondingDelegation
+	app.AppCodec().MustUnmarshalBinaryBare(value, &ubd)
+	require.Equal(t, addr2.String(), ubd.DelegatorAddress)
+	require.Len(t, ubd.Entries, 1)
+	require.Equal(t, bondCoin.Amount, ubd.Entries[0].Balance)
+}
+
+func TestStreamingStateMatchesStores(t *testing.T) {
+	priv1 := secp256k1.GenPrivKey()
+	addr1 := sdk.AccAddress(priv1.PubKey().Address())
+	addr2 := sdk.AccAddress(secp256k1.GenPrivKey().PubKey().Address())
+
+	genAccs := []authtypes.GenesisAccount{&authtypes.BaseAccount{Address: addr1.String()}}
+	genBalances := []banktypes.Balance{{Address: addr1.String(), Coins: sdk.NewCoins(sdk.NewInt64Coin("foocoin", 100))}}
+
+	sink := streaming.NewStateSink()
+	listeners := map[string][]storetypes.WriteListener{
+		authtypes.StoreKey: {sink},
+		banktypes.StoreKey: {sink},
+	}
+
+	app := simapp.SetupWithStreaming(listeners, genAccs, genBalances...)
+	ms := app.BaseApp.NewUncachedContext(true, tmproto.Header{}).MultiStore()
+	sink.VerifyOnCommit(ms)
+
+	// the genesis state is streamed in full
+	for name := range listeners {
+		key := app.GetKey(name)
+		require.NoError(t, sink.Verify(key, ms.GetKVStore(key)))
+	}
+
+	ctx := app.BaseApp.NewContext(true, tmproto.Header{})
+	acc := app.AccountKeeper.GetAccount(ctx, addr1)
+
+	sendMsg := banktypes.NewMsgSend(addr1, addr2, sdk.NewCoins(sdk.NewInt64Coin("foocoin", 40)))
+	header := tmproto.Header{Height: app.LastBlockHeight() + 1}
+	txGen := simapp.MakeTestEncodingConfig().TxConfig
+	_, _, err := simapp.SignCheckDeliver(t, txGen, app.BaseApp, header, []sdk.Msg{sendMsg}, "", []uint64{acc.GetAccountNumber()}, []uint64{acc.GetSequence()}, true, true, priv1)
+	require.NoError(t, err)
+
+	// the state is verified on the commit of the block
+	require.NoError(t, sink.Err())
+}
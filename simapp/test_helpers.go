@@ -23,6 +23,7 @@ import (
 	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
 	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
 	"github.com/cosmos/cosmos-sdk/simapp/helpers"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/types/errors"
 	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
@@ -159,6 +160,49 @@ func SetupWithGenesisAccounts(genAccs []authtypes.GenesisAccount, balances ...ba
 	db := dbm.NewMemDB()
 	app := NewSimApp(log.NewNopLogger(), db, nil, true, map[int64]bool{}, DefaultNodeHome, 0, MakeTestEncodingConfig(), EmptyAppOptions{})
 
+	initChainWithGenesisAccounts(app, genAccs, balances...)
+
+	return app
+}
+
+// SetupWithStreaming initializes a new SimApp like SetupWithGenesisAccounts,
+// registering the provided WriteListeners for the stores with the given names
+// before the app is loaded, so every state change from genesis on is streamed
+// to them.
+func SetupWithStreaming(listeners map[string][]storetypes.WriteListener, genAccs []authtypes.GenesisAccount, balances ...banktypes.Balance) *SimApp {
+	db := dbm.NewMemDB()
+	app := NewSimApp(log.NewNopLogger(), db, nil, false, map[int64]bool{}, DefaultNodeHome, 0, MakeTestEncodingConfig(), EmptyAppOptions{})
+	loadLatestWithStreaming(app, listeners)
+
+	initChainWithGenesisAccounts(app, genAccs, balances...)
+
+	return app
+}
+
+// loadLatestWithStreaming registers the provided WriteListeners for the stores
+// with the given names with a SimApp created without loading its latest
+// version, and then loads it.
+func loadLatestWithStreaming(app *SimApp, listeners map[string][]storetypes.WriteListener) {
+	for name, storeListeners := range listeners {
+		key := app.GetKey(name)
+		if key == nil {
+			panic(fmt.Sprintf("unknown store %s", name))
+		}
+
+		app.AddStreamingListeners(key, storeListeners...)
+	}
+
+	if err := app.LoadLatestVersion(); err != nil {
+		panic(err)
+	}
+
+	ctx := app.BaseApp.NewUncachedContext(true, tmproto.Header{})
+	app.CapabilityKeeper.InitializeAndSeal(ctx)
+}
+
+// initChainWithGenesisAccounts initializes the chain with the provided genesis
+// accounts and possible balances, commits it and begins the next block.
+func initChainWithGenesisAccounts(app *SimApp, genAccs []authtypes.GenesisAccount, balances ...banktypes.Balance) {
 	// initialize the chain with the passed in genesis accounts
 	genesisState := NewDefaultGenesisState()
 
@@ -188,8 +232,6 @@ func SetupWithGenesisAccounts(genAccs []authtypes.GenesisAccount, balances ...ba
 
 	app.Commit()
 	app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: app.LastBlockHeight() + 1}})
-
-	return app
 }
 
 type GenerateAccountStrategy func(int) []sdk.AccAddress
@@ -1,6 +1,7 @@
 package iavl
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -192,6 +193,114 @@ func (st *Store) DeleteVersions(versions ...int64) error {
 	return st.tree.DeleteVersions(versions...)
 }
 
+// StoreDiff returns the changes made to the store between the given versions
+// in ascending key order: every key whose value at toVersion differs from its
+// value at fromVersion, with a nil Value for the keys missing at toVersion.
+// Version 0 is the empty store. An error is returned if either version does
+// not exist or has been pruned.
+func (st *Store) StoreDiff(fromVersion, toVersion int64) ([]types.KVPairDelta, error) {
+	diff, err := st.LoadStoreDiff(fromVersion, toVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff()
+}
+
+// LoadStoreDiff loads the given versions of the store and returns a function
+// computing the changes made to the store between them, as StoreDiff does.
+// Only loading the versions must be serialized with the commits of the store:
+// the changes are computed from the immutable versions loaded, as long as
+// they are not pruned meanwhile.
+func (st *Store) LoadStoreDiff(fromVersion, toVersion int64) (func() ([]types.KVPairDelta, error), error) {
+	from, err := st.getVersion(fromVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := st.getVersion(toVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() ([]types.KVPairDelta, error) {
+		var deltas []types.KVPairDelta
+		err := diffStores(from, to, func(key, value []byte) {
+			deltas = append(deltas, types.KVPairDelta{Key: key, Value: value})
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return deltas, nil
+	}, nil
+}
+
+// getVersion returns the store at the given version, which must exist unless
+// it is 0, the empty store.
+func (st *Store) getVersion(version int64) (*Store, error) {
+	if version == 0 {
+		return &Store{tree: &immutableTree{&iavl.ImmutableTree{}}}, nil
+	}
+
+	if version < 0 || !st.VersionExists(version) {
+		return nil, sdkerrors.Wrapf(types.ErrVersionNotFound, "version %d", version)
+	}
+
+	return st.GetImmutable(version)
+}
+
+// diffStores calls fn, in ascending key order, for every key whose value
+// differs between the from and to stores, with a nil value for the keys only
+// in the from store.
+func diffStores(from, to types.KVStore, fn func(key, value []byte)) (err error) {
+	fromIt := from.Iterator(nil, nil)
+	defer func() {
+		if cerr := fromIt.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	toIt := to.Iterator(nil, nil)
+	defer func() {
+		if cerr := toIt.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	for fromIt.Valid() || toIt.Valid() {
+		var cmp int
+		switch {
+		case !fromIt.Valid():
+			cmp = 1
+		case !toIt.Valid():
+			cmp = -1
+		default:
+			cmp = bytes.Compare(fromIt.Key(), toIt.Key())
+		}
+
+		switch {
+		case cmp < 0:
+			fn(fromIt.Key(), nil)
+			fromIt.Next()
+
+		case cmp > 0:
+			fn(toIt.Key(), toIt.Value())
+			toIt.Next()
+
+		default:
+			if !bytes.Equal(fromIt.Value(), toIt.Value()) {
+				fn(toIt.Key(), toIt.Value())
+			}
+
+			fromIt.Next()
+			toIt.Next()
+		}
+	}
+
+	return nil
+}
+
 // Implements types.KVStore.
 func (st *Store) Iterator(start, end []byte) types.Iterator {
 	var iTree *iavl.ImmutableTree
@@ -136,6 +136,41 @@ func TestGetImmutable(t *testing.T) {
 	require.Panics(t, func() { newStore.Commit() })
 }
 
+func TestStoreDiff(t *testing.T) {
+	db := dbm.NewMemDB()
+	tree, cID := newAlohaTree(t, db)
+	store := UnsafeNewStore(tree)
+
+	require.True(t, tree.Set([]byte("hello"), []byte("adios")))
+	tree.Remove([]byte("aloha"))
+	tree.Set([]byte("hola"), []byte("ciao"))
+	_, ver, err := tree.SaveVersion()
+	require.NoError(t, err)
+
+	deltas, err := store.StoreDiff(cID.Version, ver)
+	require.NoError(t, err)
+	require.Equal(t, []types.KVPairDelta{
+		{Key: []byte("aloha")},
+		{Key: []byte("hello"), Value: []byte("adios")},
+		{Key: []byte("hola"), Value: []byte("ciao")},
+	}, deltas)
+
+	// version 0 is the empty store
+	deltas, err = store.StoreDiff(0, cID.Version)
+	require.NoError(t, err)
+	require.Equal(t, []types.KVPairDelta{
+		{Key: []byte("aloha"), Value: []byte("shalom")},
+		{Key: []byte("hello"), Value: []byte("goodbye")},
+	}, deltas)
+
+	deltas, err = store.StoreDiff(ver, ver)
+	require.NoError(t, err)
+	require.Empty(t, deltas)
+
+	_, err = store.StoreDiff(cID.Version, ver+1)
+	require.True(t, types.ErrVersionNotFound.Is(err))
+}
+
 func TestTestGetImmutableIterator(t *testing.T) {
 	db := dbm.NewMemDB()
 	tree, cID := newAlohaTree(t, db)
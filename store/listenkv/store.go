@@ -0,0 +1,132 @@
+package listenkv
+
+import (
+	"io"
+
+	"github.com/cosmos/cosmos-sdk/store/cachekv"
+	"github.com/cosmos/cosmos-sdk/store/tracekv"
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+var _ types.KVStore = &Store{}
+
+// Store implements the KVStore interface with listening enabled.
+// Every write operation (Set or Delete) is forwarded to the parent KVStore and
+// then passed to each of the underlying WriteListeners along with the store
+// key of the parent store. The listeners which also implement ReadListener are
+// passed every value read from the parent KVStore.
+type Store struct {
+	parent         types.KVStore
+	listeners      []types.WriteListener
+	readListeners  []types.ReadListener
+	parentStoreKey types.StoreKey
+}
+
+// NewStore returns a reference to a new listenkv Store given a parent KVStore,
+// the StoreKey the parent is mounted under and the WriteListeners to notify.
+func NewStore(parent types.KVStore, parentStoreKey types.StoreKey, listeners []types.WriteListener) *Store {
+	s := &Store{parent: parent, listeners: listeners, parentStoreKey: parentStoreKey}
+	for _, l := range listeners {
+		if rl, ok := l.(types.ReadListener); ok {
+			s.readListeners = append(s.readListeners, rl)
+		}
+	}
+
+	return s
+}
+
+// Get implements the KVStore interface. It delegates the Get call to the
+// parent KVStore and then notifies the read listeners of the read.
+func (s *Store) Get(key []byte) []byte {
+	value := s.parent.Get(key)
+	s.onRead(key, value)
+	return value
+}
+
+// Set implements the KVStore interface. It delegates the Set call to the
+// parent KVStore and then notifies the listeners of the write.
+func (s *Store) Set(key []byte, value []byte) {
+	types.AssertValidKey(key)
+	s.parent.Set(key, value)
+	s.onWrite(key, value)
+}
+
+// Delete implements the KVStore interface. It delegates the Delete call to
+// the parent KVStore and then notifies the listeners of the deletion.
+func (s *Store) Delete(key []byte) {
+	s.parent.Delete(key)
+	s.onWrite(key, nil)
+}
+
+// Has implements the KVStore interface. It delegates the Has call to the
+// parent KVStore.
+func (s *Store) Has(key []byte) bool {
+	return s.parent.Has(key)
+}
+
+// Iterator implements the KVStore interface. It delegates the Iterator call
+// to the parent KVStore.
+func (s *Store) Iterator(start, end []byte) types.Iterator {
+	return s.iterator(s.parent.Iterator(start, end))
+}
+
+// ReverseIterator implements the KVStore interface. It delegates the
+// ReverseIterator call to the parent KVStore.
+func (s *Store) ReverseIterator(start, end []byte) types.Iterator {
+	return s.iterator(s.parent.ReverseIterator(start, end))
+}
+
+// iterator wraps the parent iterator so that the values read through it are
+// passed to the read listeners, if any.
+func (s *Store) iterator(parent types.Iterator) types.Iterator {
+	if len(s.readListeners) == 0 {
+		return parent
+	}
+
+	return &listenIterator{Iterator: parent, store: s}
+}
+
+// listenIterator is an Iterator which notifies the read listeners of its
+// store of every value read.
+type listenIterator struct {
+	types.Iterator
+	store *Store
+}
+
+// Value implements the Iterator interface.
+func (li *listenIterator) Value() []byte {
+	value := li.Iterator.Value()
+	li.store.onRead(li.Iterator.Key(), value)
+	return value
+}
+
+// GetStoreType implements the KVStore interface. It returns the underlying
+// KVStore type.
+func (s *Store) GetStoreType() types.StoreType {
+	return s.parent.GetStoreType()
+}
+
+// CacheWrap implements the CacheWrapper interface. Writes flushed from the
+// returned cache are passed through the Store and thus to its listeners.
+func (s *Store) CacheWrap() types.CacheWrap {
+	return cachekv.NewStore(s)
+}
+
+// CacheWrapWithTrace implements the CacheWrapper interface.
+func (s *Store) CacheWrapWithTrace(w io.Writer, tc types.TraceContext) types.CacheWrap {
+	return cachekv.NewStore(tracekv.NewStore(s, w, tc))
+}
+
+// onWrite writes a KVStore operation to all of the WriteListeners
+func (s *Store) onWrite(key, value []byte) {
+	for _, l := range s.listeners {
+		l.OnWrite(s.parentStoreKey, key, value)
+	}
+}
+
+// onRead passes a read to all of the ReadListeners
+func (s *Store) onRead(key, value []byte) {
+	for _, l := range s.readListeners {
+		l.OnRead(s.parentStoreKey, key, value)
+	}
+}
@@ -0,0 +1,193 @@
+package listenkv_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/listenkv"
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+func bz(s string) []byte { return []byte(s) }
+
+func keyFmt(i int) []byte { return bz(fmt.Sprintf("key%0.8d", i)) }
+func valFmt(i int) []byte { return bz(fmt.Sprintf("value%0.8d", i)) }
+
+var kvPairs = []types.KVPair{
+	{Key: keyFmt(1), Value: valFmt(1)},
+	{Key: keyFmt(2), Value: valFmt(2)},
+	{Key: keyFmt(3), Value: valFmt(3)},
+}
+
+var testStoreKey = types.NewKVStoreKey("listen_test")
+
+type write struct {
+	storeKey types.StoreKey
+	key      []byte
+	value    []byte
+}
+
+// recordingListener records every write it is notified of
+type recordingListener struct {
+	writes []write
+}
+
+func (l *recordingListener) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	l.writes = append(l.writes, write{storeKey: storeKey, key: key, value: value})
+}
+
+// readRecordingListener additionally records every read it is notified of
+type readRecordingListener struct {
+	recordingListener
+	reads []write
+}
+
+func (l *readRecordingListener) OnRead(storeKey types.StoreKey, key []byte, value []byte) {
+	l.reads = append(l.reads, write{storeKey: storeKey, key: key, value: value})
+}
+
+func newListenKVStore(listener types.WriteListener) *listenkv.Store {
+	store := newEmptyListenKVStore(listener)
+
+	for _, kvPair := range kvPairs {
+		store.Set(kvPair.Key, kvPair.Value)
+	}
+
+	return store
+}
+
+func newEmptyListenKVStore(listener types.WriteListener) *listenkv.Store {
+	memDB := dbadapter.Store{DB: dbm.NewMemDB()}
+
+	return listenkv.NewStore(memDB, testStoreKey, []types.WriteListener{listener})
+}
+
+func TestListenKVStoreGet(t *testing.T) {
+	listener := &recordingListener{}
+	store := newListenKVStore(listener)
+	listener.writes = nil
+
+	require.Equal(t, kvPairs[0].Value, store.Get(kvPairs[0].Key))
+	require.Nil(t, store.Get([]byte("does-not-exist")))
+	require.Empty(t, listener.writes, "reads should not be passed to the listeners")
+}
+
+func TestListenKVStoreSet(t *testing.T) {
+	for _, kvPair := range kvPairs {
+		listener := &recordingListener{}
+		store := newEmptyListenKVStore(listener)
+		store.Set(kvPair.Key, kvPair.Value)
+
+		require.Equal(t, []write{{storeKey: testStoreKey, key: kvPair.Key, value: kvPair.Value}}, listener.writes)
+		require.Equal(t, kvPair.Value, store.Get(kvPair.Key))
+	}
+
+	store := newEmptyListenKVStore(&recordingListener{})
+	require.Panics(t, func() { store.Set([]byte(""), []byte("value")) }, "setting an empty key should panic")
+	require.Panics(t, func() { store.Set(nil, []byte("value")) }, "setting a nil key should panic")
+}
+
+func TestListenKVStoreDelete(t *testing.T) {
+	listener := &recordingListener{}
+	store := newListenKVStore(listener)
+	listener.writes = nil
+
+	store.Delete(kvPairs[0].Key)
+
+	require.Equal(t, []write{{storeKey: testStoreKey, key: kvPairs[0].Key, value: nil}}, listener.writes)
+	require.False(t, store.Has(kvPairs[0].Key))
+}
+
+func TestListenKVStoreHas(t *testing.T) {
+	store := newListenKVStore(&recordingListener{})
+
+	require.True(t, store.Has(kvPairs[0].Key))
+	require.False(t, store.Has([]byte("does-not-exist")))
+}
+
+func TestListenKVStoreIterator(t *testing.T) {
+	store := newListenKVStore(&recordingListener{})
+	iterator := store.Iterator(nil, nil)
+	defer iterator.Close()
+
+	for _, kvPair := range kvPairs {
+		require.True(t, iterator.Valid())
+		require.Equal(t, kvPair.Key, iterator.Key())
+		require.Equal(t, kvPair.Value, iterator.Value())
+		iterator.Next()
+	}
+
+	require.False(t, iterator.Valid())
+}
+
+func TestListenKVStoreReverseIterator(t *testing.T) {
+	store := newListenKVStore(&recordingListener{})
+	iterator := store.ReverseIterator(nil, nil)
+	defer iterator.Close()
+
+	for i := len(kvPairs) - 1; i >= 0; i-- {
+		require.True(t, iterator.Valid())
+		require.Equal(t, kvPairs[i].Key, iterator.Key())
+		require.Equal(t, kvPairs[i].Value, iterator.Value())
+		iterator.Next()
+	}
+
+	require.False(t, iterator.Valid())
+}
+
+func TestListenKVStoreCacheWrap(t *testing.T) {
+	listener := &recordingListener{}
+	store := newEmptyListenKVStore(listener)
+
+	cache := store.CacheWrap().(types.CacheKVStore)
+	cache.Set(kvPairs[0].Key, kvPairs[0].Value)
+	require.Empty(t, listener.writes, "cached writes should not be passed to the listeners")
+
+	cache.Write()
+	require.Equal(t, []write{{storeKey: testStoreKey, key: kvPairs[0].Key, value: kvPairs[0].Value}}, listener.writes)
+}
+
+func TestListenKVStoreReadListener(t *testing.T) {
+	listener := &readRecordingListener{}
+	store := newListenKVStore(listener)
+	require.Empty(t, listener.reads, "writes should not be passed as reads")
+
+	require.Equal(t, kvPairs[0].Value, store.Get(kvPairs[0].Key))
+	require.Nil(t, store.Get([]byte("does-not-exist")))
+
+	iterator := store.Iterator(nil, kvPairs[1].Key)
+	require.Equal(t, kvPairs[0].Value, iterator.Value())
+	require.NoError(t, iterator.Close())
+
+	require.Equal(t, []write{
+		{storeKey: testStoreKey, key: kvPairs[0].Key, value: kvPairs[0].Value},
+		{storeKey: testStoreKey, key: []byte("does-not-exist")},
+		{storeKey: testStoreKey, key: kvPairs[0].Key, value: kvPairs[0].Value},
+	}, listener.reads)
+}
+
+func TestListenKVStoreCacheWrapWithTrace(t *testing.T) {
+	var buf bytes.Buffer
+	listener := &recordingListener{}
+	store := newEmptyListenKVStore(listener)
+
+	cache := store.CacheWrapWithTrace(&buf, types.TraceContext{}).(types.CacheKVStore)
+	cache.Set(kvPairs[0].Key, kvPairs[0].Value)
+	require.Empty(t, buf.String())
+
+	cache.Write()
+	require.Equal(t, []write{{storeKey: testStoreKey, key: kvPairs[0].Key, value: kvPairs[0].Value}}, listener.writes)
+	require.Equal(t, "{\"operation\":\"write\",\"key\":\"a2V5MDAwMDAwMDE=\",\"value\":\"dmFsdWUwMDAwMDAwMQ==\",\"metadata\":{}}\n", buf.String())
+}
+
+func TestListenKVStoreGetStoreType(t *testing.T) {
+	memDB := dbadapter.Store{DB: dbm.NewMemDB()}
+	store := newEmptyListenKVStore(&recordingListener{})
+	require.Equal(t, memDB.GetStoreType(), store.GetStoreType())
+}
@@ -6,26 +6,32 @@ import (
 
 // Import cosmos-sdk/types/store.go for convenience.
 type (
-	PruningOptions   = types.PruningOptions
-	Store            = types.Store
-	Committer        = types.Committer
-	CommitStore      = types.CommitStore
-	MultiStore       = types.MultiStore
-	CacheMultiStore  = types.CacheMultiStore
-	CommitMultiStore = types.CommitMultiStore
-	KVStore          = types.KVStore
-	KVPair           = types.KVPair
-	Iterator         = types.Iterator
-	CacheKVStore     = types.CacheKVStore
-	CommitKVStore    = types.CommitKVStore
-	CacheWrapper     = types.CacheWrapper
-	CacheWrap        = types.CacheWrap
-	CommitID         = types.CommitID
-	Key              = types.StoreKey
-	Type             = types.StoreType
-	Queryable        = types.Queryable
-	TraceContext     = types.TraceContext
-	Gas              = types.Gas
-	GasMeter         = types.GasMeter
-	GasConfig        = types.GasConfig
+	PruningOptions         = types.PruningOptions
+	Store                  = types.Store
+	Committer              = types.Committer
+	CommitStore            = types.CommitStore
+	MultiStore             = types.MultiStore
+	CacheMultiStore        = types.CacheMultiStore
+	CommitMultiStore       = types.CommitMultiStore
+	KVStore                = types.KVStore
+	KVPair                 = types.KVPair
+	KVPairDelta            = types.KVPairDelta
+	Iterator               = types.Iterator
+	CacheKVStore           = types.CacheKVStore
+	CommitKVStore          = types.CommitKVStore
+	CacheWrapper           = types.CacheWrapper
+	CacheWrap              = types.CacheWrap
+	CommitID               = types.CommitID
+	Key                    = types.StoreKey
+	Type                   = types.StoreType
+	Queryable              = types.Queryable
+	TraceContext           = types.TraceContext
+	WriteListener          = types.WriteListener
+	ReadListener           = types.ReadListener
+	StoreLifecycleListener = types.StoreLifecycleListener
+	StoreLifecycleEvent    = types.StoreLifecycleEvent
+	FrameRecord            = types.FrameRecord
+	Gas                    = types.Gas
+	GasMeter               = types.GasMeter
+	GasConfig              = types.GasConfig
 )
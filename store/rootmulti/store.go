@@ -22,6 +22,7 @@ import (
 	"github.com/cosmos/cosmos-sdk/store/cachemulti"
 	"github.com/cosmos/cosmos-sdk/store/dbadapter"
 	"github.com/cosmos/cosmos-sdk/store/iavl"
+	"github.com/cosmos/cosmos-sdk/store/listenkv"
 	"github.com/cosmos/cosmos-sdk/store/mem"
 	"github.com/cosmos/cosmos-sdk/store/tracekv"
 	"github.com/cosmos/cosmos-sdk/store/transient"
@@ -58,6 +59,14 @@ type Store struct {
 	traceContext types.TraceContext
 
 	interBlockCache types.MultiStorePersistentCache
+
+	listeners map[types.StoreKey][]types.WriteListener
+
+	// pruningGuard returns the highest version which may be pruned, if set
+	pruningGuard func() int64
+
+	// lifecycleListener is notified of the pruned versions and store upgrades
+	lifecycleListener types.StoreLifecycleListener
 }
 
 var (
@@ -77,6 +86,7 @@ func NewStore(db dbm.DB) *Store {
 		stores:       make(map[types.StoreKey]types.CommitKVStore),
 		keysByName:   make(map[string]types.StoreKey),
 		pruneHeights: make([]int64, 0),
+		listeners:    make(map[types.StoreKey][]types.WriteListener),
 	}
 }
 
@@ -92,6 +102,22 @@ func (rs *Store) SetPruning(pruningOpts types.PruningOptions) {
 	rs.pruningOpts = pruningOpts
 }
 
+// SetPruningGuard sets a function returning the highest version which may be
+// pruned, e.g. the last version durably archived by an external consumer.
+// Versions above it which are due for pruning are retained and pruned at the
+// first pruning interval at which the guard allows it. A nil guard allows
+// every version to be pruned.
+func (rs *Store) SetPruningGuard(guard func() int64) {
+	rs.pruningGuard = guard
+}
+
+// SetLifecycleListener sets the listener notified of the versions deleted by
+// pruning and of the stores added, deleted or renamed by store upgrades. It
+// must be set before the store is loaded to be notified of the upgrades.
+func (rs *Store) SetLifecycleListener(listener types.StoreLifecycleListener) {
+	rs.lifecycleListener = listener
+}
+
 // SetLazyLoading sets if the iavl store should be loaded lazily or not
 func (rs *Store) SetLazyLoading(lazyLoading bool) {
 	rs.lazyLoading = lazyLoading
@@ -185,6 +211,7 @@ func (rs *Store) loadVersion(ver int64, upgrades *types.StoreUpgrades) error {
 
 	// load each Store (note this doesn't panic on unmounted keys now)
 	var newStores = make(map[types.StoreKey]types.CommitKVStore)
+	var upgradeEvents []types.StoreLifecycleEvent
 
 	for key, storeParams := range rs.storesParams {
 		commitID := rs.getCommitID(infos, key.Name())
@@ -192,6 +219,7 @@ func (rs *Store) loadVersion(ver int64, upgrades *types.StoreUpgrades) error {
 		// If it has been added, set the initial version
 		if upgrades.IsAdded(key.Name()) {
 			storeParams.initialVersion = uint64(ver) + 1
+			upgradeEvents = append(upgradeEvents, types.StoreLifecycleEvent{Type: types.StoreAdded, Height: ver + 1, StoreKey: key.Name()})
 		}
 
 		store, err := rs.loadCommitStoreFromParams(key, commitID, storeParams)
@@ -206,6 +234,7 @@ func (rs *Store) loadVersion(ver int64, upgrades *types.StoreUpgrades) error {
 			if err := deleteKVStore(store.(types.KVStore)); err != nil {
 				return errors.Wrapf(err, "failed to delete store %s", key.Name())
 			}
+			upgradeEvents = append(upgradeEvents, types.StoreLifecycleEvent{Type: types.StoreDeleted, Height: ver + 1, StoreKey: key.Name()})
 		} else if oldName := upgrades.RenamedFrom(key.Name()); oldName != "" {
 			// handle renames specially
 			// make an unregistered key to satify loadCommitStore params
@@ -223,12 +252,20 @@ func (rs *Store) loadVersion(ver int64, upgrades *types.StoreUpgrades) error {
 			if err := moveKVStoreData(oldStore.(types.KVStore), store.(types.KVStore)); err != nil {
 				return errors.Wrapf(err, "failed to move store %s -> %s", oldName, key.Name())
 			}
+			upgradeEvents = append(upgradeEvents, types.StoreLifecycleEvent{Type: types.StoreRenamed, Height: ver + 1, StoreKey: key.Name(), OldStoreKey: oldName})
 		}
 	}
 
 	rs.lastCommitInfo = cInfo
 	rs.stores = newStores
 
+	if rs.lifecycleListener != nil {
+		sort.Slice(upgradeEvents, func(i, j int) bool { return upgradeEvents[i].StoreKey < upgradeEvents[j].StoreKey })
+		for _, event := range upgradeEvents {
+			rs.lifecycleListener.OnStoreLifecycle(event)
+		}
+	}
+
 	// load any pruned heights we missed from disk to be pruned on the next run
 	ph, err := getPruningHeights(rs.db)
 	if err == nil && len(ph) > 0 {
@@ -312,6 +349,23 @@ func (rs *Store) TracingEnabled() bool {
 	return rs.traceWriter != nil
 }
 
+// AddListeners adds listeners for a specific KVStore
+func (rs *Store) AddListeners(key types.StoreKey, listeners []types.WriteListener) {
+	if ls, ok := rs.listeners[key]; ok {
+		rs.listeners[key] = append(ls, listeners...)
+	} else {
+		rs.listeners[key] = listeners
+	}
+}
+
+// ListeningEnabled returns if listening is enabled for a specific KVStore
+func (rs *Store) ListeningEnabled(key types.StoreKey) bool {
+	if ls, ok := rs.listeners[key]; ok {
+		return len(ls) != 0
+	}
+	return false
+}
+
 // LastCommitID implements Committer/CommitStore.
 func (rs *Store) LastCommitID() types.CommitID {
 	if rs.lastCommitInfo == nil {
@@ -359,7 +413,7 @@ func (rs *Store) Commit() types.CommitID {
 
 	// batch prune if the current height is a pruning interval height
 	if rs.pruningOpts.Interval > 0 && version%int64(rs.pruningOpts.Interval) == 0 {
-		rs.pruneStores()
+		rs.pruneStores(version)
 	}
 
 	flushMetadata(rs.db, version, rs.lastCommitInfo, rs.pruneHeights)
@@ -371,9 +425,25 @@ func (rs *Store) Commit() types.CommitID {
 }
 
 // pruneStores will batch delete a list of heights from each mounted sub-store.
-// Afterwards, pruneHeights is reset.
-func (rs *Store) pruneStores() {
-	if len(rs.pruneHeights) == 0 {
+// Afterwards, pruneHeights is reset to the heights retained by the pruning
+// guard, if any. The lifecycle listener, if any, is notified of the pruned
+// heights as of the given version.
+func (rs *Store) pruneStores(version int64) {
+	pruneHeights, retainedHeights := rs.pruneHeights, make([]int64, 0)
+	if rs.pruningGuard != nil {
+		limit := rs.pruningGuard()
+
+		pruneHeights = make([]int64, 0, len(rs.pruneHeights))
+		for _, height := range rs.pruneHeights {
+			if height <= limit {
+				pruneHeights = append(pruneHeights, height)
+			} else {
+				retainedHeights = append(retainedHeights, height)
+			}
+		}
+	}
+
+	if len(pruneHeights) == 0 {
 		return
 	}
 
@@ -383,7 +453,7 @@ func (rs *Store) pruneStores() {
 			// it to get the underlying IAVL store.
 			store = rs.GetCommitKVStore(key)
 
-			if err := store.(*iavl.Store).DeleteVersions(rs.pruneHeights...); err != nil {
+			if err := store.(*iavl.Store).DeleteVersions(pruneHeights...); err != nil {
 				if errCause := errors.Cause(err); errCause != nil && errCause != iavltree.ErrVersionDoesNotExist {
 					panic(err)
 				}
@@ -391,7 +461,13 @@ func (rs *Store) pruneStores() {
 		}
 	}
 
-	rs.pruneHeights = make([]int64, 0)
+	rs.pruneHeights = retainedHeights
+
+	if rs.lifecycleListener != nil {
+		versions := append([]int64(nil), pruneHeights...)
+		sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+		rs.lifecycleListener.OnStoreLifecycle(types.StoreLifecycleEvent{Type: types.StoreVersionsPruned, Height: version, Versions: versions})
+	}
 }
 
 // CacheWrap implements CacheWrapper/Store/CommitStore.
@@ -412,7 +488,7 @@ func (rs *Store) CacheMultiStore() types.CacheMultiStore {
 		stores[k] = v
 	}
 
-	return cachemulti.NewStore(rs.db, stores, rs.keysByName, rs.traceWriter, rs.traceContext)
+	return cachemulti.NewStore(rs.db, stores, rs.keysByName, rs.traceWriter, rs.traceContext, rs.listeners)
 }
 
 // CacheMultiStoreWithVersion is analogous to CacheMultiStore except that it
@@ -442,7 +518,72 @@ func (rs *Store) CacheMultiStoreWithVersion(version int64) (types.CacheMultiStor
 		}
 	}
 
-	return cachemulti.NewStore(rs.db, cachedStores, rs.keysByName, rs.traceWriter, rs.traceContext), nil
+	return cachemulti.NewStore(rs.db, cachedStores, rs.keysByName, rs.traceWriter, rs.traceContext, nil), nil
+}
+
+// StoreDiff returns the changes made to the IAVL stores mounted under the
+// given keys, or to every IAVL store if none is given, between the given
+// versions. The changes are ordered by store name, then key, and a nil Value
+// signals a deleted key. Version 0 is the empty state. An error is returned if
+// either version does not exist or has been pruned in any of the stores.
+func (rs *Store) StoreDiff(fromVersion, toVersion int64, keys ...types.StoreKey) ([]types.KVPairDelta, error) {
+	diff, err := rs.LoadStoreDiff(fromVersion, toVersion, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff()
+}
+
+// LoadStoreDiff loads the given versions of the IAVL stores mounted under the
+// given keys, or of every IAVL store if none is given, and returns a function
+// computing the changes made to them between these versions, as StoreDiff
+// does. Only loading the versions must be serialized with Commit: the changes
+// are computed from the immutable versions loaded, as long as they are not
+// pruned meanwhile.
+func (rs *Store) LoadStoreDiff(fromVersion, toVersion int64, keys ...types.StoreKey) (func() ([]types.KVPairDelta, error), error) {
+	if len(keys) == 0 {
+		for key, store := range rs.stores {
+			if store.GetStoreType() == types.StoreTypeIAVL {
+				keys = append(keys, key)
+			}
+		}
+	} else {
+		keys = append([]types.StoreKey(nil), keys...)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name() < keys[j].Name() })
+
+	diffs := make([]func() ([]types.KVPairDelta, error), len(keys))
+	for i, key := range keys {
+		store, ok := rs.GetCommitKVStore(key).(*iavl.Store)
+		if !ok {
+			return nil, fmt.Errorf("store %s is not an IAVL store", key.Name())
+		}
+
+		diff, err := store.LoadStoreDiff(fromVersion, toVersion)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to diff store %s", key.Name())
+		}
+
+		diffs[i] = diff
+	}
+
+	return func() ([]types.KVPairDelta, error) {
+		var deltas []types.KVPairDelta
+		for i, key := range keys {
+			storeDeltas, err := diffs[i]()
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to diff store %s", key.Name())
+			}
+
+			for _, delta := range storeDeltas {
+				delta.StoreKey = key
+				deltas = append(deltas, delta)
+			}
+		}
+
+		return deltas, nil
+	}, nil
 }
 
 // GetStore returns a mounted Store for a given StoreKey. If the StoreKey does
@@ -462,7 +603,8 @@ func (rs *Store) GetStore(key types.StoreKey) types.Store {
 
 // GetKVStore returns a mounted KVStore for a given StoreKey. If tracing is
 // enabled on the KVStore, a wrapped TraceKVStore will be returned with the root
-// store's tracer, otherwise, the original KVStore will be returned.
+// store's tracer. If listening is enabled on the KVStore, it is additionally
+// wrapped in a ListenKVStore. Otherwise, the original KVStore will be returned.
 //
 // NOTE: The returned KVStore may be wrapped in an inter-block cache if it is
 // set on the root store.
@@ -472,10 +614,30 @@ func (rs *Store) GetKVStore(key types.StoreKey) types.KVStore {
 	if rs.TracingEnabled() {
 		store = tracekv.NewStore(store, rs.traceWriter, rs.traceContext)
 	}
+	if rs.ListeningEnabled(key) {
+		store = listenkv.NewStore(store, key, rs.listeners[key])
+	}
 
 	return store
 }
 
+// StoreKeyByName returns the key of the store mounted under the given name, or
+// nil if there is none.
+func (rs *Store) StoreKeyByName(name string) types.StoreKey {
+	return rs.keysByName[name]
+}
+
+// StoreKeys returns the keys of the mounted stores, ordered by name.
+func (rs *Store) StoreKeys() []types.StoreKey {
+	keys := make([]types.StoreKey, 0, len(rs.keysByName))
+	for _, key := range rs.keysByName {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name() < keys[j].Name() })
+
+	return keys
+}
+
 // getStoreByName performs a lookup of a StoreKey given a store name typically
 // provided in a path. The StoreKey is then used to perform a lookup and return
 // a Store. If the Store is wrapped in an inter-block cache, it will be unwrapped
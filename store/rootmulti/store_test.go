@@ -1,6 +1,7 @@
 package rootmulti
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
@@ -99,6 +100,42 @@ func TestCacheMultiStoreWithVersion(t *testing.T) {
 	})
 }
 
+func TestStoreDiff(t *testing.T) {
+	var db dbm.DB = dbm.NewMemDB()
+	ms := newMultiStoreWithMixedMounts(db)
+
+	iavl1 := ms.getStoreByName("iavl1").(types.KVStore)
+	iavl2 := ms.getStoreByName("iavl2").(types.KVStore)
+
+	iavl1.Set([]byte("a"), []byte("1"))
+	iavl2.Set([]byte("b"), []byte("2"))
+	ms.Commit()
+
+	iavl1.Delete([]byte("a"))
+	iavl2.Set([]byte("b"), []byte("3"))
+	cID := ms.Commit()
+
+	deltas, err := ms.StoreDiff(cID.Version-1, cID.Version)
+	require.NoError(t, err)
+	require.Equal(t, []types.KVPairDelta{
+		{StoreKey: ms.keysByName["iavl1"], Key: []byte("a")},
+		{StoreKey: ms.keysByName["iavl2"], Key: []byte("b"), Value: []byte("3")},
+	}, deltas)
+
+	// the diff can be restricted to some stores
+	deltas, err = ms.StoreDiff(0, cID.Version-1, ms.keysByName["iavl2"])
+	require.NoError(t, err)
+	require.Equal(t, []types.KVPairDelta{
+		{StoreKey: ms.keysByName["iavl2"], Key: []byte("b"), Value: []byte("2")},
+	}, deltas)
+
+	_, err = ms.StoreDiff(cID.Version, cID.Version+1)
+	require.True(t, types.ErrVersionNotFound.Is(err))
+
+	_, err = ms.StoreDiff(0, cID.Version, ms.keysByName["trans1"])
+	require.Error(t, err)
+}
+
 func TestHashStableWithEmptyCommit(t *testing.T) {
 	var db dbm.DB = dbm.NewMemDB()
 	ms := newMultiStoreWithMounts(db, types.PruneNothing)
@@ -505,6 +542,34 @@ func TestMultiStore_Pruning(t *testing.T) {
 	}
 }
 
+func TestMultiStore_PruningGuard(t *testing.T) {
+	db := dbm.NewMemDB()
+	ms := newMultiStoreWithMounts(db, types.NewPruningOptions(0, 0, 1))
+	require.NoError(t, ms.LoadLatestVersion())
+
+	limit := int64(2)
+	ms.SetPruningGuard(func() int64 { return limit })
+
+	for i := 0; i < 5; i++ {
+		ms.Commit()
+	}
+
+	store1 := ms.GetCommitKVStore(ms.keysByName["store1"]).(*iavl.Store)
+	for v, exists := range map[int64]bool{1: false, 2: false, 3: true, 4: true, 5: true} {
+		require.Equal(t, exists, store1.VersionExists(v), "version %d", v)
+	}
+	require.Equal(t, []int64{3, 4}, ms.pruneHeights)
+
+	// the retained versions are pruned once the guard allows it
+	limit = 4
+	ms.Commit()
+
+	for v, exists := range map[int64]bool{3: false, 4: false, 5: true, 6: true} {
+		require.Equal(t, exists, store1.VersionExists(v), "version %d", v)
+	}
+	require.Equal(t, []int64{5}, ms.pruneHeights)
+}
+
 func TestMultiStore_PruningRestart(t *testing.T) {
 	db := dbm.NewMemDB()
 	ms := newMultiStoreWithMounts(db, types.NewPruningOptions(2, 3, 11))
@@ -671,6 +736,127 @@ func TestSetInitialVersion(t *testing.T) {
 	require.True(t, iavlStore.VersionExists(5))
 }
 
+type testWrite struct {
+	storeKey types.StoreKey
+	key      []byte
+	value    []byte
+}
+
+type testWriteListener struct {
+	writes []testWrite
+}
+
+func (l *testWriteListener) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	l.writes = append(l.writes, testWrite{storeKey: storeKey, key: key, value: value})
+}
+
+func TestMultiStoreListening(t *testing.T) {
+	db := dbm.NewMemDB()
+	multi := newMultiStoreWithMounts(db, types.PruneNothing)
+	key1 := multi.keysByName["store1"]
+	key2 := multi.keysByName["store2"]
+
+	listener := &testWriteListener{}
+	multi.AddListeners(key1, []types.WriteListener{listener})
+	require.NoError(t, multi.LoadLatestVersion())
+
+	require.True(t, multi.ListeningEnabled(key1))
+	require.False(t, multi.ListeningEnabled(key2))
+
+	k, v := []byte("key"), []byte("value")
+
+	// writes to a cache are only passed to the listeners once written
+	cacheMulti := multi.CacheMultiStore()
+	require.True(t, cacheMulti.ListeningEnabled(key1))
+
+	nested := cacheMulti.CacheMultiStore()
+	require.False(t, nested.ListeningEnabled(key1))
+
+	nested.GetKVStore(key1).Set(k, v)
+	nested.GetKVStore(key2).Set(k, v)
+	nested.Write()
+	require.Empty(t, listener.writes)
+
+	cacheMulti.Write()
+	require.Equal(t, []testWrite{{storeKey: key1, key: k, value: v}}, listener.writes)
+
+	// writes directly against the root store are passed to the listeners
+	listener.writes = nil
+	multi.GetKVStore(key1).Delete(k)
+	require.Equal(t, []testWrite{{storeKey: key1, key: k, value: nil}}, listener.writes)
+
+	// versioned caches used for queries are never listened to
+	multi.Commit()
+	versioned, err := multi.CacheMultiStoreWithVersion(1)
+	require.NoError(t, err)
+	require.False(t, versioned.ListeningEnabled(key1))
+}
+
+func TestMultiStoreListeningWithTracing(t *testing.T) {
+	db := dbm.NewMemDB()
+	multi := newMultiStoreWithMounts(db, types.PruneNothing)
+	key1 := multi.keysByName["store1"]
+
+	var buf bytes.Buffer
+	multi.SetTracer(&buf)
+
+	listener := &testWriteListener{}
+	multi.AddListeners(key1, []types.WriteListener{listener})
+	require.NoError(t, multi.LoadLatestVersion())
+
+	// listened stores are still traced with their tracekv wrapper
+	store := multi.GetKVStore(key1)
+	store.Set([]byte("key"), []byte("value"))
+
+	iterator := store.Iterator(nil, nil)
+	require.Equal(t, []byte("key"), iterator.Key())
+	require.Equal(t, []byte("value"), iterator.Value())
+	require.NoError(t, iterator.Close())
+
+	require.Equal(t, []testWrite{{storeKey: key1, key: []byte("key"), value: []byte("value")}}, listener.writes)
+	require.Contains(t, buf.String(), `"operation":"write"`)
+	require.Contains(t, buf.String(), `"operation":"iterKey"`)
+	require.Contains(t, buf.String(), `"operation":"iterValue"`)
+}
+
+type testLifecycleListener struct {
+	events []types.StoreLifecycleEvent
+}
+
+func (l *testLifecycleListener) OnStoreLifecycle(event types.StoreLifecycleEvent) {
+	l.events = append(l.events, event)
+}
+
+func TestMultiStoreLifecycleEvents(t *testing.T) {
+	db := dbm.NewMemDB()
+	ms := newMultiStoreWithMounts(db, types.NewPruningOptions(0, 0, 2))
+	require.NoError(t, ms.LoadLatestVersion())
+
+	listener := &testLifecycleListener{}
+	ms.SetLifecycleListener(listener)
+
+	for i := 0; i < 4; i++ {
+		ms.Commit()
+	}
+
+	require.Equal(t, []types.StoreLifecycleEvent{
+		{Type: types.StoreVersionsPruned, Height: 2, Versions: []int64{1}},
+		{Type: types.StoreVersionsPruned, Height: 4, Versions: []int64{2, 3}},
+	}, listener.events)
+
+	// the upgrades are reported as of the first height after them
+	listener.events = nil
+	restore, upgrades := newMultiStoreWithModifiedMounts(db, types.PruneNothing)
+	restore.SetLifecycleListener(listener)
+	require.NoError(t, restore.LoadLatestVersionAndUpgrade(upgrades))
+
+	require.Equal(t, []types.StoreLifecycleEvent{
+		{Type: types.StoreRenamed, Height: 5, StoreKey: "restore2", OldStoreKey: "store2"},
+		{Type: types.StoreDeleted, Height: 5, StoreKey: "store3"},
+		{Type: types.StoreAdded, Height: 5, StoreKey: "store4"},
+	}, listener.events)
+}
+
 func BenchmarkMultistoreSnapshot100K(b *testing.B) {
 	benchmarkMultistoreSnapshot(b, 10, 10000)
 }
@@ -0,0 +1,44 @@
+package tracekv
+
+import (
+	"io"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+var (
+	_ types.WriteListener = (*Listener)(nil)
+	_ types.ReadListener  = (*Listener)(nil)
+)
+
+// Listener traces the operations passed to it by a listenkv.Store in the same
+// JSON format as a Store, so streaming consumers can be fed the trace format
+// through listeners. It does not replace a Store: iterated keys are not
+// traced and values read through iterators are traced as read operations,
+// so stores traced with the --trace-store flag keep their Store wrapper.
+type Listener struct {
+	writer  io.Writer
+	context types.TraceContext
+}
+
+// NewListener returns a reference to a new Listener given a writer and a
+// trace context.
+func NewListener(writer io.Writer, tc types.TraceContext) *Listener {
+	return &Listener{writer: writer, context: tc}
+}
+
+// OnWrite implements the WriteListener interface. It traces a write or
+// delete operation.
+func (l *Listener) OnWrite(_ types.StoreKey, key []byte, value []byte) {
+	if value == nil {
+		writeOperation(l.writer, deleteOp, l.context, key, nil)
+		return
+	}
+
+	writeOperation(l.writer, writeOp, l.context, key, value)
+}
+
+// OnRead implements the ReadListener interface. It traces a read operation.
+func (l *Listener) OnRead(_ types.StoreKey, key []byte, value []byte) {
+	writeOperation(l.writer, readOp, l.context, key, value)
+}
@@ -0,0 +1,45 @@
+package tracekv_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/tracekv"
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+func TestListener(t *testing.T) {
+	storeKey := types.NewKVStoreKey("trace_test")
+	traceContext := types.TraceContext(map[string]interface{}{"blockHeight": 64})
+
+	testCases := []struct {
+		name        string
+		notify      func(l *tracekv.Listener)
+		expectedOut string
+	}{
+		{
+			name:        "write",
+			notify:      func(l *tracekv.Listener) { l.OnWrite(storeKey, kvPairs[0].Key, kvPairs[0].Value) },
+			expectedOut: "{\"operation\":\"write\",\"key\":\"a2V5MDAwMDAwMDE=\",\"value\":\"dmFsdWUwMDAwMDAwMQ==\",\"metadata\":{\"blockHeight\":64}}\n",
+		},
+		{
+			name:        "delete",
+			notify:      func(l *tracekv.Listener) { l.OnWrite(storeKey, kvPairs[0].Key, nil) },
+			expectedOut: "{\"operation\":\"delete\",\"key\":\"a2V5MDAwMDAwMDE=\",\"value\":\"\",\"metadata\":{\"blockHeight\":64}}\n",
+		},
+		{
+			name:        "read",
+			notify:      func(l *tracekv.Listener) { l.OnRead(storeKey, kvPairs[0].Key, kvPairs[0].Value) },
+			expectedOut: "{\"operation\":\"read\",\"key\":\"a2V5MDAwMDAwMDE=\",\"value\":\"dmFsdWUwMDAwMDAwMQ==\",\"metadata\":{\"blockHeight\":64}}\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+
+		tc.notify(tracekv.NewListener(&buf, traceContext))
+		require.Equal(t, tc.expectedOut, buf.String(), tc.name)
+	}
+}
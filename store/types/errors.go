@@ -8,4 +8,10 @@ const StoreCodespace = "store"
 
 var (
 	ErrInvalidProof = sdkerrors.Register(StoreCodespace, 2, "invalid proof")
+
+	ErrFrameTruncated         = sdkerrors.Register(StoreCodespace, 3, "truncated frame")
+	ErrUnsupportedFrameFormat = sdkerrors.Register(StoreCodespace, 4, "unsupported frame format")
+	ErrFrameTooLarge          = sdkerrors.Register(StoreCodespace, 5, "frame too large")
+
+	ErrVersionNotFound = sdkerrors.Register(StoreCodespace, 6, "version not found")
 )
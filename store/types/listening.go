@@ -0,0 +1,305 @@
+package types
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// WriteListener interface for streaming data out from a listenkv.Store
+type WriteListener interface {
+	// OnWrite is called for every write performed against a listened store.
+	// If value is nil then the key was deleted. The storeKey indicates the
+	// source KVStore, which allows the same WriteListener to be used across
+	// separate KVStores.
+	OnWrite(storeKey StoreKey, key []byte, value []byte)
+}
+
+// ReadListener is implemented by WriteListeners which are also notified of
+// the reads performed against a listened store.
+type ReadListener interface {
+	// OnRead is called for every value read from a listened store, through
+	// Get or an iterator. A nil value means the key was not found.
+	OnRead(storeKey StoreKey, key []byte, value []byte)
+}
+
+// StoreLifecycleEventType is the kind of a StoreLifecycleEvent.
+type StoreLifecycleEventType string
+
+const (
+	// StoreVersionsPruned is the type of the events reporting versions of
+	// every IAVL store deleted by pruning.
+	StoreVersionsPruned StoreLifecycleEventType = "versions_pruned"
+	// StoreAdded is the type of the events reporting a store added by a
+	// store upgrade.
+	StoreAdded StoreLifecycleEventType = "store_added"
+	// StoreDeleted is the type of the events reporting a store whose data was
+	// deleted by a store upgrade.
+	StoreDeleted StoreLifecycleEventType = "store_deleted"
+	// StoreRenamed is the type of the events reporting a store whose data was
+	// moved from another store by a store upgrade.
+	StoreRenamed StoreLifecycleEventType = "store_renamed"
+)
+
+// StoreLifecycleEvent reports a change of the data available from a
+// multi-store other than a write, so that archival consumers know which
+// historical data the node can still serve and where it came from.
+type StoreLifecycleEvent struct {
+	Type StoreLifecycleEventType `json:"type"`
+	// Height is the height of the commit which pruned the versions, or the
+	// first height of the stores after the upgrade.
+	Height int64 `json:"height"`
+	// StoreKey is the name of the upgraded store, empty for pruned versions
+	// which apply to every IAVL store.
+	StoreKey string `json:"store_key,omitempty"`
+	// OldStoreKey is the name of the store a renamed store was moved from.
+	OldStoreKey string `json:"old_store_key,omitempty"`
+	// Versions are the pruned versions, in ascending order.
+	Versions []int64 `json:"versions,omitempty"`
+}
+
+// StoreLifecycleListener is notified of the StoreLifecycleEvents of a
+// multi-store.
+type StoreLifecycleListener interface {
+	OnStoreLifecycle(event StoreLifecycleEvent)
+}
+
+const (
+	// FrameFormatV1 is the version byte of the frame format in which each
+	// write is encoded as the uvarint length prefixed store key name, key and
+	// value. Deletes can't be told apart from writes of empty values.
+	FrameFormatV1 byte = 1
+	// FrameFormatV2 is the version byte of the frame format in which each
+	// write is encoded as a FrameOp byte followed by the uvarint length
+	// prefixed store key name and key, and the value for FrameOpSet.
+	FrameFormatV2 byte = 2
+)
+
+// FrameOp is the operation of a write encoded in the FrameFormatV2 format.
+type FrameOp byte
+
+const (
+	FrameOpSet    FrameOp = 0
+	FrameOpDelete FrameOp = 1
+	// FrameOpMetadata frames hold the uvarint length prefixed metadata the
+	// stream is stamped with, such as the chain it was streamed from. They
+	// are written at the start of the stream and are not writes.
+	FrameOpMetadata FrameOp = 2
+)
+
+// MaxFrameFieldLength is the maximum length of a field accepted by a
+// FrameReader, guarding against allocating for corrupted length prefixes.
+const MaxFrameFieldLength = 1 << 30
+
+var _ WriteListener = (*FrameWriteListener)(nil)
+
+// FrameWriteListener is a WriteListener which encodes every write it is
+// passed as a self-delimiting frame to an io.Writer. The stream starts with a
+// single format version byte, after which each frame is built in memory and
+// written with a single call to Write. Streams are written in the
+// FrameFormatV2 format.
+//
+// FrameWriteListener is safe for concurrent use: concurrent writes are
+// serialized so their frames never interleave, provided the underlying
+// io.Writer is not written to by anything else.
+type FrameWriteListener struct {
+	mtx           sync.Mutex
+	writer        io.Writer
+	buf           []byte
+	metadata      []byte
+	headerWritten bool
+	err           error
+}
+
+// NewFrameWriteListener returns a FrameWriteListener writing to w.
+func NewFrameWriteListener(w io.Writer) *FrameWriteListener {
+	return &FrameWriteListener{writer: w}
+}
+
+// SetMetadata sets the metadata written in a FrameOpMetadata frame at the
+// start of the stream, read back by FrameReader.Metadata. It must be set
+// before the first write, and empty metadata is not written.
+func (l *FrameWriteListener) SetMetadata(metadata []byte) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.metadata = metadata
+}
+
+// OnWrite implements the WriteListener interface. Once a Write fails every
+// subsequent write is dropped and the error is returned from Err.
+func (l *FrameWriteListener) OnWrite(storeKey StoreKey, key []byte, value []byte) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.err != nil {
+		return
+	}
+
+	l.buf = l.buf[:0]
+	if !l.headerWritten {
+		l.buf = append(l.buf, FrameFormatV2)
+		if len(l.metadata) > 0 {
+			l.buf = append(l.buf, byte(FrameOpMetadata))
+			l.buf = appendFrameField(l.buf, l.metadata)
+		}
+	}
+
+	op := FrameOpSet
+	if value == nil {
+		op = FrameOpDelete
+	}
+
+	l.buf = append(l.buf, byte(op))
+	l.buf = appendFrameField(l.buf, []byte(storeKey.Name()))
+	l.buf = appendFrameField(l.buf, key)
+	if op == FrameOpSet {
+		l.buf = appendFrameField(l.buf, value)
+	}
+
+	if _, err := l.writer.Write(l.buf); err != nil {
+		l.err = err
+		return
+	}
+
+	l.headerWritten = true
+}
+
+// Err returns the first error encountered writing a frame.
+func (l *FrameWriteListener) Err() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.err
+}
+
+// appendFrameField appends the uvarint length prefixed field to buf.
+func appendFrameField(buf []byte, field []byte) []byte {
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(field)))
+
+	buf = append(buf, lenBuf[:n]...)
+	return append(buf, field...)
+}
+
+// FrameRecord is a single write decoded from a frame stream. Delete is never
+// set for FrameFormatV1 streams.
+type FrameRecord struct {
+	StoreKey string `json:"store_key"`
+	Key      []byte `json:"key"`
+	Value    []byte `json:"value,omitempty"`
+	Delete   bool   `json:"delete"`
+}
+
+// FrameReader decodes the writes encoded by a FrameWriteListener from an
+// io.Reader, in either frame format.
+type FrameReader struct {
+	reader   *bufio.Reader
+	version  byte
+	metadata []byte
+}
+
+// NewFrameReader returns a FrameReader reading from r.
+func NewFrameReader(r io.Reader) *FrameReader {
+	return &FrameReader{reader: bufio.NewReader(r)}
+}
+
+// Metadata returns the metadata the stream is stamped with, once read by
+// Next, or nil if it has none.
+func (r *FrameReader) Metadata() []byte {
+	return r.metadata
+}
+
+// Next decodes the next record, skipping the FrameOpMetadata frames. It
+// returns io.EOF once the stream ends on a frame boundary and
+// ErrFrameTruncated if it ends in the middle of a frame.
+func (r *FrameReader) Next() (FrameRecord, error) {
+	if r.version == 0 {
+		version, err := r.reader.ReadByte()
+		if err != nil {
+			return FrameRecord{}, err
+		}
+
+		if version != FrameFormatV1 && version != FrameFormatV2 {
+			return FrameRecord{}, sdkerrors.Wrapf(ErrUnsupportedFrameFormat, "version %d", version)
+		}
+
+		r.version = version
+	}
+
+	op := FrameOpSet
+	if r.version == FrameFormatV2 {
+		b, err := r.reader.ReadByte()
+		if err != nil {
+			return FrameRecord{}, err
+		}
+
+		op = FrameOp(b)
+		if op == FrameOpMetadata {
+			if r.metadata, err = r.readField(); err != nil {
+				return FrameRecord{}, truncated(err)
+			}
+
+			return r.Next()
+		}
+
+		if op != FrameOpSet && op != FrameOpDelete {
+			return FrameRecord{}, sdkerrors.Wrapf(ErrUnsupportedFrameFormat, "operation %d", op)
+		}
+	}
+
+	storeKey, err := r.readField()
+	if err == io.EOF && r.version == FrameFormatV1 {
+		return FrameRecord{}, io.EOF
+	} else if err != nil {
+		return FrameRecord{}, truncated(err)
+	}
+
+	key, err := r.readField()
+	if err != nil {
+		return FrameRecord{}, truncated(err)
+	}
+
+	record := FrameRecord{StoreKey: string(storeKey), Key: key, Delete: op == FrameOpDelete}
+	if op == FrameOpSet {
+		if record.Value, err = r.readField(); err != nil {
+			return FrameRecord{}, truncated(err)
+		}
+	}
+
+	return record, nil
+}
+
+// readField reads a uvarint length prefixed field. It returns io.EOF only if
+// the stream ends before the field starts.
+func (r *FrameReader) readField() ([]byte, error) {
+	n, err := binary.ReadUvarint(r.reader)
+	if err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, truncated(err)
+	}
+
+	if n > MaxFrameFieldLength {
+		return nil, sdkerrors.Wrapf(ErrFrameTooLarge, "field of %d bytes", n)
+	}
+
+	field := make([]byte, n)
+	if _, err := io.ReadFull(r.reader, field); err != nil {
+		return nil, truncated(err)
+	}
+
+	return field, nil
+}
+
+// truncated converts an unexpected end of the stream into ErrFrameTruncated.
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrFrameTruncated
+	}
+
+	return err
+}
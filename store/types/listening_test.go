@@ -0,0 +1,203 @@
+package types_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// countingWriter records every call to Write.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+func TestFrameWriteListener(t *testing.T) {
+	key := types.NewKVStoreKey("acc")
+	w := &countingWriter{}
+	l := types.NewFrameWriteListener(w)
+
+	l.OnWrite(key, []byte("k1"), []byte("value1"))
+	l.OnWrite(key, []byte("k2"), nil)
+	require.NoError(t, l.Err())
+	require.Equal(t, 2, w.writes, "each frame should be written at once")
+
+	expected := []byte{types.FrameFormatV2}
+	expected = append(expected, byte(types.FrameOpSet), 3, 'a', 'c', 'c', 2, 'k', '1', 6, 'v', 'a', 'l', 'u', 'e', '1')
+	expected = append(expected, byte(types.FrameOpDelete), 3, 'a', 'c', 'c', 2, 'k', '2')
+	require.Equal(t, expected, w.Bytes())
+
+	l = types.NewFrameWriteListener(failingWriter{})
+	l.OnWrite(key, []byte("k1"), []byte("value1"))
+	require.Error(t, l.Err())
+}
+
+func TestFrameWriteListenerConcurrentWrites(t *testing.T) {
+	const writers, writesPerWriter = 8, 200
+
+	w := &countingWriter{}
+	l := types.NewFrameWriteListener(w)
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			key := types.NewKVStoreKey(fmt.Sprintf("store%d", i))
+			for j := 0; j < writesPerWriter; j++ {
+				value := make([]byte, rand.Intn(512))
+				rand.Read(value)
+
+				l.OnWrite(key, []byte(fmt.Sprintf("%d/%d", i, j)), value)
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.NoError(t, l.Err())
+
+	// every frame must be intact and each writer's frames in order
+	r := types.NewFrameReader(bytes.NewReader(w.Bytes()))
+	next := make(map[string]int)
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		var i, j int
+		_, err = fmt.Sscanf(string(record.Key), "%d/%d", &i, &j)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("store%d", i), record.StoreKey)
+		require.Equal(t, next[record.StoreKey], j)
+
+		next[record.StoreKey]++
+	}
+
+	require.Len(t, next, writers)
+	for _, n := range next {
+		require.Equal(t, writesPerWriter, n)
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+	key := types.NewKVStoreKey("acc")
+	w := &countingWriter{}
+	l := types.NewFrameWriteListener(w)
+
+	l.OnWrite(key, []byte("k1"), []byte("value1"))
+	l.OnWrite(key, []byte("k2"), []byte{})
+	l.OnWrite(key, []byte("k3"), nil)
+	stream := w.Bytes()
+
+	r := types.NewFrameReader(bytes.NewReader(stream))
+
+	record, err := r.Next()
+	require.NoError(t, err)
+	require.Equal(t, types.FrameRecord{StoreKey: "acc", Key: []byte("k1"), Value: []byte("value1")}, record)
+
+	record, err = r.Next()
+	require.NoError(t, err)
+	require.Equal(t, types.FrameRecord{StoreKey: "acc", Key: []byte("k2"), Value: []byte{}}, record)
+
+	// deletes are told apart from empty values
+	record, err = r.Next()
+	require.NoError(t, err)
+	require.Equal(t, types.FrameRecord{StoreKey: "acc", Key: []byte("k3"), Delete: true}, record)
+
+	_, err = r.Next()
+	require.Equal(t, io.EOF, err)
+
+	// an empty stream ends cleanly
+	_, err = types.NewFrameReader(bytes.NewReader(nil)).Next()
+	require.Equal(t, io.EOF, err)
+
+	// every cut inside the first frame is reported as truncated
+	const firstFrameEnd = 16
+	for i := 2; i < firstFrameEnd; i++ {
+		_, err = types.NewFrameReader(bytes.NewReader(stream[:i])).Next()
+		require.True(t, types.ErrFrameTruncated.Is(err), "cut at %d: %v", i, err)
+	}
+
+	_, err = types.NewFrameReader(bytes.NewReader([]byte{0xff})).Next()
+	require.True(t, types.ErrUnsupportedFrameFormat.Is(err))
+
+	_, err = types.NewFrameReader(bytes.NewReader([]byte{types.FrameFormatV2, 0xff})).Next()
+	require.True(t, types.ErrUnsupportedFrameFormat.Is(err))
+
+	_, err = types.NewFrameReader(bytes.NewReader([]byte{types.FrameFormatV1, 0xff, 0xff, 0xff, 0xff, 0x7f})).Next()
+	require.True(t, types.ErrFrameTooLarge.Is(err))
+}
+
+func TestFrameMetadata(t *testing.T) {
+	key := types.NewKVStoreKey("acc")
+	w := &countingWriter{}
+	l := types.NewFrameWriteListener(w)
+	l.SetMetadata([]byte(`{"chain_id":"test"}`))
+
+	l.OnWrite(key, []byte("k1"), []byte("value1"))
+	l.OnWrite(key, []byte("k2"), nil)
+	require.NoError(t, l.Err())
+	require.Equal(t, 2, w.writes)
+
+	// the metadata is only written at the start of the stream
+	expected := []byte{types.FrameFormatV2, byte(types.FrameOpMetadata), 19}
+	expected = append(expected, `{"chain_id":"test"}`...)
+	expected = append(expected, byte(types.FrameOpSet), 3, 'a', 'c', 'c', 2, 'k', '1', 6, 'v', 'a', 'l', 'u', 'e', '1')
+	expected = append(expected, byte(types.FrameOpDelete), 3, 'a', 'c', 'c', 2, 'k', '2')
+	require.Equal(t, expected, w.Bytes())
+
+	r := types.NewFrameReader(bytes.NewReader(w.Bytes()))
+	require.Nil(t, r.Metadata())
+
+	record, err := r.Next()
+	require.NoError(t, err)
+	require.Equal(t, types.FrameRecord{StoreKey: "acc", Key: []byte("k1"), Value: []byte("value1")}, record)
+	require.Equal(t, []byte(`{"chain_id":"test"}`), r.Metadata())
+
+	record, err = r.Next()
+	require.NoError(t, err)
+	require.Equal(t, types.FrameRecord{StoreKey: "acc", Key: []byte("k2"), Delete: true}, record)
+
+	_, err = r.Next()
+	require.Equal(t, io.EOF, err)
+
+	// a truncated metadata frame is reported as such
+	_, err = types.NewFrameReader(bytes.NewReader(w.Bytes()[:10])).Next()
+	require.True(t, types.ErrFrameTruncated.Is(err))
+}
+
+func TestFrameReaderV1(t *testing.T) {
+	stream := []byte{types.FrameFormatV1, 3, 'a', 'c', 'c', 2, 'k', '1', 1, 'v', 3, 'a', 'c', 'c', 2, 'k', '2', 0}
+	r := types.NewFrameReader(bytes.NewReader(stream))
+
+	record, err := r.Next()
+	require.NoError(t, err)
+	require.Equal(t, types.FrameRecord{StoreKey: "acc", Key: []byte("k1"), Value: []byte("v")}, record)
+
+	record, err = r.Next()
+	require.NoError(t, err)
+	require.Equal(t, types.FrameRecord{StoreKey: "acc", Key: []byte("k2"), Value: []byte{}}, record)
+
+	_, err = r.Next()
+	require.Equal(t, io.EOF, err)
+}
@@ -130,6 +130,10 @@ type MultiStore interface {
 	// implied that the caller should update the context when necessary between
 	// tracing operations. The modified MultiStore is returned.
 	SetTracingContext(TraceContext) MultiStore
+
+	// ListeningEnabled returns if listening is enabled for the KVStore belonging
+	// to the provided StoreKey.
+	ListeningEnabled(key StoreKey) bool
 }
 
 // From MultiStore.CacheMultiStore()....
@@ -181,6 +185,10 @@ type CommitMultiStore interface {
 	// SetInitialVersion sets the initial version of the IAVL tree. It is used when
 	// starting a new chain at an arbitrary height.
 	SetInitialVersion(version int64) error
+
+	// AddListeners adds WriteListeners for the KVStore belonging to the provided
+	// StoreKey. It appends to any listeners that are already set.
+	AddListeners(key StoreKey, listeners []WriteListener)
 }
 
 //---------subsp-------------------------------
@@ -386,11 +394,30 @@ func (key *MemoryStoreKey) String() string {
 	return fmt.Sprintf("MemoryStoreKey{%p, %s}", key, key.name)
 }
 
+// IsEphemeralStoreKey returns true if the key is the key of a store whose
+// state is not persisted, i.e. a transient or memory store.
+func IsEphemeralStoreKey(key StoreKey) bool {
+	switch key.(type) {
+	case *TransientStoreKey, *MemoryStoreKey:
+		return true
+	default:
+		return false
+	}
+}
+
 //----------------------------------------
 
 // key-value result for iterator queries
 type KVPair kv.Pair
 
+// KVPairDelta is the change of a single key of a store between two versions.
+// A nil Value signals that the key was deleted.
+type KVPairDelta struct {
+	StoreKey StoreKey
+	Key      []byte
+	Value    []byte
+}
+
 //----------------------------------------
 
 // TraceContext contains TraceKVStore context data. It will be written with
@@ -0,0 +1,131 @@
+// Package streaming provides in-memory WriteListeners to test the streaming
+// of state changes end-to-end.
+package streaming
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+var (
+	_ types.WriteListener = (*MemoryListener)(nil)
+	_ types.WriteListener = (*FrameSink)(nil)
+)
+
+// StoreWrite is a single write recorded by a MemoryListener. A nil Value
+// signals that the key was deleted.
+type StoreWrite struct {
+	StoreKey types.StoreKey
+	Key      []byte
+	Value    []byte
+}
+
+// MemoryListener is a WriteListener recording every write it is passed in
+// memory, in order. It is safe for concurrent use.
+type MemoryListener struct {
+	mtx    sync.Mutex
+	writes []StoreWrite
+}
+
+// NewMemoryListener returns a new empty MemoryListener.
+func NewMemoryListener() *MemoryListener {
+	return &MemoryListener{}
+}
+
+// OnWrite implements the WriteListener interface.
+func (l *MemoryListener) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	write := StoreWrite{StoreKey: storeKey, Key: append([]byte{}, key...)}
+	if value != nil {
+		write.Value = append([]byte{}, value...)
+	}
+
+	l.writes = append(l.writes, write)
+}
+
+// Writes returns the recorded writes in the order they were passed to the
+// listener.
+func (l *MemoryListener) Writes() []StoreWrite {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return append([]StoreWrite{}, l.writes...)
+}
+
+// Get returns the last value written to the key of the given store, and
+// whether the key was written at all. A nil value is returned for deletes.
+func (l *MemoryListener) Get(storeKey types.StoreKey, key []byte) ([]byte, bool) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	for i := len(l.writes) - 1; i >= 0; i-- {
+		if l.writes[i].StoreKey == storeKey && bytes.Equal(l.writes[i].Key, key) {
+			return l.writes[i].Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// Reset discards the recorded writes.
+func (l *MemoryListener) Reset() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.writes = nil
+}
+
+// FrameSink is a WriteListener encoding every write it is passed in the
+// frame format of FrameWriteListener to memory, to assert on the emitted
+// frames. It is safe for concurrent use.
+type FrameSink struct {
+	mtx      sync.Mutex
+	buf      bytes.Buffer
+	listener *types.FrameWriteListener
+}
+
+// NewFrameSink returns a new empty FrameSink.
+func NewFrameSink() *FrameSink {
+	s := &FrameSink{}
+	s.listener = types.NewFrameWriteListener(&s.buf)
+
+	return s
+}
+
+// OnWrite implements the WriteListener interface.
+func (s *FrameSink) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.listener.OnWrite(storeKey, key, value)
+}
+
+// Bytes returns the encoded stream.
+func (s *FrameSink) Bytes() []byte {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return append([]byte{}, s.buf.Bytes()...)
+}
+
+// Records decodes the encoded stream.
+func (s *FrameSink) Records() ([]types.FrameRecord, error) {
+	r := types.NewFrameReader(bytes.NewReader(s.Bytes()))
+
+	var records []types.FrameRecord
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+}
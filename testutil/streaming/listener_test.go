@@ -0,0 +1,85 @@
+package streaming_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/rootmulti"
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/testutil/streaming"
+)
+
+func TestMemoryListener(t *testing.T) {
+	key := types.NewKVStoreKey("acc")
+	l := streaming.NewMemoryListener()
+
+	k, v := []byte("key"), []byte("value")
+	l.OnWrite(key, k, v)
+	l.OnWrite(key, []byte("other"), nil)
+
+	// the written slices are copied
+	v[0] = 'V'
+
+	value, ok := l.Get(key, k)
+	require.True(t, ok)
+	require.Equal(t, []byte("value"), value)
+
+	value, ok = l.Get(key, []byte("other"))
+	require.True(t, ok)
+	require.Nil(t, value)
+
+	_, ok = l.Get(types.NewKVStoreKey("bank"), k)
+	require.False(t, ok)
+
+	require.Len(t, l.Writes(), 2)
+	l.Reset()
+	require.Empty(t, l.Writes())
+}
+
+func TestFrameSink(t *testing.T) {
+	key := types.NewKVStoreKey("acc")
+	s := streaming.NewFrameSink()
+
+	s.OnWrite(key, []byte("key"), []byte("value"))
+	s.OnWrite(key, []byte("key"), nil)
+
+	records, err := s.Records()
+	require.NoError(t, err)
+	require.Equal(t, []types.FrameRecord{
+		{StoreKey: "acc", Key: []byte("key"), Value: []byte("value")},
+		{StoreKey: "acc", Key: []byte("key"), Delete: true},
+	}, records)
+}
+
+func TestStateSink(t *testing.T) {
+	key := types.NewKVStoreKey("acc")
+	ms := rootmulti.NewStore(dbm.NewMemDB())
+	ms.MountStoreWithDB(key, types.StoreTypeIAVL, nil)
+
+	s := streaming.NewStateSink()
+	ms.AddListeners(key, []types.WriteListener{s})
+	require.NoError(t, ms.LoadLatestVersion())
+	s.VerifyOnCommit(ms)
+
+	store := ms.GetKVStore(key)
+	store.Set([]byte("a"), []byte("1"))
+	store.Set([]byte("b"), []byte("2"))
+	store.Delete([]byte("a"))
+	ms.Commit()
+	s.OnCommit(1)
+	require.NoError(t, s.Err())
+	require.NoError(t, s.Verify(key, store))
+
+	// a write missing from the stream is caught
+	ms.GetCommitKVStore(key).Set([]byte("c"), []byte("3"))
+	require.EqualError(t, s.Verify(key, store), "store acc: key 63 was never streamed")
+
+	// so is a stale write streamed again
+	s.OnWrite(key, []byte("c"), []byte("3"))
+	s.OnWrite(key, []byte("b"), []byte("1"))
+	ms.Commit()
+	s.OnCommit(2)
+	require.EqualError(t, s.Err(), "block 2: store acc: streamed value 31 of key 62 differs from the stored value 32")
+}
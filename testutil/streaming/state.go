@@ -0,0 +1,127 @@
+package streaming
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+var _ types.WriteListener = (*StateSink)(nil)
+
+// StateSink is a WriteListener reconstructing in memory the state of the
+// stores it is passed the writes of, to assert that the streamed writes
+// reproduce the state of the stores: a lost, duplicated or reordered write
+// leaves the state of the sink different from the state of its store. It
+// must be passed every write since its stores were empty, e.g. by being
+// registered before the chain is initialized. It is safe for concurrent use.
+type StateSink struct {
+	mtx    sync.Mutex
+	states map[types.StoreKey]map[string][]byte
+	stores types.MultiStore
+	err    error
+}
+
+// NewStateSink returns a new StateSink with empty stores.
+func NewStateSink() *StateSink {
+	return &StateSink{states: make(map[types.StoreKey]map[string][]byte)}
+}
+
+// OnWrite implements the WriteListener interface.
+func (s *StateSink) OnWrite(storeKey types.StoreKey, key []byte, value []byte) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	state, ok := s.states[storeKey]
+	if !ok {
+		state = make(map[string][]byte)
+		s.states[storeKey] = state
+	}
+
+	if value == nil {
+		delete(state, string(key))
+		return
+	}
+
+	state[string(key)] = append([]byte{}, value...)
+}
+
+// Verify returns an error describing the first key, in ascending order, whose
+// value in the state reconstructed for the store differs from its value in
+// the store.
+func (s *StateSink) Verify(storeKey types.StoreKey, store types.KVStore) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.verify(storeKey, store)
+}
+
+// verify implements Verify. It must be called with the lock held.
+func (s *StateSink) verify(storeKey types.StoreKey, store types.KVStore) error {
+	state := s.states[storeKey]
+
+	keys := make([]string, 0, len(state))
+	for key := range state {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	it := store.Iterator(nil, nil)
+	defer it.Close()
+
+	for i := 0; it.Valid() || i < len(keys); {
+		switch {
+		case !it.Valid() || (i < len(keys) && keys[i] < string(it.Key())):
+			return fmt.Errorf("store %s: streamed key %X is not in the store", storeKey.Name(), keys[i])
+
+		case i >= len(keys) || string(it.Key()) < keys[i]:
+			return fmt.Errorf("store %s: key %X was never streamed", storeKey.Name(), it.Key())
+
+		case !bytes.Equal(state[keys[i]], it.Value()):
+			return fmt.Errorf("store %s: streamed value %X of key %X differs from the stored value %X", storeKey.Name(), state[keys[i]], it.Key(), it.Value())
+		}
+
+		i++
+		it.Next()
+	}
+
+	return nil
+}
+
+// VerifyOnCommit makes the sink verify the state of every store it was
+// passed the writes of against the given multi-store on the commit of every
+// block, when registered as a baseapp CommitListener. The first difference
+// found is returned from Err.
+func (s *StateSink) VerifyOnCommit(stores types.MultiStore) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.stores = stores
+}
+
+// OnCommit implements the baseapp CommitListener interface.
+func (s *StateSink) OnCommit(height int64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.stores == nil || s.err != nil {
+		return
+	}
+
+	for storeKey := range s.states {
+		if err := s.verify(storeKey, s.stores.GetKVStore(storeKey)); err != nil {
+			s.err = fmt.Errorf("block %d: %w", height, err)
+			return
+		}
+	}
+}
+
+// Err returns the first difference found by the verification of the commits.
+func (s *StateSink) Err() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.err
+}
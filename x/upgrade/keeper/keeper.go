@@ -11,6 +11,7 @@ import (
 	"github.com/tendermint/tendermint/libs/log"
 	tmos "github.com/tendermint/tendermint/libs/os"
 
+	"github.com/cosmos/cosmos-sdk/baseapp"
 	"github.com/cosmos/cosmos-sdk/codec"
 	"github.com/cosmos/cosmos-sdk/store/prefix"
 	store "github.com/cosmos/cosmos-sdk/store/types"
@@ -30,6 +31,14 @@ type Keeper struct {
 	storeKey           sdk.StoreKey
 	cdc                codec.BinaryMarshaler
 	upgradeHandlers    map[string]types.UpgradeHandler
+	upgradeListeners   *upgradeListeners
+}
+
+// upgradeListeners holds the UpgradeListeners and the stores whose writes
+// they are passed.
+type upgradeListeners struct {
+	keys      map[sdk.StoreKey]bool
+	listeners []types.UpgradeListener
 }
 
 // NewKeeper constructs an upgrade Keeper
@@ -40,6 +49,7 @@ func NewKeeper(skipUpgradeHeights map[int64]bool, storeKey sdk.StoreKey, cdc cod
 		storeKey:           storeKey,
 		cdc:                cdc,
 		upgradeHandlers:    map[string]types.UpgradeHandler{},
+		upgradeListeners:   &upgradeListeners{keys: map[sdk.StoreKey]bool{}},
 	}
 }
 
@@ -50,6 +60,16 @@ func (k Keeper) SetUpgradeHandler(name string, upgradeHandler types.UpgradeHandl
 	k.upgradeHandlers[name] = upgradeHandler
 }
 
+// AddUpgradeListeners registers UpgradeListeners which are passed the writes
+// the upgrade handlers make to the stores mounted under the provided keys.
+func (k Keeper) AddUpgradeListeners(keys []sdk.StoreKey, listeners ...types.UpgradeListener) {
+	for _, key := range keys {
+		k.upgradeListeners.keys[key] = true
+	}
+
+	k.upgradeListeners.listeners = append(k.upgradeListeners.listeners, listeners...)
+}
+
 // ScheduleUpgrade schedules an upgrade based on the specified plan.
 // If there is another Plan already scheduled, it will overwrite it
 // (implicitly cancelling the current plan)
@@ -219,7 +239,7 @@ func (k Keeper) ApplyUpgrade(ctx sdk.Context, plan types.Plan) {
 		panic("ApplyUpgrade should never be called without first checking HasHandler")
 	}
 
-	handler(ctx, plan)
+	k.runUpgradeHandler(ctx, plan, handler)
 
 	// Must clear IBC state after upgrade is applied as it is stored separately from the upgrade plan.
 	// This will prevent resubmission of upgrade msg after upgrade is already completed.
@@ -230,6 +250,49 @@ func (k Keeper) ApplyUpgrade(ctx sdk.Context, plan types.Plan) {
 	k.setDone(ctx, plan.Name)
 }
 
+// listenableMultiStore is implemented by cache multi-stores that can pass the
+// writes flushed from their cache-wraps to WriteListeners.
+type listenableMultiStore interface {
+	CacheMultiStoreWithListeners(listeners map[sdk.StoreKey][]store.WriteListener) sdk.CacheMultiStore
+}
+
+// upgradeWriteBuffer is a WriteListener which buffers the writes of an
+// upgrade handler.
+type upgradeWriteBuffer struct {
+	writes []baseapp.StoreKVPair
+}
+
+// OnWrite implements the WriteListener interface.
+func (b *upgradeWriteBuffer) OnWrite(storeKey sdk.StoreKey, key []byte, value []byte) {
+	b.writes = append(b.writes, baseapp.StoreKVPair{StoreKey: storeKey, Key: key, Value: value})
+}
+
+// runUpgradeHandler runs the upgrade handler of the plan. If UpgradeListeners
+// are registered, the handler writes to a cache-wrap of the context's
+// multi-store whose writes are buffered and passed to the listeners once it
+// is written back.
+func (k Keeper) runUpgradeHandler(ctx sdk.Context, plan types.Plan, handler types.UpgradeHandler) {
+	lms, ok := ctx.MultiStore().(listenableMultiStore)
+	if !ok || k.upgradeListeners == nil || len(k.upgradeListeners.listeners) == 0 {
+		handler(ctx, plan)
+		return
+	}
+
+	buffer := &upgradeWriteBuffer{}
+	listeners := make(map[sdk.StoreKey][]store.WriteListener, len(k.upgradeListeners.keys))
+	for key := range k.upgradeListeners.keys {
+		listeners[key] = []store.WriteListener{buffer}
+	}
+
+	cache := lms.CacheMultiStoreWithListeners(listeners)
+	handler(ctx.WithMultiStore(cache), plan)
+	cache.Write()
+
+	for _, l := range k.upgradeListeners.listeners {
+		l.OnUpgrade(ctx, plan, buffer.writes)
+	}
+}
+
 // IsSkipHeight checks if the given height is part of skipUpgradeHeights
 func (k Keeper) IsSkipHeight(height int64) bool {
 	return k.skipUpgradeHeights[height]
@@ -8,9 +8,12 @@ import (
 	"github.com/stretchr/testify/suite"
 	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
 
+	"github.com/cosmos/cosmos-sdk/baseapp"
 	"github.com/cosmos/cosmos-sdk/simapp"
 	store "github.com/cosmos/cosmos-sdk/store/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
 	clienttypes "github.com/cosmos/cosmos-sdk/x/ibc/core/02-client/types"
 	commitmenttypes "github.com/cosmos/cosmos-sdk/x/ibc/core/23-commitment/types"
 	ibcexported "github.com/cosmos/cosmos-sdk/x/ibc/core/exported"
@@ -297,6 +300,40 @@ func (s *KeeperTestSuite) TestSetUpgradedClient() {
 
 }
 
+type mockUpgradeListener struct {
+	plans  []types.Plan
+	writes []baseapp.StoreKVPair
+}
+
+func (l *mockUpgradeListener) OnUpgrade(_ sdk.Context, plan types.Plan, writes []baseapp.StoreKVPair) {
+	l.plans = append(l.plans, plan)
+	l.writes = append(l.writes, writes...)
+}
+
+func (s *KeeperTestSuite) TestUpgradeListeners() {
+	listenedKey, otherKey := s.app.GetKey(banktypes.StoreKey), s.app.GetKey(authtypes.StoreKey)
+
+	listener := &mockUpgradeListener{}
+	s.app.UpgradeKeeper.AddUpgradeListeners([]sdk.StoreKey{listenedKey}, listener)
+
+	plan := types.Plan{Name: "migration", Height: 10}
+	s.app.UpgradeKeeper.SetUpgradeHandler(plan.Name, func(ctx sdk.Context, _ types.Plan) {
+		ctx.KVStore(listenedKey).Set([]byte("migrated"), []byte("value"))
+		ctx.KVStore(otherKey).Set([]byte("migrated"), []byte("value"))
+	})
+	s.app.UpgradeKeeper.ApplyUpgrade(s.ctx, plan)
+
+	s.Require().Equal([]types.Plan{plan}, listener.plans)
+	s.Require().Equal([]baseapp.StoreKVPair{
+		{StoreKey: listenedKey, Key: []byte("migrated"), Value: []byte("value")},
+	}, listener.writes)
+
+	// the writes of the handler are written back to the context's store
+	s.Require().Equal([]byte("value"), s.ctx.KVStore(listenedKey).Get([]byte("migrated")))
+	s.Require().Equal([]byte("value"), s.ctx.KVStore(otherKey).Get([]byte("migrated")))
+	s.Require().Equal(int64(10), s.app.UpgradeKeeper.GetDoneHeight(s.ctx, plan.Name))
+}
+
 func TestKeeperTestSuite(t *testing.T) {
 	suite.Run(t, new(KeeperTestSuite))
 }
@@ -1,8 +1,18 @@
 package types
 
 import (
+	"github.com/cosmos/cosmos-sdk/baseapp"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 )
 
 // UpgradeHandler specifies the type of function that is called when an upgrade is applied
 type UpgradeHandler func(ctx sdk.Context, plan Plan)
+
+// UpgradeListener is notified of every upgrade applied, so that the consumers
+// of the streamed state changes can tell the writes of the store migrations
+// apart from ordinary writes and coordinate their own schema changes.
+type UpgradeListener interface {
+	// OnUpgrade is called once the handler of the plan has run, with the
+	// writes it made to the listened stores grouped by store.
+	OnUpgrade(ctx sdk.Context, plan Plan, writes []baseapp.StoreKVPair)
+}